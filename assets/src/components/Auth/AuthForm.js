@@ -37,6 +37,7 @@ const AuthForm = () => {
             Password: passwordValue,
           }),
           headers: {
+            'Accept': 'application/json',
             'Content-Type': 'application/json',
           },
         }
@@ -14,6 +14,7 @@ const Post = (props) => {
         {
           method: 'DELETE',
           headers: {
+            'Accept': 'application/json',
             'Authorization': 'Bearer ' + authContext.token,
           },
         }
@@ -27,24 +27,29 @@ const PostForm = (props) => {
     setErrors({});
 
     try {
-      const method = props.onEditPost ? 'PUT' : 'POST';
-      let body = {
+      const body = {
         Title: titleValue,
         Content: contentValue,
       }
+      let request = {
+        method: 'POST',
+        body: JSON.stringify(body),
+        headers: {
+          'Accept': 'application/json',
+          'Content-Type': 'application/json',
+          'Authorization': 'Bearer ' + authContext.token,
+        },
+      };
+      let url = 'api/posts';
       if (props.onEditPost) {
-        body.ID = props.post.ID;
+        // Patch changes only title and content, and If-Match makes sure
+        // post wasn't changed from other tab or device in the meantime
+        url = 'api/posts/' + props.post.ID;
+        request.method = 'PATCH';
+        request.headers['Content-Type'] = 'application/merge-patch+json';
+        request.headers['If-Match'] = '"' + props.post.Version + '"';
       }
-      const response = await fetch('api/posts',
-        {
-          method: method,
-          body: JSON.stringify(body),
-          headers: {
-            'Content-Type': 'application/json',
-            'Authorization': 'Bearer ' + authContext.token,
-          },
-        }
-      );
+      const response = await fetch(url, request);
       const data = await response.json();
       if (!response.ok) {
         let errorText = 'Failed to add new post.';
@@ -17,6 +17,7 @@ const Posts = () => {
       const response = await fetch('/api/posts',
         {
           headers: {
+            'Accept': 'application/json',
             'Authorization': 'Bearer ' + authContext.token,
           },
         }
@@ -44,8 +45,32 @@ const Posts = () => {
     fetchPostsHandler();
   }, [fetchPostsHandler]);
 
+  // Keep posts in sync with changes made from other tabs and devices
+  useEffect(() => {
+    const events = new EventSource('/api/events?access_token=' + encodeURIComponent(authContext.token));
+    const upsertPost = (event) => {
+      const post = JSON.parse(event.data).data;
+      setPosts((prevState) => {
+        const others = prevState.filter(existing => { return existing.ID !== post.ID; });
+        return [...others, post].sort((a, b) => a.ID - b.ID);
+      });
+    };
+    events.addEventListener('post.created', upsertPost);
+    events.addEventListener('post.updated', upsertPost);
+    events.addEventListener('post.deleted', (event) => {
+      const postID = JSON.parse(event.data).data.ID;
+      setPosts((prevState) => {
+        return prevState.filter(post => { return post.ID !== postID; })
+      });
+    });
+    return () => events.close();
+  }, [authContext.token]);
+
   const addPostHandler = (postData) => {
-    setPosts((prevState) => { return [...prevState, postData] });
+    // Post might already be added by its event
+    setPosts((prevState) => {
+      return [...prevState.filter(post => { return post.ID !== postData.ID; }), postData]
+    });
   }
 
   const deletePostHandler = (postID) => {
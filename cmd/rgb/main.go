@@ -3,10 +3,16 @@ package main
 import (
 	"rgb/internal/cli"
 	"rgb/internal/conf"
+	"rgb/internal/logging"
 	"rgb/internal/server"
 )
 
 func main() {
 	env := cli.Parse()
-	server.Start(conf.NewConfig(env))
+	cfg := conf.NewConfig(env)
+	logging.ConfigureLogger(cfg)
+	if cfg.Env == "prod" {
+		logging.SetGinLogToFile(cfg)
+	}
+	server.Start(cfg)
 }
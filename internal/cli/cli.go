@@ -4,8 +4,6 @@ import (
 	"flag"
 	"fmt"
 	"os"
-
-	"rgb/internal/logging"
 )
 
 func usage() {
@@ -26,9 +24,5 @@ func Parse() string {
 	flag.Usage = usage
 	env := flag.String("env", "dev", `Sets run environment. Possible values are "dev" and "prod"`)
 	flag.Parse()
-	logging.ConfigureLogger(*env)
-	if *env == "prod" {
-		logging.SetGinLogToFile()
-	}
 	return *env
 }
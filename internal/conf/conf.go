@@ -3,31 +3,149 @@ package conf
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 const (
-	hostKey       = "RGB_HOST"
-	portKey       = "RGB_PORT"
-	dbHostKey     = "RGB_DB_HOST"
-	dbPortKey     = "RGB_DB_PORT"
-	dbNameKey     = "RGB_DB_NAME"
-	dbUserKey     = "RGB_DB_USER"
-	dbPasswordKey = "RGB_DB_PASSWORD"
-	jwtSecretKey  = "RGB_JWT_SECRET"
+	hostKey        = "RGB_HOST"
+	portKey        = "RGB_PORT"
+	dbHostKey      = "RGB_DB_HOST"
+	dbPortKey      = "RGB_DB_PORT"
+	dbNameKey      = "RGB_DB_NAME"
+	dbUserKey      = "RGB_DB_USER"
+	dbPasswordKey  = "RGB_DB_PASSWORD"
+	jwtSecretKey   = "RGB_JWT_SECRET"
+	autoMigrateKey = "RGB_AUTO_MIGRATE"
+
+	logDirKey            = "RGB_LOG_DIR"
+	logMaxSizeKey        = "RGB_LOG_MAX_SIZE"
+	logMaxAgeKey         = "RGB_LOG_MAX_AGE"
+	logMaxBackupsKey     = "RGB_LOG_MAX_BACKUPS"
+	logCompressKey       = "RGB_LOG_COMPRESS"
+	logRotateIntervalKey = "RGB_LOG_ROTATE_INTERVAL"
+	logLevelKey          = "RGB_LOG_LEVEL"
+	serverLogLevelKey    = "RGB_LOG_LEVEL_SERVER"
+	storeLogLevelKey     = "RGB_LOG_LEVEL_STORE"
+	jwtLogLevelKey       = "RGB_LOG_LEVEL_JWT"
+
+	adminsKey = "RGB_ADMINS"
+
+	otlpEndpointKey = "RGB_OTLP_ENDPOINT"
+	otlpInsecureKey = "RGB_OTLP_INSECURE"
+
+	shutdownDrainDelayKey = "RGB_SHUTDOWN_DRAIN_DELAY"
+
+	dbPoolSizeKey       = "RGB_DB_POOL_SIZE"
+	dbMinIdleConnsKey   = "RGB_DB_MIN_IDLE_CONNS"
+	dbMaxConnAgeKey     = "RGB_DB_MAX_CONN_AGE"
+	dbDialTimeoutKey    = "RGB_DB_DIAL_TIMEOUT"
+	dbReadTimeoutKey    = "RGB_DB_READ_TIMEOUT"
+	dbWriteTimeoutKey   = "RGB_DB_WRITE_TIMEOUT"
+	dbSSLModeKey        = "RGB_DB_SSL_MODE"
+	dbSSLRootCertKey    = "RGB_DB_SSL_ROOT_CERT"
+	dbStartupTimeoutKey = "RGB_DB_STARTUP_TIMEOUT"
+	dbQueryRetriesKey   = "RGB_DB_QUERY_RETRIES"
+	dbQueryTimeoutKey   = "RGB_DB_QUERY_TIMEOUT"
+
+	shutdownTimeoutKey = "RGB_SHUTDOWN_TIMEOUT"
+
+	errorFormatKey = "RGB_ERROR_FORMAT"
+
+	webhooksAllowPrivateKey = "RGB_WEBHOOKS_ALLOW_PRIVATE"
+
+	metricsTokenKey = "RGB_METRICS_TOKEN"
+)
+
+// Supported values of DbSSLMode, with the same meaning as libpq sslmode.
+const (
+	SSLModeDisable    = "disable"
+	SSLModeRequire    = "require"
+	SSLModeVerifyCA   = "verify-ca"
+	SSLModeVerifyFull = "verify-full"
+)
+
+// Supported values of ErrorFormat.
+const (
+	// {"error": ..., "code": ...} body expected by bundled frontend
+	ErrorFormatLegacy = "legacy"
+	// RFC 7807 application/problem+json body
+	ErrorFormatProblem = "problem"
 )
 
 type Config struct {
-	Host       string
-	Port       string
-	DbHost     string
-	DbPort     string
-	DbName     string
-	DbUser     string
-	DbPassword string
-	JwtSecret  string
-	Env        string
+	Host        string
+	Port        string
+	DbHost      string
+	DbPort      string
+	DbName      string
+	DbUser      string
+	DbPassword  string
+	JwtSecret   string
+	Env         string
+	AutoMigrate bool
+	// Directory for log files in prod environment
+	LogDir string
+	// Max size of log file in megabytes before it gets rotated
+	LogMaxSize int
+	// Max number of days to retain rotated log files
+	LogMaxAge int
+	// Max number of rotated log files to retain
+	LogMaxBackups int
+	// Compress rotated log files using gzip
+	LogCompress bool
+	// Rotate log files after this interval regardless of size, 0 disables it
+	LogRotateInterval time.Duration
+	// Default log level, "debug" in dev and "info" in prod if not set
+	LogLevel string
+	// Log levels of server, store and jwt subsystems, LogLevel if not set
+	ServerLogLevel string
+	StoreLogLevel  string
+	JwtLogLevel    string
+	// Usernames of users allowed to access admin endpoints
+	Admins []string
+	// OTLP HTTP collector address (host:port) traces are exported to,
+	// tracing is disabled if not set
+	OtlpEndpoint string
+	// Export traces over plain HTTP instead of HTTPS
+	OtlpInsecure bool
+	// Time between failing readiness checks and stopping the server on
+	// shutdown, 5s in prod and 0 in dev if not set
+	ShutdownDrainDelay time.Duration
+	// Max number of DB connections, 0 uses go-pg default of 10 per CPU
+	DbPoolSize int
+	// Min number of idle DB connections kept open
+	DbMinIdleConns int
+	// Age at which DB connection is closed, 0 keeps connections forever
+	DbMaxConnAge   time.Duration
+	DbDialTimeout  time.Duration
+	DbReadTimeout  time.Duration
+	DbWriteTimeout time.Duration
+	// One of SSLMode constants
+	DbSSLMode string
+	// Path to PEM file with CA certificates used to verify DB server
+	DbSSLRootCert string
+	// How long to wait for database to become reachable on start
+	DbStartupTimeout time.Duration
+	// Number of times idempotent queries are retried on transient errors
+	DbQueryRetries int
+	// Max duration of single store operation, 0 disables it
+	DbQueryTimeout time.Duration
+	// How long in-flight requests may run on shutdown before their
+	// queries are cancelled
+	ShutdownTimeout time.Duration
+	// Format of API error responses when client doesn't ask for specific
+	// one, one of ErrorFormat constants
+	ErrorFormat string
+	// Allow webhooks to loopback and private addresses, which are rejected
+	// by default so users can't reach internal services through them
+	WebhooksAllowPrivate bool
+	// Bearer token required to scrape metrics, which aren't served if it is
+	// empty
+	MetricsToken string
 }
 
 func NewConfig(env string) Config {
@@ -75,16 +193,90 @@ func NewConfig(env string) Config {
 		logAndPanic(jwtSecretKey)
 	}
 
+	autoMigrate := lookupBool(autoMigrateKey, false)
+
+	logDir, ok := os.LookupEnv(logDirKey)
+	if !ok || logDir == "" {
+		logDir = "logs"
+	}
+
+	logLevel := lookupLogLevel(logLevelKey, "info")
+	if env == "dev" {
+		logLevel = lookupLogLevel(logLevelKey, "debug")
+	}
+
+	dbSSLMode, ok := os.LookupEnv(dbSSLModeKey)
+	if !ok || dbSSLMode == "" {
+		dbSSLMode = SSLModeDisable
+	}
+	switch dbSSLMode {
+	case SSLModeDisable, SSLModeRequire, SSLModeVerifyCA, SSLModeVerifyFull:
+	default:
+		logAndPanic(dbSSLModeKey)
+	}
+
+	errorFormat, ok := os.LookupEnv(errorFormatKey)
+	if !ok || errorFormat == "" {
+		errorFormat = ErrorFormatLegacy
+	}
+	if errorFormat != ErrorFormatLegacy && errorFormat != ErrorFormatProblem {
+		logAndPanic(errorFormatKey)
+	}
+
+	shutdownDrainDelay := lookupDuration(shutdownDrainDelayKey, 5*time.Second)
+	if env == "dev" {
+		shutdownDrainDelay = lookupDuration(shutdownDrainDelayKey, 0)
+	}
+
 	return Config{
-		Host:       host,
-		Port:       port,
-		DbHost:     dbHost,
-		DbPort:     dbPort,
-		DbName:     dbName,
-		DbUser:     dbUser,
-		DbPassword: dbPassword,
-		JwtSecret:  jwtSecret,
-		Env:        env,
+		Host:        host,
+		Port:        port,
+		DbHost:      dbHost,
+		DbPort:      dbPort,
+		DbName:      dbName,
+		DbUser:      dbUser,
+		DbPassword:  dbPassword,
+		JwtSecret:   jwtSecret,
+		Env:         env,
+		AutoMigrate: autoMigrate,
+
+		LogDir:            logDir,
+		LogMaxSize:        lookupInt(logMaxSizeKey, 100),
+		LogMaxAge:         lookupInt(logMaxAgeKey, 30),
+		LogMaxBackups:     lookupInt(logMaxBackupsKey, 10),
+		LogCompress:       lookupBool(logCompressKey, true),
+		LogRotateInterval: lookupDuration(logRotateIntervalKey, 24*time.Hour),
+		LogLevel:          logLevel,
+		ServerLogLevel:    lookupLogLevel(serverLogLevelKey, logLevel),
+		StoreLogLevel:     lookupLogLevel(storeLogLevelKey, logLevel),
+		JwtLogLevel:       lookupLogLevel(jwtLogLevelKey, logLevel),
+
+		Admins: lookupList(adminsKey),
+
+		OtlpEndpoint: os.Getenv(otlpEndpointKey),
+		OtlpInsecure: lookupBool(otlpInsecureKey, false),
+
+		ShutdownDrainDelay: shutdownDrainDelay,
+
+		DbPoolSize:       lookupInt(dbPoolSizeKey, 0),
+		DbMinIdleConns:   lookupInt(dbMinIdleConnsKey, 0),
+		DbMaxConnAge:     lookupDuration(dbMaxConnAgeKey, 30*time.Minute),
+		DbDialTimeout:    lookupDuration(dbDialTimeoutKey, 5*time.Second),
+		DbReadTimeout:    lookupDuration(dbReadTimeoutKey, 10*time.Second),
+		DbWriteTimeout:   lookupDuration(dbWriteTimeoutKey, 10*time.Second),
+		DbSSLMode:        dbSSLMode,
+		DbSSLRootCert:    os.Getenv(dbSSLRootCertKey),
+		DbStartupTimeout: lookupDuration(dbStartupTimeoutKey, 30*time.Second),
+		DbQueryRetries:   lookupInt(dbQueryRetriesKey, 2),
+		DbQueryTimeout:   lookupDuration(dbQueryTimeoutKey, 5*time.Second),
+
+		ShutdownTimeout: lookupDuration(shutdownTimeoutKey, 5*time.Second),
+
+		ErrorFormat: errorFormat,
+
+		WebhooksAllowPrivate: lookupBool(webhooksAllowPrivateKey, false),
+
+		MetricsToken: os.Getenv(metricsTokenKey),
 	}
 }
 
@@ -94,6 +286,63 @@ func NewTestConfig() Config {
 	return testConfig
 }
 
+func lookupBool(envVar string, fallback bool) bool {
+	value, ok := os.LookupEnv(envVar)
+	if !ok || value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		logAndPanic(envVar)
+	}
+	return parsed
+}
+
+func lookupInt(envVar string, fallback int) int {
+	value, ok := os.LookupEnv(envVar)
+	if !ok || value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		logAndPanic(envVar)
+	}
+	return parsed
+}
+
+func lookupDuration(envVar string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(envVar)
+	if !ok || value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		logAndPanic(envVar)
+	}
+	return parsed
+}
+
+func lookupLogLevel(envVar string, fallback string) string {
+	value, ok := os.LookupEnv(envVar)
+	if !ok || value == "" {
+		return fallback
+	}
+	if _, err := zerolog.ParseLevel(value); err != nil {
+		logAndPanic(envVar)
+	}
+	return value
+}
+
+func lookupList(envVar string) []string {
+	list := []string{}
+	for _, item := range strings.Split(os.Getenv(envVar), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func logAndPanic(envVar string) {
 	log.Panic().Str("envVar", envVar).Msg("ENV variable not set or value not valid")
 }
@@ -3,6 +3,7 @@ package conf
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -94,3 +95,118 @@ func TestNewTestConfig(t *testing.T) {
 	assert.Equal(t, conf.DbPassword, testConf.DbPassword)
 	assert.Equal(t, "dev", conf.Env)
 }
+
+func TestNewConfigAutoMigrate(t *testing.T) {
+	autoMigrate, ok := os.LookupEnv(autoMigrateKey)
+	defer func() {
+		if ok {
+			os.Setenv(autoMigrateKey, autoMigrate)
+		} else {
+			os.Unsetenv(autoMigrateKey)
+		}
+	}()
+
+	os.Unsetenv(autoMigrateKey)
+	assert.False(t, NewConfig("dev").AutoMigrate)
+
+	os.Setenv(autoMigrateKey, "true")
+	assert.True(t, NewConfig("dev").AutoMigrate)
+
+	os.Setenv(autoMigrateKey, "invalid")
+	assert.Panics(t, func() { NewConfig("dev") })
+}
+
+func TestNewConfigLogDefaults(t *testing.T) {
+	for _, key := range []string{logDirKey, logMaxSizeKey, logMaxAgeKey, logMaxBackupsKey, logCompressKey, logRotateIntervalKey} {
+		value, ok := os.LookupEnv(key)
+		os.Unsetenv(key)
+		if ok {
+			defer os.Setenv(key, value)
+		}
+	}
+
+	conf := NewConfig("prod")
+	assert.Equal(t, "logs", conf.LogDir)
+	assert.Equal(t, 100, conf.LogMaxSize)
+	assert.Equal(t, 30, conf.LogMaxAge)
+	assert.Equal(t, 10, conf.LogMaxBackups)
+	assert.True(t, conf.LogCompress)
+	assert.Equal(t, 24*time.Hour, conf.LogRotateInterval)
+}
+
+func TestNewConfigLogRotateIntervalNotValid(t *testing.T) {
+	interval, ok := os.LookupEnv(logRotateIntervalKey)
+	err := os.Setenv(logRotateIntervalKey, "daily")
+	defer func() {
+		if ok {
+			os.Setenv(logRotateIntervalKey, interval)
+		} else {
+			os.Unsetenv(logRotateIntervalKey)
+		}
+	}()
+	assert.Nil(t, err)
+	assert.Panics(t, func() { NewConfig("prod") })
+}
+
+func TestNewConfigLogLevels(t *testing.T) {
+	for _, key := range []string{logLevelKey, serverLogLevelKey, storeLogLevelKey, jwtLogLevelKey} {
+		value, ok := os.LookupEnv(key)
+		os.Unsetenv(key)
+		if ok {
+			defer os.Setenv(key, value)
+		}
+	}
+	defer os.Unsetenv(storeLogLevelKey)
+
+	assert.Equal(t, "debug", NewConfig("dev").LogLevel)
+	assert.Equal(t, "info", NewConfig("prod").LogLevel)
+
+	os.Setenv(storeLogLevelKey, "trace")
+	conf := NewConfig("prod")
+	assert.Equal(t, "info", conf.ServerLogLevel)
+	assert.Equal(t, "trace", conf.StoreLogLevel)
+	assert.Equal(t, "info", conf.JwtLogLevel)
+
+	os.Setenv(storeLogLevelKey, "verbose")
+	assert.Panics(t, func() { NewConfig("prod") })
+}
+
+func TestNewConfigDbSSLMode(t *testing.T) {
+	sslMode, ok := os.LookupEnv(dbSSLModeKey)
+	defer func() {
+		if ok {
+			os.Setenv(dbSSLModeKey, sslMode)
+		} else {
+			os.Unsetenv(dbSSLModeKey)
+		}
+	}()
+
+	os.Unsetenv(dbSSLModeKey)
+	assert.Equal(t, SSLModeDisable, NewConfig("dev").DbSSLMode)
+
+	os.Setenv(dbSSLModeKey, SSLModeVerifyFull)
+	assert.Equal(t, SSLModeVerifyFull, NewConfig("dev").DbSSLMode)
+
+	os.Setenv(dbSSLModeKey, "prefer")
+	assert.Panics(t, func() { NewConfig("dev") })
+}
+
+func TestNewConfigErrorFormat(t *testing.T) {
+	errorFormat, ok := os.LookupEnv(errorFormatKey)
+	defer func() {
+		if ok {
+			os.Setenv(errorFormatKey, errorFormat)
+		} else {
+			os.Unsetenv(errorFormatKey)
+		}
+	}()
+
+	os.Unsetenv(errorFormatKey)
+	assert.Equal(t, ErrorFormatLegacy, NewConfig("dev").ErrorFormat)
+
+	os.Setenv(errorFormatKey, ErrorFormatProblem)
+	assert.Equal(t, ErrorFormatProblem, NewConfig("dev").ErrorFormat)
+
+	os.Setenv(errorFormatKey, "xml")
+	assert.Panics(t, func() { NewConfig("dev") })
+}
@@ -1,16 +1,94 @@
 package database
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
 	"rgb/internal/conf"
 
 	"github.com/go-pg/pg/v10"
+	"github.com/rs/zerolog/log"
 )
 
 func NewDBOptions(cfg conf.Config) *pg.Options {
 	return &pg.Options{
-		Addr:     cfg.DbHost + ":" + cfg.DbPort,
-		Database: cfg.DbName,
-		User:     cfg.DbUser,
-		Password: cfg.DbPassword,
+		Addr:         cfg.DbHost + ":" + cfg.DbPort,
+		Database:     cfg.DbName,
+		User:         cfg.DbUser,
+		Password:     cfg.DbPassword,
+		PoolSize:     cfg.DbPoolSize,
+		MinIdleConns: cfg.DbMinIdleConns,
+		MaxConnAge:   cfg.DbMaxConnAge,
+		DialTimeout:  cfg.DbDialTimeout,
+		ReadTimeout:  cfg.DbReadTimeout,
+		WriteTimeout: cfg.DbWriteTimeout,
+		TLSConfig:    newTLSConfig(cfg),
+		// go-pg would retry any failed query, including inserts which might
+		// have been executed, so only idempotent queries are retried by store.
+		MaxRetries: 0,
 	}
 }
+
+// newTLSConfig returns TLS config matching libpq sslmode semantics.
+func newTLSConfig(cfg conf.Config) *tls.Config {
+	switch cfg.DbSSLMode {
+	case conf.SSLModeRequire:
+		// Encrypt connection without verifying server certificate
+		return &tls.Config{InsecureSkipVerify: true}
+	case conf.SSLModeVerifyCA:
+		rootCAs := loadRootCAs(cfg.DbSSLRootCert)
+		return &tls.Config{
+			// Default verification also checks host name, so it is
+			// skipped and only certificate chain is verified below
+			InsecureSkipVerify: true,
+			VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				return verifyChain(rawCerts, rootCAs)
+			},
+		}
+	case conf.SSLModeVerifyFull:
+		return &tls.Config{
+			RootCAs:    loadRootCAs(cfg.DbSSLRootCert),
+			ServerName: cfg.DbHost,
+		}
+	default:
+		return nil
+	}
+}
+
+// loadRootCAs returns CA certificates from PEM file, or nil to use system
+// certificates if path is empty.
+func loadRootCAs(path string) *x509.CertPool {
+	if path == "" {
+		return nil
+	}
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Panic().Err(err).Str("path", path).Msg("Error reading DB root certificate")
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(pem) {
+		log.Panic().Str("path", path).Msg("No valid certificates in DB root certificate file")
+	}
+	return rootCAs
+}
+
+func verifyChain(rawCerts [][]byte, rootCAs *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("database server didn't provide certificate")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{Roots: rootCAs, Intermediates: intermediates})
+	return err
+}
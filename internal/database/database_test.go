@@ -3,6 +3,7 @@ package database
 import (
 	"rgb/internal/conf"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -25,3 +26,51 @@ func TestSetTestDBOptions(t *testing.T) {
 	assert.Equal(t, testCfg.DbUser, dbOptions.User)
 	assert.Equal(t, testCfg.DbPassword, dbOptions.Password)
 }
+
+func TestNewDBOptionsPool(t *testing.T) {
+	cfg := conf.NewConfig("dev")
+	cfg.DbPoolSize = 20
+	cfg.DbMinIdleConns = 2
+	cfg.DbMaxConnAge = time.Hour
+	cfg.DbDialTimeout = time.Second
+	cfg.DbReadTimeout = 2 * time.Second
+	cfg.DbWriteTimeout = 3 * time.Second
+	dbOptions := NewDBOptions(cfg)
+	assert.Equal(t, 20, dbOptions.PoolSize)
+	assert.Equal(t, 2, dbOptions.MinIdleConns)
+	assert.Equal(t, time.Hour, dbOptions.MaxConnAge)
+	assert.Equal(t, time.Second, dbOptions.DialTimeout)
+	assert.Equal(t, 2*time.Second, dbOptions.ReadTimeout)
+	assert.Equal(t, 3*time.Second, dbOptions.WriteTimeout)
+	assert.Equal(t, 0, dbOptions.MaxRetries)
+}
+
+func TestNewDBOptionsSSLModes(t *testing.T) {
+	cfg := conf.NewConfig("dev")
+
+	cfg.DbSSLMode = conf.SSLModeDisable
+	assert.Nil(t, NewDBOptions(cfg).TLSConfig)
+
+	cfg.DbSSLMode = conf.SSLModeRequire
+	tlsConfig := NewDBOptions(cfg).TLSConfig
+	assert.NotNil(t, tlsConfig)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+
+	cfg.DbSSLMode = conf.SSLModeVerifyCA
+	tlsConfig = NewDBOptions(cfg).TLSConfig
+	assert.NotNil(t, tlsConfig)
+	assert.NotNil(t, tlsConfig.VerifyPeerCertificate)
+
+	cfg.DbSSLMode = conf.SSLModeVerifyFull
+	tlsConfig = NewDBOptions(cfg).TLSConfig
+	assert.NotNil(t, tlsConfig)
+	assert.False(t, tlsConfig.InsecureSkipVerify)
+	assert.Equal(t, cfg.DbHost, tlsConfig.ServerName)
+}
+
+func TestNewDBOptionsInvalidRootCert(t *testing.T) {
+	cfg := conf.NewConfig("dev")
+	cfg.DbSSLMode = conf.SSLModeVerifyFull
+	cfg.DbSSLRootCert = "not-existing.pem"
+	assert.Panics(t, func() { NewDBOptions(cfg) })
+}
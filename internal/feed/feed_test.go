@@ -0,0 +1,82 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testFeed = Feed{
+	ID:          "https://example.com/feed.atom",
+	Title:       "Recent posts",
+	Description: "Recent posts",
+	Link:        "https://example.com/",
+	Self:        "https://example.com/feed.atom",
+	Updated:     time.Date(2021, time.October, 2, 12, 0, 0, 0, time.UTC),
+	Items: []Item{{
+		ID:        "tag:example.com,2021:posts/1",
+		Title:     "Gotham <cronicles>",
+		Content:   "Joker is planning a big hit tonight.",
+		Author:    "batman",
+		Published: time.Date(2021, time.October, 1, 12, 0, 0, 0, time.UTC),
+		Updated:   time.Date(2021, time.October, 2, 12, 0, 0, 0, time.UTC),
+	}},
+}
+
+func TestWriteRSS(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteRSS(&buf, testFeed))
+
+	var doc struct {
+		Version string `xml:"version,attr"`
+		Channel struct {
+			Title string `xml:"title"`
+			Items []struct {
+				Title   string `xml:"title"`
+				GUID    string `xml:"guid"`
+				PubDate string `xml:"pubDate"`
+				Creator string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "2.0", doc.Version)
+	assert.Equal(t, testFeed.Title, doc.Channel.Title)
+	if assert.Len(t, doc.Channel.Items, 1) {
+		item := doc.Channel.Items[0]
+		assert.Equal(t, "Gotham <cronicles>", item.Title)
+		assert.Equal(t, "tag:example.com,2021:posts/1", item.GUID)
+		assert.Equal(t, "Fri, 01 Oct 2021 12:00:00 +0000", item.PubDate)
+		assert.Equal(t, "batman", item.Creator)
+	}
+}
+
+func TestWriteAtom(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteAtom(&buf, testFeed))
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		ID      string   `xml:"id"`
+		Updated string   `xml:"updated"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Title   string `xml:"title"`
+			Updated string `xml:"updated"`
+			Author  string `xml:"author>name"`
+			Content string `xml:"content"`
+		} `xml:"entry"`
+	}
+	assert.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, testFeed.ID, doc.ID)
+	assert.Equal(t, "2021-10-02T12:00:00Z", doc.Updated)
+	if assert.Len(t, doc.Entries, 1) {
+		entry := doc.Entries[0]
+		assert.Equal(t, "tag:example.com,2021:posts/1", entry.ID)
+		assert.Equal(t, "batman", entry.Author)
+		assert.Equal(t, "Joker is planning a big hit tonight.", entry.Content)
+	}
+}
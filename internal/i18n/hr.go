@@ -0,0 +1,84 @@
+package i18n
+
+var hr = map[string]string{
+	// Validation, %[1]s is field name and %[2]s is tag parameter
+	"%[1]s is required.": "Polje %[1]s je obavezno.",
+	"%[1]s must be longer than or equal %[2]s characters.": "Polje %[1]s mora imati najmanje %[2]s znakova.",
+	"%[1]s cannot be longer than %[2]s characters.":        "Polje %[1]s može imati najviše %[2]s znakova.",
+	"%[1]s cannot have more than %[2]s items.":             "Polje %[1]s može imati najviše %[2]s stavki.",
+	"%[1]s must be in the future.":                         "Polje %[1]s mora biti u budućnosti.",
+	"%[1]s is not valid.":                                  "Polje %[1]s nije ispravno.",
+	"%[1]s must be public address.":                        "Polje %[1]s mora biti javna adresa.",
+	"%s already exists.":                                   "%s već postoji.",
+
+	// Field names
+	"Username":   "Korisničko ime",
+	"Password":   "Lozinka",
+	"Title":      "Naslov",
+	"Content":    "Sadržaj",
+	"Level":      "Razina",
+	"Duration":   "Trajanje",
+	"URL":        "URL",
+	"Events":     "Događaji",
+	"Secret":     "Tajna",
+	"Role":       "Uloga",
+	"PublishAt":  "Vrijeme objave",
+	"Operations": "Operacije",
+
+	// Errors
+	"Something went wrong!":                         "Nešto je pošlo po zlu!",
+	"Request body is not valid.":                    "Tijelo zahtjeva nije ispravno.",
+	"Not found.":                                    "Nije pronađeno.",
+	"Not authorized.":                               "Nemate ovlasti.",
+	"Not valid.":                                    "Nije ispravno.",
+	"Not valid ID.":                                 "ID nije ispravan.",
+	"Sign in failed.":                               "Prijava nije uspjela.",
+	"Authorization header missing.":                 "Nedostaje zaglavlje Authorization.",
+	"Authorization header format is not valid.":     "Format zaglavlja Authorization nije ispravan.",
+	"Authorization header is missing bearer part.":  "Zaglavlju Authorization nedostaje dio Bearer.",
+	"Last-Event-ID header is not valid.":            "Zaglavlje Last-Event-ID nije ispravno.",
+	"Token expired.":                                "Token je istekao.",
+	"Log level not valid.":                          "Razina zapisivanja nije ispravna.",
+	"Duration not valid.":                           "Trajanje nije ispravno.",
+	"Unknown log module.":                           "Nepoznat modul zapisivanja.",
+	"Post is already published.":                    "Objava je već objavljena.",
+	"Post cannot be shared with its owner.":         "Objava se ne može podijeliti s njezinim vlasnikom.",
+	"Post is not scheduled.":                        "Objava nije zakazana.",
+	"Modified in the meantime.":                     "U međuvremenu je izmijenjeno.",
+	"Content type is not supported.":                "Vrsta sadržaja nije podržana.",
+	"Operation %d failed, no changes were saved.":   "Operacija %d nije uspjela, nijedna promjena nije spremljena.",
+	"Import file is missing.":                       "Nedostaje datoteka za uvoz.",
+	"Import file cannot be larger than %d MB.":      "Datoteka za uvoz može imati najviše %d MB.",
+	"Import file cannot have more than %d files.":   "Datoteka za uvoz može imati najviše %d datoteka.",
+	"Import file is not valid.":                     "Datoteka za uvoz nije ispravna.",
+	"File is not valid Markdown with front matter.": "Datoteka nije ispravan Markdown sa zaglavljem.",
+
+	// Success
+	"Signed up successfully.":               "Registracija je uspjela.",
+	"Signed in successfully.":               "Prijava je uspjela.",
+	"Post created successfully.":            "Objava je stvorena.",
+	"Posts fetched successfully.":           "Objave su dohvaćene.",
+	"Post updated successfully.":            "Objava je ažurirana.",
+	"Post deleted successfully.":            "Objava je obrisana.",
+	"Log levels fetched successfully.":      "Razine zapisivanja su dohvaćene.",
+	"Log level changed successfully.":       "Razina zapisivanja je promijenjena.",
+	"Bulk operations executed.":             "Skupne operacije su izvršene.",
+	"Posts imported successfully.":          "Objave su uvezene.",
+	"Webhook created successfully.":         "Webhook je stvoren.",
+	"Webhooks fetched successfully.":        "Webhookovi su dohvaćeni.",
+	"Webhook deleted successfully.":         "Webhook je obrisan.",
+	"Deliveries fetched successfully.":      "Isporuke su dohvaćene.",
+	"Scheduled posts fetched successfully.": "Zakazane objave su dohvaćene.",
+	"Post scheduled successfully.":          "Objava je zakazana.",
+	"Shared posts fetched successfully.":    "Podijeljene objave su dohvaćene.",
+	"Collaborators fetched successfully.":   "Suradnici su dohvaćeni.",
+	"Post shared successfully.":             "Objava je podijeljena.",
+	"Collaborator removed successfully.":    "Suradnik je uklonjen.",
+	"Post schedule cancelled successfully.": "Zakazivanje objave je otkazano.",
+
+	// Feeds
+	"Posts by %s":               "Objave korisnika %s",
+	"Recent public posts by %s": "Nedavne javne objave korisnika %s",
+	"Recent posts":              "Nedavne objave",
+	"Recent public posts":       "Nedavne javne objave",
+}
@@ -0,0 +1,40 @@
+package i18n
+
+import (
+	"fmt"
+
+	"golang.org/x/text/language"
+)
+
+// Locales messages are translated to. The first one is used if client
+// doesn't accept any of them.
+var supported = []language.Tag{
+	language.English,
+	language.Croatian,
+}
+
+var matcher = language.NewMatcher(supported)
+
+// Translations of English messages, which are used as keys. Messages missing
+// from catalog are returned in English.
+var catalogs = map[language.Tag]map[string]string{
+	language.Croatian: hr,
+}
+
+// Locale returns supported locale which best matches Accept-Language header.
+func Locale(acceptLanguage string) language.Tag {
+	_, index := language.MatchStrings(matcher, acceptLanguage)
+	return supported[index]
+}
+
+// Translate returns message translated to locale. Args are formatted into
+// translated message like with fmt.Sprintf.
+func Translate(locale language.Tag, message string, args ...interface{}) string {
+	if translated, ok := catalogs[locale][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
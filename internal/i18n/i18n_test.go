@@ -0,0 +1,35 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+func TestLocale(t *testing.T) {
+	assert.Equal(t, language.English, Locale(""))
+	assert.Equal(t, language.English, Locale("de-DE,de;q=0.9"))
+	assert.Equal(t, language.Croatian, Locale("hr-HR,hr;q=0.9,en;q=0.8"))
+	assert.Equal(t, language.English, Locale("en-US,hr;q=0.5"))
+}
+
+func TestTranslate(t *testing.T) {
+	assert.Equal(t, "Sign in failed.", Translate(language.English, "Sign in failed."))
+	assert.Equal(t, "Prijava nije uspjela.", Translate(language.Croatian, "Sign in failed."))
+	assert.Equal(t, "Polje Lozinka je obavezno.", Translate(language.Croatian, "%[1]s is required.", Translate(language.Croatian, "Password")))
+	assert.Equal(t, "Password is required.", Translate(language.English, "%[1]s is required.", "Password"))
+	// Messages missing from catalog are returned in English
+	assert.Equal(t, "jwt: token format is not valid", Translate(language.Croatian, "jwt: token format is not valid"))
+}
+
+func TestCatalogsFormatVerbs(t *testing.T) {
+	// Translations must use the same arguments as English messages
+	verbs := regexp.MustCompile(`%(\[\d+\])?s`)
+	for locale, catalog := range catalogs {
+		for message, translated := range catalog {
+			assert.ElementsMatch(t, verbs.FindAllString(message, -1), verbs.FindAllString(translated, -1), "%s: %s", locale, message)
+		}
+	}
+}
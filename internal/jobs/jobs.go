@@ -0,0 +1,329 @@
+// Package jobs runs background jobs saved in Postgres job queue. Jobs are
+// enqueued in the same transaction as the change they are about, claimed
+// with SELECT ... FOR UPDATE SKIP LOCKED, so every instance of the server
+// runs different jobs, and retried with exponential backoff if they fail.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"rgb/internal/logging"
+	"rgb/internal/store"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Handler runs job of the type it is registered for. Job is retried if it
+// returns error, so it should be safe to run more than once.
+type Handler func(ctx context.Context, job *store.Job) error
+
+// Options of job type. Zero values are replaced by defaults.
+type Options struct {
+	// Maximum number of jobs of the type one runner runs at once, 1 by default
+	Concurrency int
+	// Job fails after this many attempts, 5 by default
+	MaxAttempts int
+	// Maximum duration of one attempt, 1 minute by default
+	Timeout time.Duration
+	// Delay before the first retry, doubled after every failed attempt up
+	// to MaxBackoff. 30 seconds and 6 hours by default.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Called after the last attempt failed
+	OnFailure func(ctx context.Context, job *store.Job, err error)
+}
+
+func (o Options) withDefaults() Options {
+	if o.Concurrency <= 0 {
+		o.Concurrency = 1
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 5
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = time.Minute
+	}
+	if o.Backoff <= 0 {
+		o.Backoff = 30 * time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 6 * time.Hour
+	}
+	return o
+}
+
+// backoff returns delay before next attempt after given number of failed
+// attempts.
+func (o Options) backoff(attempts int) time.Duration {
+	delay := o.Backoff
+	for i := 1; i < attempts && delay < o.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > o.MaxBackoff {
+		return o.MaxBackoff
+	}
+	return delay
+}
+
+type registration struct {
+	handler Handler
+	opts    Options
+	// Interval of recurring job, 0 if job isn't recurring
+	every time.Duration
+	// Number of jobs being run, guarded by Runner.mu
+	running int
+}
+
+// Runner periodically claims due jobs of registered types and runs them.
+type Runner struct {
+	repo store.JobRepository
+	// Time between checks for due jobs
+	Interval time.Duration
+
+	types []string
+	jobs  map[string]*registration
+
+	mu      sync.Mutex
+	running sync.WaitGroup
+	// Context of running jobs, cancelled if they don't finish before
+	// shutdown deadline
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+	stop       context.CancelFunc
+	stopped    chan struct{}
+}
+
+func NewRunner(repo store.JobRepository) *Runner {
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	return &Runner{
+		repo:       repo,
+		Interval:   time.Second,
+		jobs:       map[string]*registration{},
+		jobsCtx:    jobsCtx,
+		cancelJobs: cancelJobs,
+	}
+}
+
+// Register sets handler of job type. It must be called before Start.
+func (r *Runner) Register(jobType string, handler Handler, opts Options) {
+	if _, ok := r.jobs[jobType]; !ok {
+		r.types = append(r.types, jobType)
+	}
+	r.jobs[jobType] = &registration{handler: handler, opts: opts.withDefaults()}
+}
+
+// Every makes registered job type recurring. Next job is enqueued interval
+// after the previous one finishes, and only one of them is pending at a time,
+// no matter how many runners there are.
+func (r *Runner) Every(jobType string, interval time.Duration) {
+	reg, ok := r.jobs[jobType]
+	if !ok {
+		panic(fmt.Sprintf("jobs: job type %q is not registered", jobType))
+	}
+	reg.every = interval
+}
+
+// New returns job of given type with payload marshalled to JSON.
+func New(jobType string, payload interface{}) (*store.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &store.Job{Type: jobType, Payload: data}, nil
+}
+
+// Start enqueues recurring jobs, if they aren't already, and starts running
+// due jobs until Shutdown.
+func (r *Runner) Start() {
+	ctx, stop := context.WithCancel(context.Background())
+	r.stop = stop
+	r.stopped = make(chan struct{})
+	for _, jobType := range r.types {
+		if every := r.jobs[jobType].every; every > 0 {
+			r.enqueueNext(ctx, jobType, every)
+		}
+	}
+	go func() {
+		defer close(r.stopped)
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+		for {
+			r.claim(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Shutdown stops claiming new jobs and waits for running ones. If ctx is done
+// first, their contexts are cancelled, and jobs which don't return by then
+// are claimed again when their lease expires.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	if r.stop != nil {
+		r.stop()
+		<-r.stopped
+	}
+	done := make(chan struct{})
+	go func() {
+		r.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	r.cancelJobs()
+	// Give interrupted jobs a moment to save that they should be run again
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+	}
+	return ctx.Err()
+}
+
+// RunDue claims due jobs, runs them and waits for them to finish. It returns
+// number of claimed jobs.
+func (r *Runner) RunDue(ctx context.Context) int {
+	claimed := r.claim(ctx)
+	r.running.Wait()
+	return claimed
+}
+
+// claim claims due jobs of every type up to its concurrency limit, starts
+// running them and returns their number.
+func (r *Runner) claim(ctx context.Context) int {
+	claimed := 0
+	for _, jobType := range r.types {
+		reg := r.jobs[jobType]
+		r.mu.Lock()
+		free := reg.opts.Concurrency - reg.running
+		r.mu.Unlock()
+		if free <= 0 {
+			continue
+		}
+		// Lease outlives timeout, so job isn't claimed twice while running
+		jobs, err := r.repo.Claim(ctx, []string{jobType}, free, 2*reg.opts.Timeout)
+		if err != nil {
+			continue
+		}
+		for _, job := range jobs {
+			r.mu.Lock()
+			reg.running++
+			r.mu.Unlock()
+			r.running.Add(1)
+			go r.run(job, reg)
+		}
+		claimed += len(jobs)
+	}
+	return claimed
+}
+
+func (r *Runner) run(job *store.Job, reg *registration) {
+	defer r.running.Done()
+	defer func() {
+		r.mu.Lock()
+		reg.running--
+		r.mu.Unlock()
+	}()
+	ctx, cancel := context.WithTimeout(r.jobsCtx, reg.opts.Timeout)
+	err := call(ctx, reg.handler, job)
+	cancel()
+	r.finish(job, reg, err)
+}
+
+// call runs handler and converts its panic to error, so it doesn't crash
+// the server.
+func call(ctx context.Context, handler Handler, job *store.Job) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// finish saves result of job attempt, and enqueues next recurring job.
+func (r *Runner) finish(job *store.Job, reg *registration, err error) {
+	// Don't lose result of the attempt when jobs are cancelled during it
+	ctx := context.Background()
+	attempt := job.Attempts
+	log := logger(ctx).With().
+		Int("job_id", job.ID).
+		Str("type", job.Type).
+		Int("attempt", job.Attempts).
+		Logger()
+	now := time.Now()
+	switch {
+	case err == nil:
+		job.Status = store.JobDone
+		job.LastError = ""
+		job.FinishedAt = &now
+		log.Debug().Msg("Job done")
+	case r.jobsCtx.Err() != nil:
+		// Interrupted by shutdown, which doesn't count as attempt
+		job.Attempts--
+		job.RunAt = now
+		job.LastError = err.Error()
+		log.Info().Err(err).Msg("Job interrupted by shutdown")
+	case job.Attempts >= reg.opts.MaxAttempts:
+		job.Status = store.JobFailed
+		job.LastError = err.Error()
+		job.FinishedAt = &now
+		log.Warn().Err(err).Msg("Job failed, giving up")
+	default:
+		job.LastError = err.Error()
+		job.RunAt = now.Add(reg.opts.backoff(job.Attempts))
+		log.Info().Err(err).Time("run_at", job.RunAt).Msg("Job failed")
+	}
+	if err := r.repo.Update(ctx, job, attempt); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			// Lease expired and job was claimed again, whose attempt now
+			// owns the result
+			log.Warn().Msg("Job lease lost, discarding result")
+			return
+		}
+		log.Error().Err(err).Msg("Error saving job result")
+		return
+	}
+	if job.Status == store.JobFailed && reg.opts.OnFailure != nil {
+		reg.opts.OnFailure(ctx, job, err)
+	}
+	if job.Status != store.JobPending && reg.every > 0 {
+		r.enqueueNext(ctx, job.Type, reg.every)
+	}
+}
+
+// enqueueNext enqueues recurring job due after interval. Type is used as key,
+// so it isn't enqueued if it is already pending.
+func (r *Runner) enqueueNext(ctx context.Context, jobType string, interval time.Duration) {
+	job := &store.Job{Type: jobType, Key: jobType, RunAt: time.Now().Add(interval)}
+	if err := r.repo.Enqueue(ctx, job); err != nil {
+		logger(ctx).Error().Err(err).Str("type", jobType).Msg("Error enqueueing recurring job")
+	}
+}
+
+// PurgeType is type of job deleting old finished jobs.
+const PurgeType = "jobs.purge"
+
+// Purge returns handler of PurgeType, which deletes jobs finished more than
+// retention ago.
+func Purge(repo store.JobRepository, retention time.Duration) Handler {
+	return func(ctx context.Context, job *store.Job) error {
+		purged, err := repo.Purge(ctx, time.Now().Add(-retention))
+		if err != nil {
+			return err
+		}
+		logger(ctx).Info().Int("purged", purged).Msg("Purged finished jobs")
+		return nil
+	}
+}
+
+func logger(ctx context.Context) *zerolog.Logger { return logging.Module(ctx, logging.Server) }
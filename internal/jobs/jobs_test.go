@@ -0,0 +1,242 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"rgb/internal/store"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type payload struct {
+	Name string
+}
+
+func enqueue(t *testing.T, repo store.JobRepository, jobType string) *store.Job {
+	job, err := New(jobType, payload{Name: "batman"})
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Enqueue(context.Background(), job))
+	return job
+}
+
+func TestRunDue(t *testing.T) {
+	repos := store.NewMemoryRepositories()
+	runner := NewRunner(repos.Jobs)
+	var received []string
+	runner.Register("test", func(ctx context.Context, job *store.Job) error {
+		received = append(received, string(job.Payload))
+		return nil
+	}, Options{})
+	job := enqueue(t, repos.Jobs, "test")
+	// Not registered
+	enqueue(t, repos.Jobs, "other")
+
+	assert.Equal(t, 1, runner.RunDue(context.Background()))
+	assert.Equal(t, []string{`{"Name":"batman"}`}, received)
+	job, err := repos.Jobs.Fetch(context.Background(), job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, store.JobDone, job.Status)
+	assert.NotNil(t, job.FinishedAt)
+	assert.Equal(t, 0, runner.RunDue(context.Background()))
+}
+
+func TestRetries(t *testing.T) {
+	repos := store.NewMemoryRepositories()
+	runner := NewRunner(repos.Jobs)
+	var failed error
+	runner.Register("test", func(ctx context.Context, job *store.Job) error {
+		if job.Attempts == 2 {
+			panic("boom")
+		}
+		return errors.New("unavailable")
+	}, Options{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		OnFailure:   func(ctx context.Context, job *store.Job, err error) { failed = err },
+	})
+	job := enqueue(t, repos.Jobs, "test")
+
+	assert.Equal(t, 1, runner.RunDue(context.Background()))
+	job, _ = repos.Jobs.Fetch(context.Background(), job.ID)
+	assert.Equal(t, store.JobPending, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+	assert.Equal(t, "unavailable", job.LastError)
+	// Not due until backoff passes
+	assert.Equal(t, 0, runner.RunDue(context.Background()))
+
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, 1, runner.RunDue(context.Background()))
+	job, _ = repos.Jobs.Fetch(context.Background(), job.ID)
+	assert.Equal(t, "panic: boom", job.LastError)
+
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, 1, runner.RunDue(context.Background()))
+	job, _ = repos.Jobs.Fetch(context.Background(), job.ID)
+	assert.Equal(t, store.JobFailed, job.Status)
+	assert.Equal(t, 3, job.Attempts)
+	assert.EqualError(t, failed, "unavailable")
+}
+
+func TestLostLease(t *testing.T) {
+	repos := store.NewMemoryRepositories()
+	runner := NewRunner(repos.Jobs)
+	var failed error
+	runner.Register("test", func(ctx context.Context, job *store.Job) error {
+		// Lease expires and other instance claims the job
+		time.Sleep(15 * time.Millisecond)
+		_, err := repos.Jobs.Claim(context.Background(), []string{"test"}, 1, time.Minute)
+		assert.NoError(t, err)
+		return errors.New("unavailable")
+	}, Options{
+		Timeout:     5 * time.Millisecond,
+		MaxAttempts: 1,
+		OnFailure:   func(ctx context.Context, job *store.Job, err error) { failed = err },
+	})
+	job := enqueue(t, repos.Jobs, "test")
+
+	assert.Equal(t, 1, runner.RunDue(context.Background()))
+	// Result of the first attempt doesn't overwrite the second one
+	job, _ = repos.Jobs.Fetch(context.Background(), job.ID)
+	assert.Equal(t, store.JobPending, job.Status)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Empty(t, job.LastError)
+	assert.NoError(t, failed)
+}
+
+func TestScheduledJob(t *testing.T) {
+	repos := store.NewMemoryRepositories()
+	runner := NewRunner(repos.Jobs)
+	runner.Register("test", func(ctx context.Context, job *store.Job) error { return nil }, Options{})
+	job := &store.Job{Type: "test", RunAt: time.Now().Add(20 * time.Millisecond)}
+	assert.NoError(t, repos.Jobs.Enqueue(context.Background(), job))
+
+	assert.Equal(t, 0, runner.RunDue(context.Background()))
+	time.Sleep(25 * time.Millisecond)
+	assert.Equal(t, 1, runner.RunDue(context.Background()))
+}
+
+func TestConcurrency(t *testing.T) {
+	repos := store.NewMemoryRepositories()
+	runner := NewRunner(repos.Jobs)
+	release := make(chan struct{})
+	var running, maxRunning int32
+	runner.Register("test", func(ctx context.Context, job *store.Job) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		<-release
+		return nil
+	}, Options{Concurrency: 2})
+	for i := 0; i < 5; i++ {
+		enqueue(t, repos.Jobs, "test")
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.Equal(t, 2, runner.RunDue(context.Background()))
+	}()
+	// Running jobs use all slots
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&running) == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, 0, runner.claim(context.Background()))
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, 2, runner.RunDue(context.Background()))
+	assert.Equal(t, 1, runner.RunDue(context.Background()))
+	assert.Equal(t, int32(2), maxRunning)
+}
+
+func TestRecurringJob(t *testing.T) {
+	repos := store.NewMemoryRepositories()
+	runner := NewRunner(repos.Jobs)
+	runner.Interval = time.Millisecond
+	var runs int32
+	runner.Register("test", func(ctx context.Context, job *store.Job) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}, Options{})
+	runner.Every("test", 5*time.Millisecond)
+
+	runner.Start()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&runs) >= 2 }, time.Second, time.Millisecond)
+	assert.NoError(t, runner.Shutdown(context.Background()))
+}
+
+func TestShutdownWaitsForJobs(t *testing.T) {
+	repos := store.NewMemoryRepositories()
+	runner := NewRunner(repos.Jobs)
+	runner.Interval = time.Millisecond
+	started := make(chan struct{})
+	var done int32
+	runner.Register("test", func(ctx context.Context, job *store.Job) error {
+		close(started)
+		time.Sleep(20 * time.Millisecond)
+		atomic.StoreInt32(&done, 1)
+		return nil
+	}, Options{})
+	job := enqueue(t, repos.Jobs, "test")
+
+	runner.Start()
+	<-started
+	assert.NoError(t, runner.Shutdown(context.Background()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&done))
+	job, _ = repos.Jobs.Fetch(context.Background(), job.ID)
+	assert.Equal(t, store.JobDone, job.Status)
+}
+
+func TestShutdownInterruptsJobs(t *testing.T) {
+	repos := store.NewMemoryRepositories()
+	runner := NewRunner(repos.Jobs)
+	runner.Interval = time.Millisecond
+	started := make(chan struct{})
+	runner.Register("test", func(ctx context.Context, job *store.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, Options{})
+	job := enqueue(t, repos.Jobs, "test")
+
+	runner.Start()
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, runner.Shutdown(ctx), context.DeadlineExceeded)
+	// Interrupted job is run again on the next start
+	job, _ = repos.Jobs.Fetch(context.Background(), job.ID)
+	assert.Equal(t, store.JobPending, job.Status)
+	assert.Equal(t, 0, job.Attempts)
+	assert.False(t, job.RunAt.After(time.Now()))
+}
+
+func TestBackoff(t *testing.T) {
+	opts := Options{}.withDefaults()
+	assert.Equal(t, 30*time.Second, opts.backoff(1))
+	assert.Equal(t, time.Minute, opts.backoff(2))
+	assert.Equal(t, 2*time.Minute, opts.backoff(3))
+	assert.Equal(t, 6*time.Hour, opts.backoff(20))
+}
+
+func TestPurge(t *testing.T) {
+	repos := store.NewMemoryRepositories()
+	runner := NewRunner(repos.Jobs)
+	runner.Register(PurgeType, Purge(repos.Jobs, 0), Options{})
+	runner.Register("test", func(ctx context.Context, job *store.Job) error { return nil }, Options{})
+	job := enqueue(t, repos.Jobs, "test")
+	assert.Equal(t, 1, runner.RunDue(context.Background()))
+
+	enqueue(t, repos.Jobs, PurgeType)
+	assert.Equal(t, 1, runner.RunDue(context.Background()))
+	_, err := repos.Jobs.Fetch(context.Background(), job.ID)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
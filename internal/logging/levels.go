@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Subsystems with separately configurable log levels.
+const (
+	Server = "server"
+	Store  = "store"
+	JWT    = "jwt"
+)
+
+var ErrUnknownModule = errors.New("Unknown log module.")
+
+type ModuleLevel struct {
+	Level      string
+	Configured string
+	// Time when temporary level reverts to the configured one, nil if
+	// level is not changed
+	RevertAt *time.Time
+}
+
+type moduleLevel struct {
+	configured zerolog.Level
+	current    zerolog.Level
+	revertAt   time.Time
+	revert     *time.Timer
+}
+
+var (
+	levelsMu sync.RWMutex
+	levels   = map[string]*moduleLevel{}
+)
+
+// Module returns logger from the ctx with level set to module's current level.
+func Module(ctx context.Context, module string) *zerolog.Logger {
+	logger := Ctx(ctx).With().Str("module", module).Logger()
+	if level, ok := moduleLogLevel(module); ok {
+		logger = logger.Level(level)
+	}
+	return &logger
+}
+
+func setModuleLevels(configured map[string]zerolog.Level) {
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	for _, level := range levels {
+		if level.revert != nil {
+			level.revert.Stop()
+		}
+	}
+	levels = make(map[string]*moduleLevel, len(configured))
+	for module, level := range configured {
+		levels[module] = &moduleLevel{configured: level, current: level}
+	}
+}
+
+func moduleLogLevel(module string) (zerolog.Level, bool) {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	if level, ok := levels[module]; ok {
+		return level.current, true
+	}
+	return zerolog.NoLevel, false
+}
+
+// SetModuleLevel temporarily changes module's log level. After duration
+// passes, level reverts to the configured one.
+func SetModuleLevel(module string, level zerolog.Level, duration time.Duration) error {
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	moduleLevel, ok := levels[module]
+	if !ok {
+		return ErrUnknownModule
+	}
+	if moduleLevel.revert != nil {
+		moduleLevel.revert.Stop()
+	}
+	moduleLevel.current = level
+	moduleLevel.revertAt = time.Now().Add(duration)
+	moduleLevel.revert = time.AfterFunc(duration, func() { resetModuleLevel(module) })
+	return nil
+}
+
+func resetModuleLevel(module string) {
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	if moduleLevel, ok := levels[module]; ok {
+		moduleLevel.current = moduleLevel.configured
+		moduleLevel.revertAt = time.Time{}
+		moduleLevel.revert = nil
+	}
+}
+
+// ModuleLevels returns current and configured log level of every module.
+func ModuleLevels() map[string]ModuleLevel {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	moduleLevels := make(map[string]ModuleLevel, len(levels))
+	for module, level := range levels {
+		moduleLevel := ModuleLevel{
+			Level:      level.current.String(),
+			Configured: level.configured.String(),
+		}
+		if level.revert != nil {
+			revertAt := level.revertAt
+			moduleLevel.RevertAt = &revertAt
+		}
+		moduleLevels[module] = moduleLevel
+	}
+	return moduleLevels
+}
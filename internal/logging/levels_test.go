@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModuleLevel(t *testing.T) {
+	setModuleLevels(map[string]zerolog.Level{Store: zerolog.WarnLevel})
+
+	assert.Equal(t, zerolog.WarnLevel, Module(context.Background(), Store).GetLevel())
+	assert.Equal(t, "warn", ModuleLevels()[Store].Level)
+	assert.Nil(t, ModuleLevels()[Store].RevertAt)
+}
+
+func TestSetModuleLevel(t *testing.T) {
+	setModuleLevels(map[string]zerolog.Level{Store: zerolog.WarnLevel})
+
+	err := SetModuleLevel(Store, zerolog.DebugLevel, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, zerolog.DebugLevel, Module(context.Background(), Store).GetLevel())
+	assert.Equal(t, "debug", ModuleLevels()[Store].Level)
+	assert.Equal(t, "warn", ModuleLevels()[Store].Configured)
+	assert.NotNil(t, ModuleLevels()[Store].RevertAt)
+}
+
+func TestSetModuleLevelReverts(t *testing.T) {
+	setModuleLevels(map[string]zerolog.Level{Store: zerolog.WarnLevel})
+
+	err := SetModuleLevel(Store, zerolog.DebugLevel, 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return Module(context.Background(), Store).GetLevel() == zerolog.WarnLevel
+	}, time.Second, 5*time.Millisecond)
+	assert.Nil(t, ModuleLevels()[Store].RevertAt)
+}
+
+func TestSetUnknownModuleLevel(t *testing.T) {
+	setModuleLevels(map[string]zerolog.Level{Store: zerolog.WarnLevel})
+
+	err := SetModuleLevel("unknown", zerolog.DebugLevel, time.Hour)
+	assert.ErrorIs(t, err, ErrUnknownModule)
+}
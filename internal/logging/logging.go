@@ -1,92 +1,99 @@
 package logging
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strings"
+	"rgb/internal/conf"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 const (
-	logsDir = "logs"
-	logName = "gin_production.log"
+	logName    = "production.log"
+	ginLogName = "gin_production.log"
 )
 
-var logFilePath = filepath.Join(logsDir, logName)
-
-func SetGinLogToFile() {
+func SetGinLogToFile(cfg conf.Config) {
 	gin.SetMode(gin.ReleaseMode)
-	logFile, err := os.Create(logFilePath)
-	if err != nil {
-		log.Panic().Err(err).Msg("Error opening Gin log file")
-	}
+	logFile := newRotatingFile(cfg, ginLogName)
+	// Requests are logged by server's access log middleware, so this file
+	// only receives Gin's own debug output and recovered panics.
 	gin.DefaultWriter = io.MultiWriter(logFile)
+	gin.DefaultErrorWriter = io.MultiWriter(logFile)
 }
 
-func ConfigureLogger(env string) {
-	zerolog.SetGlobalLevel(zerolog.DebugLevel)
-	switch env {
+func ConfigureLogger(cfg conf.Config) {
+	// Global level doesn't filter anything, levels are set per logger so
+	// modules can log more verbosely than the default level.
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	var logger zerolog.Logger
+	switch cfg.Env {
 	case "dev":
 		stdOutWriter := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: "15:04:05.000"}
-		logger := zerolog.New(stdOutWriter).With().Timestamp().Logger()
-		log.Logger = logger
+		logger = zerolog.New(stdOutWriter).With().Timestamp().Logger()
 	case "prod":
-		createLogDir()
-		backupLastLog()
-		logFile := openLogFile()
-		logFileWriter := zerolog.ConsoleWriter{Out: logFile, NoColor: true, TimeFormat: "15:04:05.000"}
-		logger := zerolog.New(logFileWriter).With().Timestamp().Logger()
-		log.Logger = logger
+		logFile := newRotatingFile(cfg, logName)
+		zerolog.TimeFieldFormat = time.RFC3339Nano
+		logger = zerolog.New(logFile).With().Timestamp().Logger()
 	default:
-		fmt.Printf("Env not valid: %s\n", env)
+		fmt.Printf("Env not valid: %s\n", cfg.Env)
 		os.Exit(2)
 	}
+	log.Logger = logger.Level(parseLevel(cfg.LogLevel))
+	setModuleLevels(map[string]zerolog.Level{
+		Server: parseLevel(cfg.ServerLogLevel),
+		Store:  parseLevel(cfg.StoreLogLevel),
+		JWT:    parseLevel(cfg.JwtLogLevel),
+	})
 }
 
-func createLogDir() {
-	if err := os.Mkdir(logsDir, 0744); err != nil && !os.IsExist(err) {
-		log.Fatal().Err(err).Msg("Unable to create logs directory.")
-	}
-}
-
-func backupLastLog() {
-	timeStamp := time.Now().Format("20060201_15_04_05")
-	base := strings.TrimSuffix(logName, filepath.Ext(logName))
-	bkpLogName := base + "_" + timeStamp + "." + filepath.Ext(logName)
-	bkpLogPath := filepath.Join(logsDir, bkpLogName)
-
-	logFile, err := ioutil.ReadFile(logFilePath)
+func parseLevel(level string) zerolog.Level {
+	parsed, err := zerolog.ParseLevel(level)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return
-		}
-		log.Panic().Err(err).Msg("Error reading log file for backup")
+		log.Panic().Err(err).Str("level", level).Msg("Log level not valid")
 	}
+	return parsed
+}
 
-	if err = ioutil.WriteFile(bkpLogPath, logFile, 0644); err != nil {
-		log.Panic().Err(err).Msg("Error writing backup log file")
+// Ctx returns logger associated with the ctx, falling back to global logger
+// if there is none, so log messages are never silently discarded.
+func Ctx(ctx context.Context) *zerolog.Logger {
+	if logger := zerolog.Ctx(ctx); logger.GetLevel() != zerolog.Disabled {
+		return logger
 	}
+	return &log.Logger
 }
 
-func openLogFile() *os.File {
-	logFile, err := os.OpenFile(logFilePath, os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
-		log.Panic().Err(err).Msg("Error while opening log file")
+// newRotatingFile returns log file writer which rotates file once it reaches
+// configured size or age, compressing and eventually removing old files.
+func newRotatingFile(cfg conf.Config, name string) *lumberjack.Logger {
+	logFile := &lumberjack.Logger{
+		Filename:   filepath.Join(cfg.LogDir, name),
+		MaxSize:    cfg.LogMaxSize,
+		MaxAge:     cfg.LogMaxAge,
+		MaxBackups: cfg.LogMaxBackups,
+		Compress:   cfg.LogCompress,
+		LocalTime:  true,
+	}
+	if cfg.LogRotateInterval > 0 {
+		go rotateEvery(logFile, cfg.LogRotateInterval)
 	}
 	return logFile
 }
 
-func curentDir() string {
-	path, err := os.Executable()
-	if err != nil {
-		log.Panic().Err(err).Msg("Can't get current directory.")
+func rotateEvery(logFile *lumberjack.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := logFile.Rotate(); err != nil {
+			log.Error().Err(err).Str("file", logFile.Filename).Msg("Error rotating log file")
+		}
 	}
-	return filepath.Dir(path)
 }
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Known SQL operations. Anything else is reported as OTHER to keep
+// number of label values small.
+var operations = map[string]bool{
+	"SELECT": true,
+	"INSERT": true,
+	"UPDATE": true,
+	"DELETE": true,
+	"BEGIN":  true,
+	"COMMIT": true,
+}
+
+// QueryHook records duration of every query executed through go-pg.
+type QueryHook struct{}
+
+var _ pg.QueryHook = QueryHook{}
+
+func (QueryHook) BeforeQuery(ctx context.Context, _ *pg.QueryEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+func (QueryHook) AfterQuery(ctx context.Context, event *pg.QueryEvent) error {
+	failed := event.Err != nil && event.Err != pg.ErrNoRows
+	DBQueryDuration.
+		WithLabelValues(queryOperation(event), strconv.FormatBool(failed)).
+		Observe(time.Since(event.StartTime).Seconds())
+	return nil
+}
+
+func queryOperation(event *pg.QueryEvent) string {
+	query, err := event.UnformattedQuery()
+	if err != nil {
+		return "OTHER"
+	}
+	fields := bytes.Fields(query)
+	if len(fields) == 0 {
+		return "OTHER"
+	}
+	operation := string(bytes.ToUpper(fields[0]))
+	if !operations[operation] {
+		return "OTHER"
+	}
+	return operation
+}
+
+type poolStatsCollector struct {
+	db func() *pg.DB
+
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+}
+
+// RegisterDBPool exposes connection pool stats of the database returned by db.
+// Stats are read on every scrape, so db may return different connection
+// over time.
+func RegisterDBPool(db func() *pg.DB) {
+	Registry.MustRegister(newPoolStatsCollector(db))
+}
+
+func newPoolStatsCollector(db func() *pg.DB) *poolStatsCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+	return &poolStatsCollector{
+		db:         db,
+		hits:       desc("hits_total", "Number of times free connection was found in the pool."),
+		misses:     desc("misses_total", "Number of times free connection was not found in the pool."),
+		timeouts:   desc("timeouts_total", "Number of times waiting for connection timed out."),
+		totalConns: desc("connections", "Number of connections in the pool."),
+		idleConns:  desc("idle_connections", "Number of idle connections in the pool."),
+		staleConns: desc("stale_connections_total", "Number of stale connections removed from the pool."),
+	}
+}
+
+func (c *poolStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+	ch <- c.totalConns
+	ch <- c.idleConns
+	ch <- c.staleConns
+}
+
+func (c *poolStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	db := c.db()
+	if db == nil {
+		return
+	}
+	stats := db.PoolStats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.staleConns, prometheus.CounterValue, float64(stats.StaleConns))
+}
@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryOperation(t *testing.T) {
+	assert.Equal(t, "SELECT", queryOperation(&pg.QueryEvent{Query: "SELECT 1"}))
+	assert.Equal(t, "INSERT", queryOperation(&pg.QueryEvent{Query: "\n\tinsert into posts values (1)"}))
+	assert.Equal(t, "OTHER", queryOperation(&pg.QueryEvent{Query: "ALTER SEQUENCE posts_id_seq RESTART"}))
+	assert.Equal(t, "OTHER", queryOperation(&pg.QueryEvent{Query: ""}))
+}
+
+func TestPoolStatsCollectorWithoutDB(t *testing.T) {
+	collector := newPoolStatsCollector(func() *pg.DB { return nil })
+	assert.Equal(t, 0, testutil.CollectAndCount(collector))
+}
+
+func TestPoolStatsCollector(t *testing.T) {
+	db := pg.Connect(&pg.Options{Addr: "localhost:5432"})
+	defer db.Close()
+	collector := newPoolStatsCollector(func() *pg.DB { return db })
+	assert.Equal(t, 6, testutil.CollectAndCount(collector))
+}
@@ -1,4 +1,4 @@
-package main
+package migrations
 
 import (
 	"fmt"
@@ -7,7 +7,7 @@ import (
 )
 
 func init() {
-	migrations.MustRegisterTx(func(db migrations.DB) error {
+	collection.MustRegisterTx(func(db migrations.DB) error {
 		fmt.Println("creating table posts...")
 		_, err := db.Exec(`CREATE TABLE posts(
 			id SERIAL PRIMARY KEY,
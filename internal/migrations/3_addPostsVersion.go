@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations/v8"
+)
+
+func init() {
+	collection.MustRegisterTx(func(db migrations.DB) error {
+		fmt.Println("adding version column to posts...")
+		_, err := db.Exec(`ALTER TABLE posts ADD COLUMN version INT NOT NULL DEFAULT 1`)
+		return err
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping version column from posts...")
+		_, err := db.Exec(`ALTER TABLE posts DROP COLUMN version`)
+		return err
+	})
+}
@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations/v8"
+)
+
+func init() {
+	collection.MustRegisterTx(func(db migrations.DB) error {
+		fmt.Println("adding published and published_at columns to posts...")
+		_, err := db.Exec(`ALTER TABLE posts
+			ADD COLUMN published BOOLEAN NOT NULL DEFAULT FALSE,
+			ADD COLUMN published_at TIMESTAMPTZ`)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(`CREATE INDEX posts_published_at_idx ON posts (published_at DESC) WHERE published`)
+		return err
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping published and published_at columns from posts...")
+		_, err := db.Exec(`ALTER TABLE posts DROP COLUMN published, DROP COLUMN published_at`)
+		return err
+	})
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations/v8"
+)
+
+func init() {
+	collection.MustRegisterTx(func(db migrations.DB) error {
+		fmt.Println("creating tables webhooks and webhook_deliveries...")
+		_, err := db.Exec(`CREATE TABLE webhooks(
+			id SERIAL PRIMARY KEY,
+			url TEXT NOT NULL,
+			events TEXT[] NOT NULL,
+			secret TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			user_id INT NOT NULL REFERENCES users ON DELETE CASCADE
+		)`)
+		if err != nil {
+			return err
+		}
+		// Deliveries are outbox of webhook events, saved in the same
+		// transaction as the change they are about
+		_, err = db.Exec(`CREATE TABLE webhook_deliveries(
+			id SERIAL PRIMARY KEY,
+			webhook_id INT NOT NULL REFERENCES webhooks ON DELETE CASCADE,
+			event TEXT NOT NULL,
+			payload JSONB NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INT NOT NULL DEFAULT 0,
+			next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			response_status INT NOT NULL DEFAULT 0,
+			last_error TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			delivered_at TIMESTAMPTZ
+		)`)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(`CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending'`)
+		return err
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping tables webhook_deliveries and webhooks...")
+		_, err := db.Exec(`DROP TABLE webhook_deliveries, webhooks`)
+		return err
+	})
+}
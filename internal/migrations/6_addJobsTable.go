@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations/v8"
+)
+
+func init() {
+	collection.MustRegisterTx(func(db migrations.DB) error {
+		fmt.Println("creating table jobs...")
+		_, err := db.Exec(`CREATE TABLE jobs(
+			id SERIAL PRIMARY KEY,
+			type TEXT NOT NULL,
+			payload JSONB NOT NULL DEFAULT '{}',
+			key TEXT,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INT NOT NULL DEFAULT 0,
+			run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			last_error TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			finished_at TIMESTAMPTZ
+		)`)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(`CREATE INDEX jobs_due_idx ON jobs (run_at) WHERE status = 'pending'`)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(`CREATE UNIQUE INDEX jobs_pending_key_idx ON jobs (key) WHERE status = 'pending'`)
+		if err != nil {
+			return err
+		}
+		// Deliveries are scheduled by jobs now
+		fmt.Println("dropping column next_attempt_at from table webhook_deliveries...")
+		_, err = db.Exec(`ALTER TABLE webhook_deliveries DROP COLUMN next_attempt_at`)
+		return err
+	}, func(db migrations.DB) error {
+		fmt.Println("adding column next_attempt_at to table webhook_deliveries...")
+		_, err := db.Exec(`ALTER TABLE webhook_deliveries ADD COLUMN next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW()`)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(`CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending'`)
+		if err != nil {
+			return err
+		}
+		fmt.Println("dropping table jobs...")
+		_, err = db.Exec(`DROP TABLE jobs`)
+		return err
+	})
+}
@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations/v8"
+)
+
+func init() {
+	collection.MustRegisterTx(func(db migrations.DB) error {
+		fmt.Println("creating table events...")
+		_, err := db.Exec(`CREATE TABLE events(
+			id BIGSERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			payload JSONB NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(`CREATE INDEX events_user_id_idx ON events (user_id, id)`)
+		if err != nil {
+			return err
+		}
+		// Notifications are sent when transaction commits, so listeners
+		// never see events which were rolled back
+		_, err = db.Exec(`CREATE FUNCTION notify_event() RETURNS trigger AS $$
+			BEGIN
+				PERFORM pg_notify('events', NEW.user_id::text);
+				RETURN NULL;
+			END;
+			$$ LANGUAGE plpgsql`)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(`CREATE TRIGGER events_notify AFTER INSERT ON events
+			FOR EACH ROW EXECUTE PROCEDURE notify_event()`)
+		return err
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping table events...")
+		_, err := db.Exec(`DROP TABLE events`)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(`DROP FUNCTION notify_event()`)
+		return err
+	})
+}
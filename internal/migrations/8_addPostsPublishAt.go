@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations/v8"
+)
+
+func init() {
+	collection.MustRegisterTx(func(db migrations.DB) error {
+		fmt.Println("adding publish_at column to posts...")
+		_, err := db.Exec(`ALTER TABLE posts ADD COLUMN publish_at TIMESTAMPTZ`)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(`CREATE INDEX posts_publish_at_idx ON posts (publish_at) WHERE publish_at IS NOT NULL`)
+		return err
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping publish_at column from posts...")
+		_, err := db.Exec(`ALTER TABLE posts DROP COLUMN publish_at`)
+		return err
+	})
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations/v8"
+)
+
+func init() {
+	collection.MustRegisterTx(func(db migrations.DB) error {
+		fmt.Println("creating table post_collaborators...")
+		_, err := db.Exec(`CREATE TABLE post_collaborators(
+			post_id INT NOT NULL REFERENCES posts ON DELETE CASCADE,
+			user_id INT NOT NULL REFERENCES users ON DELETE CASCADE,
+			role TEXT NOT NULL CHECK (role IN ('viewer', 'editor')),
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (post_id, user_id)
+		)`)
+		if err != nil {
+			return err
+		}
+		// Primary key covers lookups by post, this one "shared with me"
+		// listing
+		_, err = db.Exec(`CREATE INDEX post_collaborators_user_id_idx ON post_collaborators (user_id)`)
+		return err
+	}, func(db migrations.DB) error {
+		fmt.Println("dropping table post_collaborators...")
+		_, err := db.Exec(`DROP TABLE post_collaborators`)
+		return err
+	})
+}
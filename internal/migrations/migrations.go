@@ -0,0 +1,106 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/go-pg/migrations/v8"
+	"github.com/go-pg/pg/v10"
+	"github.com/rs/zerolog/log"
+)
+
+// Arbitrary key shared by every instance of the app, so only one replica at
+// a time can run migrations against the same database.
+const advisoryLockKey = 7241811
+
+// Migrations are compiled into the binary, so SQL autodiscovery from the
+// source directory is disabled.
+var collection = migrations.NewCollection().DisableSQLAutodiscover(true)
+
+// ExpectedVersion returns schema version this binary was built for.
+func ExpectedVersion() int64 {
+	registered := collection.Migrations()
+	if len(registered) == 0 {
+		return 0
+	}
+	return registered[len(registered)-1].Version
+}
+
+// Version returns current schema version of the database.
+func Version(db *pg.DB) (int64, error) {
+	exists, err := versionTableExists(db)
+	if err != nil || !exists {
+		return 0, err
+	}
+	return collection.Version(db)
+}
+
+// Run executes migrations command while holding migrations lock.
+// Supported commands are the same as in go-pg/migrations package.
+func Run(db *pg.DB, args ...string) (oldVersion, newVersion int64, err error) {
+	err = withLock(db, func(conn *pg.Conn) error {
+		oldVersion, newVersion, err = collection.Run(conn, args...)
+		return err
+	})
+	return
+}
+
+// Migrate brings database schema up to the expected version. If another
+// instance is already migrating, Migrate blocks until it is done.
+func Migrate(db *pg.DB) error {
+	return withLock(db, func(conn *pg.Conn) error {
+		if _, _, err := collection.Run(conn, "init"); err != nil {
+			return err
+		}
+		oldVersion, newVersion, err := collection.Run(conn, "up")
+		if err != nil {
+			return err
+		}
+		if oldVersion != newVersion {
+			log.Info().Int64("from", oldVersion).Int64("to", newVersion).Msg("Database migrated")
+		}
+		return nil
+	})
+}
+
+// CheckVersion returns error if database schema version doesn't match
+// version this binary expects.
+func CheckVersion(db *pg.DB) error {
+	version, err := Version(db)
+	if err != nil {
+		return err
+	}
+	expected := ExpectedVersion()
+	switch {
+	case version < expected:
+		return fmt.Errorf("database schema version %d is behind expected version %d", version, expected)
+	case version > expected:
+		return fmt.Errorf("database schema version %d is ahead of expected version %d", version, expected)
+	}
+	return nil
+}
+
+func withLock(db *pg.DB, fn func(conn *pg.Conn) error) error {
+	// Advisory locks are held by session, so lock, migrations and unlock
+	// must all run on the same connection.
+	conn := db.Conn()
+	defer conn.Close()
+
+	if _, err := conn.Exec("SELECT pg_advisory_lock(?)", advisoryLockKey); err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := conn.Exec("SELECT pg_advisory_unlock(?)", advisoryLockKey); err != nil {
+			log.Error().Err(err).Msg("Error releasing migrations lock")
+		}
+	}()
+
+	return fn(conn)
+}
+
+func versionTableExists(db *pg.DB) (bool, error) {
+	return db.Model().
+		Table("pg_tables").
+		Where("schemaname = 'public'").
+		Where("tablename = 'gopg_migrations'").
+		Exists()
+}
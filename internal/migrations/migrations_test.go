@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpectedVersion(t *testing.T) {
+	registered := collection.Migrations()
+	assert.NotEmpty(t, registered)
+	assert.Equal(t, registered[len(registered)-1].Version, ExpectedVersion())
+}
+
+func TestMigrationsAreSequential(t *testing.T) {
+	for i, migration := range collection.Migrations() {
+		assert.Equal(t, int64(i+1), migration.Version)
+		assert.NotNil(t, migration.Up)
+		assert.NotNil(t, migration.Down)
+	}
+}
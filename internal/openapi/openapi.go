@@ -0,0 +1,212 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version of OpenAPI specification documents are written in.
+const Version = "3.0.3"
+
+// Document is subset of OpenAPI document used by the app.
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *int               `json:"minimum,omitempty"`
+	Maximum              *int               `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+// Ref returns schema referencing component schema with given name.
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// SchemaOf returns schema of JSON encoding of value. Constraints are derived
+// from binding tags used by Gin's validator.
+func SchemaOf(value interface{}) *Schema {
+	return schemaOf(reflect.TypeOf(value))
+}
+
+func schemaOf(typ reflect.Type) *Schema {
+	if typ.Kind() == reflect.Ptr {
+		schema := schemaOf(typ.Elem())
+		schema.Nullable = true
+		return schema
+	}
+	if typ == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	// Raw JSON can be any value
+	if typ == rawMessageType {
+		return &Schema{}
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: schemaOf(typ.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(typ.Elem())}
+	case reflect.Struct:
+		return structSchema(typ)
+	default:
+		return &Schema{}
+	}
+}
+
+func structSchema(typ reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := jsonName(field)
+		if name == "" {
+			continue
+		}
+		fieldSchema := schemaOf(field.Type)
+		if applyBinding(fieldSchema, field.Tag.Get("binding")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = fieldSchema
+	}
+	return schema
+}
+
+// jsonName returns name of field in JSON, or empty string if field is not
+// encoded.
+func jsonName(field reflect.StructField) string {
+	tag := strings.Split(field.Tag.Get("json"), ",")[0]
+	switch tag {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	default:
+		return tag
+	}
+}
+
+// applyBinding adds constraints from binding tag to schema and reports
+// whether field is required.
+func applyBinding(schema *Schema, binding string) bool {
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		parts := strings.SplitN(rule, "=", 2)
+		switch parts[0] {
+		case "required":
+			required = true
+		case "min", "max":
+			if len(parts) != 2 {
+				continue
+			}
+			limit, err := strconv.Atoi(parts[1])
+			if err != nil {
+				continue
+			}
+			setLimit(schema, parts[0], limit)
+		}
+	}
+	return required
+}
+
+func setLimit(schema *Schema, rule string, limit int) {
+	switch {
+	case schema.Type == "string" && rule == "min":
+		schema.MinLength = &limit
+	case schema.Type == "string" && rule == "max":
+		schema.MaxLength = &limit
+	case schema.Type == "array" && rule == "min":
+		schema.MinItems = &limit
+	case schema.Type == "array" && rule == "max":
+		schema.MaxItems = &limit
+	case rule == "min":
+		schema.Minimum = &limit
+	case rule == "max":
+		schema.Maximum = &limit
+	}
+}
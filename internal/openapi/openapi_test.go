@@ -0,0 +1,37 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testUser struct {
+	ID        int
+	Username  string   `binding:"required,min=5,max=30"`
+	Age       int      `json:"age" binding:"min=18"`
+	Secret    []byte   `json:"-"`
+	Tags      []string `binding:"max=10"`
+	CreatedAt time.Time
+	Settings  json.RawMessage
+	hidden    string
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema := SchemaOf(testUser{})
+	assert.Equal(t, "object", schema.Type)
+	assert.Equal(t, []string{"Username"}, schema.Required)
+	assert.Equal(t, "integer", schema.Properties["ID"].Type)
+	assert.Equal(t, 5, *schema.Properties["Username"].MinLength)
+	assert.Equal(t, 30, *schema.Properties["Username"].MaxLength)
+	assert.Equal(t, 18, *schema.Properties["age"].Minimum)
+	assert.Equal(t, "array", schema.Properties["Tags"].Type)
+	assert.Equal(t, "string", schema.Properties["Tags"].Items.Type)
+	assert.Equal(t, 10, *schema.Properties["Tags"].MaxItems)
+	assert.Equal(t, "date-time", schema.Properties["CreatedAt"].Format)
+	assert.Equal(t, &Schema{}, schema.Properties["Settings"])
+	assert.NotContains(t, schema.Properties, "Secret")
+	assert.NotContains(t, schema.Properties, "hidden")
+}
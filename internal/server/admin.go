@@ -0,0 +1,61 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"rgb/internal/logging"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// Temporary log level changes can't outlive this duration, so forgotten
+// debug level doesn't flood production logs.
+const maxLogLevelDuration = 24 * time.Hour
+
+type logLevelChange struct {
+	Level    string `binding:"required"`
+	Duration string `binding:"required"`
+}
+
+func indexLogLevels(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  translate(ctx, "Log levels fetched successfully."),
+		"data": logging.ModuleLevels(),
+	})
+}
+
+func setLogLevel(ctx *gin.Context) {
+	change := ctx.MustGet(gin.BindKey).(*logLevelChange)
+	level, err := zerolog.ParseLevel(change.Level)
+	if err != nil || level == zerolog.NoLevel {
+		abortWithError(ctx, newAPIError(http.StatusBadRequest, CodeValidation, "Log level not valid."))
+		return
+	}
+	duration, err := time.ParseDuration(change.Duration)
+	if err != nil || duration <= 0 || duration > maxLogLevelDuration {
+		abortWithError(ctx, newAPIError(http.StatusBadRequest, CodeValidation, "Duration not valid."))
+		return
+	}
+	module := ctx.Param("module")
+	if err := logging.SetModuleLevel(module, level, duration); err != nil {
+		if errors.Is(err, logging.ErrUnknownModule) {
+			abortWithError(ctx, newAPIError(http.StatusNotFound, CodeNotFound, err.Error()))
+			return
+		}
+		abortWithError(ctx, err)
+		return
+	}
+	user, _ := currentUser(ctx)
+	logger(ctx).Warn().
+		Str("log_module", module).
+		Str("level", level.String()).
+		Dur("duration", duration).
+		Str("admin", user.Username).
+		Msg("Log level changed")
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  translate(ctx, "Log level changed successfully."),
+		"data": logging.ModuleLevels()[module],
+	})
+}
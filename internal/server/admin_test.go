@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexLogLevels(t *testing.T) {
+	t.Parallel()
+	s := testSetupWithAdmins("batman")
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+
+	rec := PerformAuthorizedRequest(s, token, "GET", "/api/admin/log-levels", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Log levels fetched successfully.", jsonRes(rec.Body)["msg"])
+	assert.NotNil(t, jsonFieldData(jsonRes(rec.Body), "store"))
+}
+
+func TestIndexLogLevelsNotAdmin(t *testing.T) {
+	t.Parallel()
+	s := testSetupWithAdmins("superman")
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+
+	rec := PerformAuthorizedRequest(s, token, "GET", "/api/admin/log-levels", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "Not authorized.", jsonRes(rec.Body)["error"])
+}
+
+func TestSetLogLevel(t *testing.T) {
+	t.Parallel()
+	s := testSetupWithAdmins("batman")
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+
+	body := `{"Level": "trace", "Duration": "10m"}`
+	rec := PerformAuthorizedRequest(s, token, "PUT", "/api/admin/log-levels/store", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Log level changed successfully.", jsonRes(rec.Body)["msg"])
+	assert.Equal(t, "trace", jsonFieldData(jsonRes(rec.Body), "Level"))
+	assert.NotEmpty(t, jsonFieldData(jsonRes(rec.Body), "RevertAt"))
+}
+
+func TestSetLogLevelUnknownModule(t *testing.T) {
+	t.Parallel()
+	s := testSetupWithAdmins("batman")
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+
+	body := `{"Level": "trace", "Duration": "10m"}`
+	rec := PerformAuthorizedRequest(s, token, "PUT", "/api/admin/log-levels/unknown", body)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "Unknown log module.", jsonRes(rec.Body)["error"])
+}
+
+func TestSetLogLevelInvalidDuration(t *testing.T) {
+	t.Parallel()
+	s := testSetupWithAdmins("batman")
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+
+	body := `{"Level": "trace", "Duration": "48h"}`
+	rec := PerformAuthorizedRequest(s, token, "PUT", "/api/admin/log-levels/store", body)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Duration not valid.", jsonRes(rec.Body)["error"])
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"rgb/internal/store"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	// Maximum number of operations in single bulk request, enforced by
+	// binding tag of Operations
+	maxBulkOperations = 100
+	// Maximum size of bulk request body, which fits maximum number of
+	// operations with the longest posts
+	maxBulkBodySize = 4 << 20
+)
+
+type bulkRequest struct {
+	// If set, all operations are executed in one transaction and none of
+	// them is saved if any fails. Otherwise every operation is executed on
+	// its own and results are reported per operation.
+	Atomic     bool
+	Operations []bulkOperation `binding:"required,max=100"`
+}
+
+type bulkOperation struct {
+	Op string `binding:"required,oneof=create update delete"`
+	// Post to update or delete
+	ID      int
+	Title   string
+	Content string
+	// Published flag of post to update is kept if not set
+	Published *bool
+	// Version of post to update, current version if not set
+	Version int
+}
+
+// bulkResult is result of single bulk operation. Error fields have the same
+// meaning as in error response envelope.
+type bulkResult struct {
+	Status int
+	Data   *store.Post       `json:",omitempty"`
+	Error  string            `json:",omitempty"`
+	Code   string            `json:",omitempty"`
+	Fields map[string]string `json:",omitempty"`
+}
+
+func (s *Server) bulkPosts(ctx *gin.Context) {
+	req := ctx.MustGet(gin.BindKey).(*bulkRequest)
+	user, err := currentUser(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	results := make([]bulkResult, len(req.Operations))
+	if !req.Atomic {
+		for i, op := range req.Operations {
+			post, err := s.executeBulkOperation(ctx.Request.Context(), user, op)
+			results[i] = newBulkResult(ctx, post, err)
+		}
+	} else {
+		// Index of operation which failed and rolled back the transaction
+		failed := -1
+		err := s.tx.WithTx(ctx.Request.Context(), func(txCtx context.Context) error {
+			failed = -1
+			for i, op := range req.Operations {
+				post, err := s.executeBulkOperation(txCtx, user, op)
+				if err != nil {
+					failed = i
+					return err
+				}
+				results[i] = bulkResult{Status: http.StatusOK, Data: post}
+			}
+			return nil
+		})
+		if err != nil && failed < 0 {
+			abortWithError(ctx, err)
+			return
+		}
+		if err != nil {
+			abortWithError(ctx, bulkError(ctx, failed, toAPIError(ctx, bulkGinError(err))))
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  translate(ctx, "Bulk operations executed."),
+		"data": results,
+	})
+}
+
+// executeBulkOperation validates and executes single operation, returning
+// created or updated post.
+func (s *Server) executeBulkOperation(ctx context.Context, user *store.User, op bulkOperation) (*store.Post, error) {
+	if err := binding.Validator.ValidateStruct(op); err != nil {
+		return nil, err
+	}
+	post := &store.Post{ID: op.ID, Title: op.Title, Content: op.Content, Version: op.Version}
+	if op.Published != nil {
+		post.Published = *op.Published
+	}
+	if op.Op == "create" {
+		if err := binding.Validator.ValidateStruct(post); err != nil {
+			return nil, err
+		}
+		return post, s.withEvent(ctx, user, store.EventPostCreated, post, func(ctx context.Context) error {
+			return s.addPost(ctx, user, post)
+		})
+	}
+
+	dbPost, err := s.posts.Fetch(ctx, op.ID)
+	if err != nil {
+		return nil, err
+	}
+	role := store.RoleEditor
+	if op.Op == "delete" {
+		role = roleOwner
+	}
+	if err := s.authorizePost(ctx, user, dbPost, role); err != nil {
+		return nil, err
+	}
+	if op.Op == "delete" {
+		return nil, s.withEvent(ctx, user, store.EventPostDeleted, dbPost, func(ctx context.Context) error {
+			return s.posts.Delete(ctx, dbPost)
+		})
+	}
+	if err := binding.Validator.ValidateStruct(post); err != nil {
+		return nil, err
+	}
+	if post.Version == 0 {
+		post.Version = dbPost.Version
+	}
+	if op.Published == nil {
+		post.Published = dbPost.Published
+	}
+	post.PublishAt = dbPost.PublishAt
+	post.UserID = dbPost.UserID
+	post.CreatedAt = dbPost.CreatedAt
+	post.ModifiedAt = time.Now()
+	return post, s.withEvent(ctx, &store.User{ID: dbPost.UserID}, store.EventPostUpdated, post, func(ctx context.Context) error {
+		return s.savePost(ctx, post)
+	})
+}
+
+func newBulkResult(ctx *gin.Context, post *store.Post, err error) bulkResult {
+	if err == nil {
+		return bulkResult{Status: http.StatusOK, Data: post}
+	}
+	apiErr := toAPIError(ctx, bulkGinError(err))
+	return bulkResult{
+		Status: apiErr.status,
+		Error:  translate(ctx, apiErr.message, apiErr.args...),
+		Code:   apiErr.code,
+		Fields: apiErr.fields,
+	}
+}
+
+// bulkGinError wraps operation error, so validation errors are reported the
+// same way as errors binding request body.
+func bulkGinError(err error) *gin.Error {
+	ginErr := &gin.Error{Err: err, Type: gin.ErrorTypePrivate}
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		ginErr.Type = gin.ErrorTypeBind
+	}
+	return ginErr
+}
+
+// bulkError returns error of atomic bulk request, reporting which operation
+// failed. Field names are prefixed with operation index.
+func bulkError(ctx *gin.Context, index int, opErr *apiError) *apiError {
+	apiErr := newAPIError(opErr.status, opErr.code, "Operation %d failed, no changes were saved.")
+	apiErr.args = []interface{}{index}
+	apiErr.fields = map[string]string{}
+	prefix := fmt.Sprintf("Operations[%d]", index)
+	for field, message := range opErr.fields {
+		apiErr.fields[prefix+"."+field] = message
+	}
+	if len(apiErr.fields) == 0 {
+		apiErr.fields[prefix] = translate(ctx, opErr.message, opErr.args...)
+	}
+	return apiErr
+}
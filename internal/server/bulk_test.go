@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func bulkResults(t *testing.T, body []byte) []bulkResult {
+	var res struct {
+		Data []bulkResult
+	}
+	assert.NoError(t, json.Unmarshal(body, &res))
+	return res.Data
+}
+
+func TestBulkPosts(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
+	notOwnedPost := s.addTestPost(s.addTestUser2())
+
+	body := fmt.Sprintf(`{"Operations": [
+		{"Op": "create", "Title": "Gotham cronicles", "Content": "Joker is planning big hit tonight."},
+		{"Op": "create", "Title": "Go", "Content": "Joker is planning big hit tonight."},
+		{"Op": "update", "ID": %d, "Title": "Gotham at night", "Content": "Gotham never sleeps."},
+		{"Op": "delete", "ID": %d},
+		{"Op": "move", "ID": %d}
+	]}`, post.ID, notOwnedPost.ID, post.ID)
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/posts/bulk", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Bulk operations executed.", jsonRes(rec.Body)["msg"])
+
+	results := bulkResults(t, rec.Body.Bytes())
+	if assert.Len(t, results, 5) {
+		assert.Equal(t, http.StatusOK, results[0].Status)
+		assert.Equal(t, "Gotham cronicles", results[0].Data.Title)
+		assert.Equal(t, http.StatusBadRequest, results[1].Status)
+		assert.Equal(t, CodeValidation, results[1].Code)
+		assert.Equal(t, "Title must be longer than or equal 3 characters.", results[1].Fields["Title"])
+		assert.Equal(t, http.StatusOK, results[2].Status)
+		assert.Equal(t, 2, results[2].Data.Version)
+		assert.Equal(t, http.StatusForbidden, results[3].Status)
+		assert.Equal(t, "Op is not valid.", results[4].Fields["Op"])
+	}
+
+	rec = PerformAuthorizedRequest(s, token, "GET", "/api/posts", "")
+	assert.Len(t, jsonDataSlice(rec.Body), 2)
+}
+
+func TestBulkPostsAtomic(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
+
+	body := fmt.Sprintf(`{"Atomic": true, "Operations": [
+		{"Op": "create", "Title": "Gotham cronicles", "Content": "Joker is planning big hit tonight."},
+		{"Op": "delete", "ID": %d}
+	]}`, post.ID)
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/posts/bulk", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, bulkResults(t, rec.Body.Bytes()), 2)
+
+	rec = PerformAuthorizedRequest(s, token, "GET", "/api/posts", "")
+	posts := jsonDataSlice(rec.Body)
+	if assert.Len(t, posts, 1) {
+		assert.Equal(t, "Gotham cronicles", posts[0]["Title"])
+	}
+}
+
+func TestBulkPostsAtomicRollback(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+
+	body := `{"Atomic": true, "Operations": [
+		{"Op": "create", "Title": "Gotham cronicles", "Content": "Joker is planning big hit tonight."},
+		{"Op": "create", "Title": "Gotham cronicles", "Content": ""}
+	]}`
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/posts/bulk", body)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	res := jsonRes(rec.Body)
+	assert.Equal(t, CodeValidation, res["code"])
+	assert.Equal(t, "Content is required.", jsonFieldError(res, "Operations[1].Content"))
+
+	rec = PerformAuthorizedRequest(s, token, "GET", "/api/posts", "")
+	assert.Empty(t, jsonDataSlice(rec.Body))
+}
+
+func TestBulkPostsAtomicNotFound(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+
+	body := `{"Atomic": true, "Operations": [{"Op": "delete", "ID": 42}]}`
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/posts/bulk", body)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "Not found.", jsonFieldError(jsonRes(rec.Body), "Operations[0]"))
+}
+
+func TestBulkPostsLimit(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+
+	operations := make([]string, maxBulkOperations+1)
+	for i := range operations {
+		operations[i] = `{"Op": "delete", "ID": 1}`
+	}
+	body := `{"Operations": [` + strings.Join(operations, ",") + `]}`
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/posts/bulk", body)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Operations cannot have more than 100 items.", jsonFieldError(jsonRes(rec.Body), "Operations"))
+}
+
+func TestBulkPostsBodyTooLarge(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+
+	body := `{"Operations": [{"Op": "create", "Title": "Gotham cronicles", "Content": "` + strings.Repeat("a", maxBulkBodySize) + `"}]}`
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/posts/bulk", body)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "validation_failed", jsonRes(rec.Body)["code"])
+	rec = PerformAuthorizedRequest(s, token, "GET", "/api/posts", "")
+	assert.Empty(t, jsonDataSlice(rec.Body))
+}
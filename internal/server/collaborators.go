@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"rgb/internal/store"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Role of post's owner, who can also delete post and share it.
+const roleOwner = "owner"
+
+// Every role allows what roles with lower rank do.
+var roleRanks = map[string]int{
+	store.RoleViewer: 1,
+	store.RoleEditor: 2,
+	roleOwner:        3,
+}
+
+type collaboratorRequest struct {
+	Username string `binding:"required"`
+	Role     string `binding:"required,oneof=viewer editor"`
+}
+
+// authorizePost returns ErrForbidden unless user owns post or it is shared
+// with them with at least given role.
+func (s *Server) authorizePost(ctx context.Context, user *store.User, post *store.Post, role string) error {
+	if post.UserID == user.ID {
+		return nil
+	}
+	granted, err := s.collaborators.Role(ctx, post.ID, user.ID)
+	if err != nil {
+		return err
+	}
+	if roleRanks[granted] < roleRanks[role] {
+		return store.ErrForbidden
+	}
+	return nil
+}
+
+// postWithRole returns current user and post from path if user has at least
+// given role on it, and aborts the request otherwise.
+func (s *Server) postWithRole(ctx *gin.Context, role string) (*store.User, *store.Post, bool) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		abortWithError(ctx, newAPIError(http.StatusBadRequest, CodeValidation, "Not valid ID."))
+		return nil, nil, false
+	}
+	user, err := currentUser(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return nil, nil, false
+	}
+	post, err := s.posts.Fetch(ctx.Request.Context(), id)
+	if err != nil {
+		abortWithError(ctx, err)
+		return nil, nil, false
+	}
+	if err := s.authorizePost(ctx.Request.Context(), user, post, role); err != nil {
+		abortWithError(ctx, err)
+		return nil, nil, false
+	}
+	return user, post, true
+}
+
+// indexSharedPosts lists posts other users shared with current user, with
+// role they have on them.
+func (s *Server) indexSharedPosts(ctx *gin.Context) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	shared, err := s.collaborators.FetchShared(ctx.Request.Context(), user)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  translate(ctx, "Shared posts fetched successfully."),
+		"data": shared,
+	})
+}
+
+func (s *Server) indexCollaborators(ctx *gin.Context) {
+	_, post, ok := s.postWithRole(ctx, roleOwner)
+	if !ok {
+		return
+	}
+	collaborators, err := s.collaborators.FetchPostCollaborators(ctx.Request.Context(), post.ID)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  translate(ctx, "Collaborators fetched successfully."),
+		"data": collaborators,
+	})
+}
+
+// grantRole shares post with user, or changes their role if it is already
+// shared with them.
+func (s *Server) grantRole(ctx *gin.Context) {
+	req := ctx.MustGet(gin.BindKey).(*collaboratorRequest)
+	user, post, ok := s.postWithRole(ctx, roleOwner)
+	if !ok {
+		return
+	}
+	if req.Username == user.Username {
+		abortWithError(ctx, newAPIError(http.StatusBadRequest, CodeValidation, "Post cannot be shared with its owner."))
+		return
+	}
+	collaboratorUser, err := s.users.FetchByUsername(ctx.Request.Context(), req.Username)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	collaborator := &store.Collaborator{PostID: post.ID, UserID: collaboratorUser.ID, Role: req.Role}
+	if err := s.collaborators.Grant(ctx.Request.Context(), collaborator); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	collaborator.Username = collaboratorUser.Username
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  translate(ctx, "Post shared successfully."),
+		"data": collaborator,
+	})
+}
+
+// revokeRole stops sharing post with user from path.
+func (s *Server) revokeRole(ctx *gin.Context) {
+	_, post, ok := s.postWithRole(ctx, roleOwner)
+	if !ok {
+		return
+	}
+	collaboratorUser, err := s.users.FetchByUsername(ctx.Request.Context(), ctx.Param("username"))
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	if err := s.collaborators.Revoke(ctx.Request.Context(), post.ID, collaboratorUser.ID); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": translate(ctx, "Collaborator removed successfully.")})
+}
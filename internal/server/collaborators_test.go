@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func shareJSON(username, role string) string {
+	return fmt.Sprintf(`{"Username": %q, "Role": %q}`, username, role)
+}
+
+func TestSharePost(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	owner := s.addTestUser()
+	token := s.generateJWT(owner)
+	post := s.addTestPost(owner)
+	collaborator := s.addTestUser2()
+	collaboratorToken := s.generateJWT(collaborator)
+	path := fmt.Sprintf("/api/v1/posts/%d/collaborators", post.ID)
+
+	rec := PerformAuthorizedRequest(s, token, "PUT", path, shareJSON(collaborator.Username, "viewer"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Post shared successfully.", jsonRes(rec.Body)["msg"])
+	assert.Equal(t, collaborator.Username, jsonFieldData(jsonRes(rec.Body), "Username"))
+
+	rec = PerformAuthorizedRequest(s, collaboratorToken, "GET", "/api/v1/posts/shared", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	shared := jsonDataSlice(rec.Body)
+	if assert.Len(t, shared, 1) {
+		assert.Equal(t, "viewer", shared[0]["Role"])
+		assert.Equal(t, post.Title, shared[0]["Post"].(map[string]interface{})["Title"])
+	}
+
+	// Sharing again changes role
+	rec = PerformAuthorizedRequest(s, token, "PUT", path, shareJSON(collaborator.Username, "editor"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = PerformAuthorizedRequest(s, token, "GET", path, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	collaborators := jsonDataSlice(rec.Body)
+	if assert.Len(t, collaborators, 1) {
+		assert.Equal(t, collaborator.Username, collaborators[0]["Username"])
+		assert.Equal(t, "editor", collaborators[0]["Role"])
+	}
+
+	rec = PerformAuthorizedRequest(s, token, "DELETE", path+"/"+collaborator.Username, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Collaborator removed successfully.", jsonRes(rec.Body)["msg"])
+	rec = PerformAuthorizedRequest(s, collaboratorToken, "GET", "/api/v1/posts/shared", "")
+	assert.Empty(t, jsonDataSlice(rec.Body))
+	rec = PerformAuthorizedRequest(s, token, "DELETE", path+"/"+collaborator.Username, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestSharePostNotValid(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	owner := s.addTestUser()
+	token := s.generateJWT(owner)
+	post := s.addTestPost(owner)
+	collaborator := s.addTestUser2()
+	path := fmt.Sprintf("/api/v1/posts/%d/collaborators", post.ID)
+
+	rec := PerformAuthorizedRequest(s, token, "PUT", path, shareJSON(collaborator.Username, "owner"))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Role is not valid.", jsonFieldError(jsonRes(rec.Body), "Role"))
+
+	rec = PerformAuthorizedRequest(s, token, "PUT", path, shareJSON(owner.Username, "editor"))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Post cannot be shared with its owner.", jsonRes(rec.Body)["error"])
+
+	rec = PerformAuthorizedRequest(s, token, "PUT", path, shareJSON("joker", "editor"))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Only owner can share post
+	rec = PerformAuthorizedRequest(s, token, "PUT", path, shareJSON(collaborator.Username, "editor"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = PerformAuthorizedRequest(s, s.generateJWT(collaborator), "PUT", path, shareJSON(collaborator.Username, "editor"))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = PerformAuthorizedRequest(s, s.generateJWT(collaborator), "GET", path, "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestSharedPostPermissions(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	owner := s.addTestUser()
+	token := s.generateJWT(owner)
+	post := s.addTestPost(owner)
+	collaborator := s.addTestUser2()
+	collaboratorToken := s.generateJWT(collaborator)
+	path := fmt.Sprintf("/api/v1/posts/%d", post.ID)
+	patch := `{"Title": "Gotham chronicles"}`
+
+	// Not shared
+	rec := PerformAuthorizedRequest(s, collaboratorToken, "PATCH", path, patch)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = PerformAuthorizedRequest(s, token, "PUT", path+"/collaborators", shareJSON(collaborator.Username, "viewer"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = PerformAuthorizedRequest(s, collaboratorToken, "PATCH", path, patch)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = PerformAuthorizedRequest(s, token, "PUT", path+"/collaborators", shareJSON(collaborator.Username, "editor"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = PerformAuthorizedRequest(s, collaboratorToken, "PATCH", path, patch)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Gotham chronicles", jsonFieldData(jsonRes(rec.Body), "Title"))
+	// Owner sees changes made by editors
+	events, err := s.events.FetchAfter(context.Background(), owner.ID, 0, 10)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, events) {
+		assert.Equal(t, "post.updated", events[len(events)-1].Name)
+	}
+	body := fmt.Sprintf(`{"Operations": [{"Op": "update", "ID": %d, "Title": "Gotham cronicles", "Content": "Joker is planning a big hit tonight."}]}`, post.ID)
+	rec = PerformAuthorizedRequest(s, collaboratorToken, "POST", "/api/v1/posts/bulk", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, float64(http.StatusOK), jsonDataSlice(rec.Body)[0]["Status"])
+
+	// Editors can't delete post
+	rec = PerformAuthorizedRequest(s, collaboratorToken, "DELETE", path, "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = PerformAuthorizedRequest(s, token, "DELETE", path, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = PerformAuthorizedRequest(s, collaboratorToken, "GET", "/api/v1/posts/shared", "")
+	assert.Empty(t, jsonDataSlice(rec.Body))
+}
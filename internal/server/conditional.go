@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// versionETag returns strong entity tag of resource version.
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatch reports whether If-Match header value matches entity tag. Missing
+// header matches everything. Weak tags never match, as If-Match requires
+// strong comparison.
+func ifMatch(header, etag string) bool {
+	if header == "" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified reports whether response client has cached is still fresh.
+// If-None-Match takes precedence over If-Modified-Since, and uses weak
+// comparison of entity tags.
+func notModified(req *http.Request, etag string, lastModified time.Time) bool {
+	if header := req.Header.Get("If-None-Match"); header != "" {
+		for _, tag := range strings.Split(header, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified.IsZero() {
+		return false
+	}
+	// Header has only second precision
+	return !lastModified.Truncate(time.Second).After(since)
+}
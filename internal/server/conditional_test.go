@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIfMatch(t *testing.T) {
+	assert.True(t, ifMatch("", `"1"`))
+	assert.True(t, ifMatch(`"1"`, `"1"`))
+	assert.True(t, ifMatch(`"2", "1"`, `"1"`))
+	assert.True(t, ifMatch("*", `"1"`))
+	assert.False(t, ifMatch(`"2"`, `"1"`))
+	assert.False(t, ifMatch(`W/"1"`, `"1"`))
+}
+
+func TestNotModified(t *testing.T) {
+	lastModified := time.Date(2021, time.October, 1, 12, 0, 0, 500, time.UTC)
+	tests := []struct {
+		header, value string
+		expected      bool
+	}{
+		{"", "", false},
+		{"If-None-Match", `W/"abc"`, true},
+		{"If-None-Match", `"abc"`, true},
+		{"If-None-Match", `"def", W/"abc"`, true},
+		{"If-None-Match", "*", true},
+		{"If-None-Match", `"def"`, false},
+		{"If-Modified-Since", "Fri, 01 Oct 2021 12:00:00 GMT", true},
+		{"If-Modified-Since", "Fri, 01 Oct 2021 13:00:00 GMT", true},
+		{"If-Modified-Since", "Fri, 01 Oct 2021 11:59:59 GMT", false},
+		{"If-Modified-Since", "not a date", false},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", "/feed.rss", nil)
+		if test.header != "" {
+			req.Header.Set(test.header, test.value)
+		}
+		assert.Equal(t, test.expected, notModified(req, `W/"abc"`, lastModified), "%s: %s", test.header, test.value)
+	}
+
+	// If-None-Match takes precedence
+	req, _ := http.NewRequest("GET", "/feed.rss", nil)
+	req.Header.Set("If-None-Match", `"def"`)
+	req.Header.Set("If-Modified-Since", "Fri, 01 Oct 2021 13:00:00 GMT")
+	assert.False(t, notModified(req, `W/"abc"`, lastModified))
+}
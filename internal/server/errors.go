@@ -0,0 +1,232 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"rgb/internal/conf"
+	"rgb/internal/store"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Machine readable error codes returned in "code" field of error responses.
+const (
+	CodeValidation   = "validation_failed"
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	// Resource changed since client fetched it
+	CodePreconditionFailed = "precondition_failed"
+	// Request body is not in format route accepts
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeInternal             = "internal_error"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	// Prefix of problem type URI, followed by error code
+	problemTypePrefix = "urn:rgb:problem:"
+)
+
+// apiError is error with everything needed to write error response.
+type apiError struct {
+	status int
+	code   string
+	// English message, translated when response is written
+	message string
+	args    []interface{}
+	// Translated messages for request fields which are not valid
+	fields map[string]string
+}
+
+func (e *apiError) Error() string {
+	if len(e.args) == 0 {
+		return e.message
+	}
+	return fmt.Sprintf(e.message, e.args...)
+}
+
+func newAPIError(status int, code, message string) *apiError {
+	return &apiError{status: status, code: code, message: message}
+}
+
+// abortWithError stops handling the request, leaving writing error response
+// to customErrors middleware.
+func abortWithError(ctx *gin.Context, err error) {
+	_ = ctx.Error(err)
+	ctx.Abort()
+}
+
+// toAPIError maps error returned by handler to HTTP status and error code.
+func toAPIError(ctx *gin.Context, err *gin.Error) *apiError {
+	var apiErr *apiError
+	if errors.As(err.Err, &apiErr) {
+		return apiErr
+	}
+	if err.Type == gin.ErrorTypeBind {
+		return bindError(ctx, err.Err)
+	}
+
+	var storeErr *store.Error
+	field := ""
+	if errors.As(err.Err, &storeErr) {
+		field = storeErr.Field
+	}
+	switch {
+	case errors.Is(err.Err, store.ErrNotFound):
+		apiErr = newAPIError(http.StatusNotFound, CodeNotFound, err.Error())
+	case errors.Is(err.Err, store.ErrConflict) && field != "":
+		apiErr = newAPIError(http.StatusConflict, CodeConflict, "%s already exists.")
+		apiErr.args = []interface{}{translate(ctx, field)}
+	case errors.Is(err.Err, store.ErrConflict):
+		apiErr = newAPIError(http.StatusConflict, CodeConflict, err.Error())
+	case errors.Is(err.Err, store.ErrForbidden):
+		apiErr = newAPIError(http.StatusForbidden, CodeForbidden, err.Error())
+	case errors.Is(err.Err, store.ErrValidation):
+		apiErr = newAPIError(http.StatusBadRequest, CodeValidation, err.Error())
+	default:
+		logger(ctx).Error().Err(err.Err).Msg("Error handling request")
+		return newAPIError(http.StatusInternalServerError, CodeInternal, InternalServerError)
+	}
+	if field != "" {
+		apiErr.fields = map[string]string{field: translate(ctx, apiErr.message, apiErr.args...)}
+	}
+	return apiErr
+}
+
+func bindError(ctx *gin.Context, err error) *apiError {
+	apiErr := newAPIError(http.StatusBadRequest, CodeValidation, "Request body is not valid.")
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		apiErr.fields = make(map[string]string, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			apiErr.fields[fieldErr.Field()] = customValidationError(ctx, fieldErr)
+		}
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		apiErr.fields = map[string]string{typeErr.Field: translate(ctx, "%[1]s is not valid.", translate(ctx, typeErr.Field))}
+	}
+	return apiErr
+}
+
+// writeError writes error response in format client asked for in Accept
+// header, or in configured format if it didn't ask for any.
+func (s *Server) writeError(ctx *gin.Context, apiErr *apiError) {
+	ctx.Writer.Header().Add("Vary", "Accept")
+	if s.errorFormat(ctx) == conf.ErrorFormatProblem {
+		ctx.Header("Content-Type", problemContentType)
+		ctx.AbortWithStatusJSON(apiErr.status, problemBody(ctx, apiErr))
+		return
+	}
+	ctx.AbortWithStatusJSON(apiErr.status, errorBody(ctx, apiErr))
+}
+
+// errorFormat returns format of error response client prefers by Accept
+// header. Problem details and plain JSON are chosen by their quality, and
+// problem details win ties as the more specific type. Configured format is
+// used if client accepts neither explicitly.
+func (s *Server) errorFormat(ctx *gin.Context) string {
+	problemQ, jsonQ := -1.0, -1.0
+	for _, accepted := range strings.Split(ctx.GetHeader("Accept"), ",") {
+		params := strings.Split(accepted, ";")
+		mediaType := strings.TrimSpace(params[0])
+		q := 1.0
+		for _, param := range params[1:] {
+			name, value := param, ""
+			if i := strings.Index(param, "="); i >= 0 {
+				name, value = param[:i], param[i+1:]
+			}
+			if strings.EqualFold(strings.TrimSpace(name), "q") {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		switch {
+		case strings.EqualFold(mediaType, problemContentType):
+			problemQ = q
+		case strings.EqualFold(mediaType, binding.MIMEJSON):
+			jsonQ = q
+		}
+	}
+	switch {
+	case problemQ > 0 && problemQ >= jsonQ:
+		return conf.ErrorFormatProblem
+	case jsonQ > 0:
+		return conf.ErrorFormatLegacy
+	case problemQ == 0:
+		// Client refused problem details
+		return conf.ErrorFormatLegacy
+	}
+	return s.cfg.ErrorFormat
+}
+
+// problemBody returns RFC 7807 problem details, extended with error code,
+// request ID and field messages.
+func problemBody(ctx *gin.Context, apiErr *apiError) gin.H {
+	body := gin.H{
+		"type":       problemTypePrefix + apiErr.code,
+		"title":      http.StatusText(apiErr.status),
+		"status":     apiErr.status,
+		"detail":     translate(ctx, apiErr.message, apiErr.args...),
+		"instance":   ctx.Request.URL.Path,
+		"code":       apiErr.code,
+		"request_id": ctx.GetString(requestIDKey),
+	}
+	if len(apiErr.fields) > 0 {
+		body["errors"] = apiErr.fields
+	}
+	return body
+}
+
+// errorBody returns legacy error response envelope. For compatibility with
+// older clients, "error" holds field messages if there are any, and message
+// otherwise.
+func errorBody(ctx *gin.Context, apiErr *apiError) gin.H {
+	body := gin.H{
+		"error":      translate(ctx, apiErr.message, apiErr.args...),
+		"code":       apiErr.code,
+		"request_id": ctx.GetString(requestIDKey),
+	}
+	if len(apiErr.fields) > 0 {
+		body["error"] = apiErr.fields
+		body["fields"] = apiErr.fields
+	}
+	return body
+}
+
+func (s *Server) customErrors(ctx *gin.Context) {
+	ctx.Next()
+	// Response might have been written by handler even if it reported error
+	if len(ctx.Errors) == 0 || ctx.Writer.Size() > 0 {
+		return
+	}
+	s.writeError(ctx, toAPIError(ctx, ctx.Errors.Last()))
+}
+
+// bind is like gin.Bind, but leaves writing error response to customErrors,
+// so bind errors use the same envelope as all other errors.
+func bind(val interface{}) gin.HandlerFunc {
+	typ := reflect.TypeOf(val)
+	if typ.Kind() == reflect.Ptr {
+		panic("Bind struct can not be a pointer.")
+	}
+	return func(ctx *gin.Context) {
+		obj := reflect.New(typ).Interface()
+		if err := ctx.ShouldBind(obj); err != nil {
+			_ = ctx.Error(err).SetType(gin.ErrorTypeBind)
+			ctx.Abort()
+			return
+		}
+		ctx.Set(gin.BindKey, obj)
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"rgb/internal/conf"
+	"rgb/internal/store"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func errorsRouter(err error) *gin.Engine {
+	s := testSetup()
+	router := gin.New()
+	router.Use(requestID, s.customErrors)
+	router.GET("/", func(ctx *gin.Context) { abortWithError(ctx, err) })
+	return router
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	t.Parallel()
+	router := errorsRouter(newAPIError(http.StatusForbidden, CodeForbidden, "Not authorized."))
+
+	req := NewRequest(router, "GET", "/", "")
+	req.Header.Add(requestIDHeader, "request-1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "Not authorized.", jsonRes(rec.Body)["error"])
+	assert.Equal(t, CodeForbidden, jsonRes(rec.Body)["code"])
+	assert.Equal(t, "request-1", jsonRes(rec.Body)["request_id"])
+	assert.Nil(t, jsonRes(rec.Body)["fields"])
+}
+
+func TestErrorEnvelopeUnknownError(t *testing.T) {
+	t.Parallel()
+	router := errorsRouter(errors.New("connection reset"))
+
+	rec := performRequest(router, "GET", "/", "")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, InternalServerError, jsonRes(rec.Body)["error"])
+	assert.Equal(t, CodeInternal, jsonRes(rec.Body)["code"])
+}
+
+func TestErrorEnvelopeValidation(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+
+	rec := performRequest(s, "POST", "/api/signup", `{"Username": "batman"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, CodeValidation, jsonRes(rec.Body)["code"])
+	assert.Equal(t, "Password is required.", jsonFieldError(jsonRes(rec.Body), "Password"))
+	assert.Equal(t, "Password is required.", jsonRes(rec.Body)["fields"].(map[string]interface{})["Password"])
+	assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+}
+
+func TestErrorEnvelopeMalformedBody(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+
+	rec := performRequest(s, "POST", "/api/signup", `{"Username": `)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Request body is not valid.", jsonRes(rec.Body)["error"])
+}
+
+func TestProblemDetailsAccept(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+
+	req := NewRequest(s, "POST", "/api/signup", `{"Username": "batman"}`)
+	req.Header.Add("Accept", "application/problem+json, application/json;q=0.9")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	body := jsonRes(rec.Body)
+	assert.Equal(t, "urn:rgb:problem:validation_failed", body["type"])
+	assert.Equal(t, "Bad Request", body["title"])
+	assert.Equal(t, float64(http.StatusBadRequest), body["status"])
+	assert.Equal(t, "Request body is not valid.", body["detail"])
+	assert.Equal(t, "/api/signup", body["instance"])
+	assert.Equal(t, "Password is required.", body["errors"].(map[string]interface{})["Password"])
+	assert.Nil(t, body["error"])
+}
+
+func TestProblemDetailsConfig(t *testing.T) {
+	t.Parallel()
+	cfg := conf.NewConfig("dev")
+	cfg.ErrorFormat = conf.ErrorFormatProblem
+	s := New(cfg, store.NewMemoryRepositories())
+
+	rec := performRequest(s, "GET", "/api/posts", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "Authorization header missing.", jsonRes(rec.Body)["detail"])
+}
+
+func TestErrorFormatAccept(t *testing.T) {
+	t.Parallel()
+	cfg := conf.NewConfig("dev")
+	cfg.ErrorFormat = conf.ErrorFormatProblem
+	s := New(cfg, store.NewMemoryRepositories())
+
+	for accept, contentType := range map[string]string{
+		"":                             "application/problem+json",
+		"*/*":                          "application/problem+json",
+		"application/json":             "application/json; charset=utf-8",
+		"application/problem+json;q=0": "application/json; charset=utf-8",
+		"application/json, application/problem+json;q=0.9": "application/json; charset=utf-8",
+		"application/json;q=0.5, application/problem+json": "application/problem+json",
+	} {
+		req := NewRequest(s, "GET", "/api/posts", "")
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, contentType, rec.Header().Get("Content-Type"), accept)
+	}
+}
+
+func TestLocalizedErrors(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+
+	req := NewRequest(s, "POST", "/api/signup", `{"Username": "batman", "Password": "secret"}`)
+	req.Header.Add("Accept-Language", "hr-HR,hr;q=0.9,en;q=0.8")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "hr", rec.Header().Get("Content-Language"))
+	assert.Equal(t, "Polje Lozinka mora imati najmanje 7 znakova.", jsonFieldError(jsonRes(rec.Body), "Password"))
+
+	req = NewRequest(s, "POST", "/api/signup", userJSON(*user))
+	req.Header.Add("Accept-Language", "hr")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "Korisničko ime već postoji.", jsonFieldError(jsonRes(rec.Body), "Username"))
+
+	req = NewRequest(s, "POST", "/api/signin", `{"Username": "batman", "Password": "invalid"}`)
+	req.Header.Add("Accept-Language", "hr")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "Prijava nije uspjela.", jsonRes(rec.Body)["error"])
+}
@@ -0,0 +1,350 @@
+package server
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"rgb/internal/store"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// Version of export format, written to manifest
+	exportVersion = 1
+	// Directory of post files in export archive
+	exportPostsDir = "posts"
+	exportManifest = "manifest.json"
+
+	// Maximum size of uploaded import archive
+	maxImportSize = 10 << 20
+	// Maximum uncompressed size of single post file in import archive
+	maxImportPostSize = 64 << 10
+	// Maximum number of files in import archive
+	maxImportPosts = 1000
+)
+
+const frontMatterDelimiter = "---\n"
+
+// frontMatter is YAML header of exported Markdown post.
+type frontMatter struct {
+	Title      string    `yaml:"title"`
+	CreatedAt  time.Time `yaml:"created_at"`
+	ModifiedAt time.Time `yaml:"modified_at"`
+	Published  bool      `yaml:"published"`
+	// Not set for drafts
+	PublishedAt *time.Time `yaml:"published_at,omitempty"`
+	// Posts don't have tags, so they are always empty on export and ignored
+	// on import. Field is there so the format doesn't change once they do.
+	Tags []string `yaml:"tags"`
+}
+
+type manifest struct {
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Username   string         `json:"username"`
+	Posts      []manifestPost `json:"posts"`
+}
+
+type manifestPost struct {
+	File       string    `json:"file"`
+	ID         int       `json:"id"`
+	Title      string    `json:"title"`
+	CreatedAt  time.Time `json:"created_at"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+type importResult struct {
+	Imported int
+	// Files which weren't imported because user already has the same post
+	Duplicates []string
+}
+
+// exportPosts streams zip archive with user's posts as Markdown files, and
+// manifest listing them.
+func (s *Server) exportPosts(ctx *gin.Context) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.Header("Content-Type", "application/zip")
+	ctx.Header("Content-Disposition", `attachment; filename="posts.zip"`)
+	ctx.Status(http.StatusOK)
+
+	archive := zip.NewWriter(ctx.Writer)
+	m := manifest{Version: exportVersion, ExportedAt: time.Now().UTC(), Username: user.Username, Posts: []manifestPost{}}
+	err = s.posts.EachUserPost(ctx.Request.Context(), user, func(post *store.Post) error {
+		file := path.Join(exportPostsDir, fmt.Sprintf("%d-%s.md", post.ID, slug(post.Title)))
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: file, Method: zip.Deflate, Modified: post.ModifiedAt})
+		if err != nil {
+			return err
+		}
+		if err := writeMarkdown(w, post); err != nil {
+			return err
+		}
+		m.Posts = append(m.Posts, manifestPost{
+			File:       file,
+			ID:         post.ID,
+			Title:      post.Title,
+			CreatedAt:  post.CreatedAt,
+			ModifiedAt: post.ModifiedAt,
+		})
+		return nil
+	})
+	if err == nil {
+		err = writeManifest(archive, m)
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		if !ctx.Writer.Written() {
+			ctx.Writer.Header().Del("Content-Type")
+			ctx.Writer.Header().Del("Content-Disposition")
+			abortWithError(ctx, err)
+			return
+		}
+		// Status is already sent, so client is left with broken archive
+		logger(ctx).Error().Err(err).Msg("Error exporting posts")
+		ctx.Abort()
+	}
+}
+
+func writeManifest(archive *zip.Writer, m manifest) error {
+	w, err := archive.Create(exportManifest)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(m)
+}
+
+// writeMarkdown writes post as Markdown with YAML front matter.
+func writeMarkdown(w io.Writer, post *store.Post) error {
+	header, err := yaml.Marshal(frontMatter{
+		Title:       post.Title,
+		CreatedAt:   post.CreatedAt.UTC(),
+		ModifiedAt:  post.ModifiedAt.UTC(),
+		Published:   post.Published,
+		PublishedAt: post.PublishedAt,
+		Tags:        []string{},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s%s\n%s", frontMatterDelimiter, header, frontMatterDelimiter, post.Content)
+	return err
+}
+
+// parseMarkdown parses post written by writeMarkdown.
+func parseMarkdown(data []byte) (*store.Post, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if !strings.HasPrefix(text, frontMatterDelimiter) {
+		return nil, errors.New("front matter missing")
+	}
+	text = text[len(frontMatterDelimiter):]
+	end := strings.Index(text, "\n"+frontMatterDelimiter)
+	if end < 0 {
+		return nil, errors.New("front matter not closed")
+	}
+	var header frontMatter
+	if err := yaml.Unmarshal([]byte(text[:end+1]), &header); err != nil {
+		return nil, err
+	}
+	content := strings.TrimPrefix(text[end+1+len(frontMatterDelimiter):], "\n")
+	return &store.Post{
+		Title:       header.Title,
+		Content:     content,
+		CreatedAt:   header.CreatedAt,
+		ModifiedAt:  header.ModifiedAt,
+		Published:   header.Published,
+		PublishedAt: header.PublishedAt,
+	}, nil
+}
+
+// slug returns title lowercased, with every run of characters which aren't
+// letters or digits replaced by a dash.
+func slug(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	if b.Len() == 0 {
+		return "post"
+	}
+	return b.String()
+}
+
+// importPosts creates posts from Markdown files in uploaded zip archive, in
+// the format written by exportPosts. Posts with the same title and content as
+// already existing ones are skipped. Nothing is imported if any file is not
+// valid.
+func (s *Server) importPosts(ctx *gin.Context) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	// Leaves room for multipart headers, so too large files can be reported
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportSize+1<<20)
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		abortWithError(ctx, newAPIError(http.StatusBadRequest, CodeValidation, "Import file is missing."))
+		return
+	}
+	if fileHeader.Size > maxImportSize {
+		apiErr := newAPIError(http.StatusBadRequest, CodeValidation, "Import file cannot be larger than %d MB.")
+		apiErr.args = []interface{}{maxImportSize >> 20}
+		abortWithError(ctx, apiErr)
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	defer file.Close()
+	archive, err := zip.NewReader(file, fileHeader.Size)
+	if err != nil {
+		abortWithError(ctx, newAPIError(http.StatusBadRequest, CodeValidation, "Import file is not valid."))
+		return
+	}
+
+	files, posts, fields, err := readImportedPosts(ctx, archive)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	if len(fields) > 0 {
+		apiErr := newAPIError(http.StatusBadRequest, CodeValidation, "Import file is not valid.")
+		apiErr.fields = fields
+		abortWithError(ctx, apiErr)
+		return
+	}
+
+	var result importResult
+	err = s.tx.WithTx(ctx.Request.Context(), func(txCtx context.Context) error {
+		result = importResult{Duplicates: []string{}}
+		existing := map[string]bool{}
+		err := s.posts.EachUserPost(txCtx, user, func(post *store.Post) error {
+			existing[postKey(post)] = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for i, post := range posts {
+			if existing[postKey(post)] {
+				result.Duplicates = append(result.Duplicates, files[i])
+				continue
+			}
+			imported := *post
+			err := s.withEvent(txCtx, user, store.EventPostCreated, &imported, func(ctx context.Context) error {
+				return s.posts.Add(ctx, user, &imported)
+			})
+			if err != nil {
+				return err
+			}
+			existing[postKey(post)] = true
+			result.Imported++
+		}
+		return nil
+	})
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  translate(ctx, "Posts imported successfully."),
+		"data": result,
+	})
+}
+
+// readImportedPosts parses and validates Markdown files from archive,
+// ordered by file name. Messages of files which are not valid are returned
+// by file name. Archives with too many files are rejected before any of
+// them is read.
+func readImportedPosts(ctx *gin.Context, archive *zip.Reader) ([]string, []*store.Post, map[string]string, error) {
+	if len(archive.File) > maxImportPosts {
+		apiErr := newAPIError(http.StatusBadRequest, CodeValidation, "Import file cannot have more than %d files.")
+		apiErr.args = []interface{}{maxImportPosts}
+		return nil, nil, nil, apiErr
+	}
+	sorted := make([]*zip.File, 0, len(archive.File))
+	for _, file := range archive.File {
+		if !file.FileInfo().IsDir() && path.Ext(file.Name) == ".md" {
+			sorted = append(sorted, file)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var files []string
+	var posts []*store.Post
+	fields := map[string]string{}
+	for _, file := range sorted {
+		post, err := readImportedPost(file)
+		var validationErrs validator.ValidationErrors
+		switch {
+		case errors.As(err, &validationErrs):
+			fields[file.Name] = customValidationError(ctx, validationErrs[0])
+		case err != nil:
+			fields[file.Name] = translate(ctx, "File is not valid Markdown with front matter.")
+		default:
+			files = append(files, file.Name)
+			posts = append(posts, post)
+		}
+	}
+	return files, posts, fields, nil
+}
+
+func readImportedPost(file *zip.File) (*store.Post, error) {
+	r, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxImportPostSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImportPostSize {
+		return nil, errors.New("post file too large")
+	}
+	post, err := parseMarkdown(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := binding.Validator.ValidateStruct(post); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+// postKey identifies post by its title and content for duplicate detection.
+func postKey(post *store.Post) string {
+	return post.Title + "\x00" + post.Content
+}
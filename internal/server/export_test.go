@@ -0,0 +1,184 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"rgb/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func performImportRequest(router http.Handler, token string, archive []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, _ := form.CreateFormFile("file", "posts.zip")
+	_, _ = file.Write(archive)
+	_ = form.Close()
+
+	req := NewRequest(router, "POST", "/api/me/import", body.String())
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Add("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func zipArchive(files map[string]string) []byte {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, _ := archive.Create(name)
+		_, _ = w.Write([]byte(content))
+	}
+	_ = archive.Close()
+	return buf.Bytes()
+}
+
+func TestExportPosts(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
+
+	rec := PerformAuthorizedRequest(s, token, "GET", "/api/v1/me/export", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	assert.NoError(t, err)
+	files := map[string][]byte{}
+	for _, file := range archive.File {
+		r, err := file.Open()
+		assert.NoError(t, err)
+		files[file.Name], _ = ioutil.ReadAll(r)
+	}
+
+	var m manifest
+	assert.NoError(t, json.Unmarshal(files["manifest.json"], &m))
+	assert.Equal(t, exportVersion, m.Version)
+	assert.Equal(t, user.Username, m.Username)
+	if assert.Len(t, m.Posts, 1) {
+		assert.Equal(t, "posts/1-gotham-cronicles.md", m.Posts[0].File)
+		assert.Equal(t, post.Title, m.Posts[0].Title)
+	}
+
+	exported, err := parseMarkdown(files["posts/1-gotham-cronicles.md"])
+	assert.NoError(t, err)
+	assert.Equal(t, post.Title, exported.Title)
+	assert.Equal(t, post.Content, exported.Content)
+	assert.True(t, post.CreatedAt.Equal(exported.CreatedAt))
+	assert.Contains(t, string(files["posts/1-gotham-cronicles.md"]), "tags: []\n")
+}
+
+func TestImportPosts(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	s.addTestPost(user)
+
+	rec := PerformAuthorizedRequest(s, token, "GET", "/api/me/export", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	exported := rec.Body.Bytes()
+
+	// Importing own export only finds duplicates
+	rec = performImportRequest(s, token, exported)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Posts imported successfully.", jsonRes(rec.Body)["msg"])
+	assert.Equal(t, float64(0), jsonFieldData(jsonRes(rec.Body), "Imported"))
+	assert.Equal(t, []interface{}{"posts/1-gotham-cronicles.md"}, jsonFieldData(jsonRes(rec.Body), "Duplicates"))
+
+	user2 := s.addTestUser2()
+	token2 := s.generateJWT(user2)
+	rec = performImportRequest(s, token2, exported)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, float64(1), jsonFieldData(jsonRes(rec.Body), "Imported"))
+
+	// Imported posts keep their publication time
+	published := zipArchive(map[string]string{"posts/1-joker.md": "---\ntitle: Joker\npublished: true\npublished_at: 2021-10-01T12:00:00Z\n---\n\nWhy so serious?\n"})
+	rec = performImportRequest(s, token2, published)
+	assert.Equal(t, float64(1), jsonFieldData(jsonRes(rec.Body), "Imported"))
+
+	rec = PerformAuthorizedRequest(s, token2, "GET", "/api/posts", "")
+	posts := jsonDataSlice(rec.Body)
+	if assert.Len(t, posts, 2) {
+		assert.Equal(t, "Gotham cronicles", posts[0]["Title"])
+		assert.Equal(t, "Joker is planning a big hit tonight.", posts[0]["Content"])
+		assert.Equal(t, "2021-10-01T12:00:00Z", posts[1]["PublishedAt"])
+	}
+}
+
+func TestImportPostsNotValid(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+
+	archive := zipArchive(map[string]string{
+		"posts/1-valid.md":   "---\ntitle: Valid post\n---\n\nJoker is planning a big hit tonight.",
+		"posts/2-short.md":   "---\ntitle: Go\n---\n\nJoker is planning a big hit tonight.",
+		"posts/3-no-yaml.md": "Joker is planning a big hit tonight.",
+		"manifest.json":      "{}",
+	})
+	rec := performImportRequest(s, token, archive)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	res := jsonRes(rec.Body)
+	assert.Equal(t, "Title must be longer than or equal 3 characters.", jsonFieldError(res, "posts/2-short.md"))
+	assert.Equal(t, "File is not valid Markdown with front matter.", jsonFieldError(res, "posts/3-no-yaml.md"))
+
+	rec = PerformAuthorizedRequest(s, token, "GET", "/api/posts", "")
+	assert.Empty(t, jsonDataSlice(rec.Body))
+
+	rec = performImportRequest(s, token, []byte("not a zip"))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Import file is not valid.", jsonRes(rec.Body)["error"])
+}
+
+func TestImportTooManyPosts(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+
+	files := map[string]string{}
+	for i := 0; i <= maxImportPosts; i++ {
+		files[fmt.Sprintf("posts/%d-post.md", i)] = "---\ntitle: Valid post\n---\n\nJoker is planning a big hit tonight."
+	}
+	rec := performImportRequest(s, token, zipArchive(files))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Import file cannot have more than 1000 files.", jsonRes(rec.Body)["error"])
+}
+
+func TestMarkdown(t *testing.T) {
+	created := time.Date(2021, time.October, 1, 12, 0, 0, 0, time.UTC)
+	post := &store.Post{
+		Title:      "Title: with --- \"quotes\"",
+		Content:    "---\nContent\n\nwith delimiter\n",
+		CreatedAt:  created,
+		ModifiedAt: created.Add(time.Hour),
+		Published:  true,
+	}
+	publishedAt := created.Add(2 * time.Hour)
+	post.PublishedAt = &publishedAt
+	var buf bytes.Buffer
+	assert.NoError(t, writeMarkdown(&buf, post))
+	parsed, err := parseMarkdown(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, post, parsed)
+}
+
+func TestSlug(t *testing.T) {
+	assert.Equal(t, "gotham-cronicles", slug("Gotham cronicles"))
+	assert.Equal(t, "joker-s-plan-2", slug("  Joker's plan #2!"))
+	assert.Equal(t, "čudo", slug("Čudo"))
+	assert.Equal(t, "post", slug("!!!"))
+}
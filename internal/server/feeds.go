@@ -0,0 +1,163 @@
+package server
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"rgb/internal/feed"
+	"rgb/internal/store"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Number of most recent posts in feeds
+const feedSize = 20
+
+type feedFormat struct {
+	name        string
+	contentType string
+	write       func(io.Writer, feed.Feed) error
+}
+
+var (
+	rssFormat  = feedFormat{name: "rss", contentType: feed.RSSContentType, write: feed.WriteRSS}
+	atomFormat = feedFormat{name: "atom", contentType: feed.AtomContentType, write: feed.WriteAtom}
+)
+
+// userFeed returns handler of feed with user's published posts.
+func (s *Server) userFeed(format feedFormat) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, err := s.users.FetchByUsername(ctx.Request.Context(), ctx.Param("username"))
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		posts, err := s.posts.FetchPublished(ctx.Request.Context(), user.ID, feedSize)
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		writeFeed(ctx, format, feed.Feed{
+			Title:       translate(ctx, "Posts by %s", user.Username),
+			Description: translate(ctx, "Recent public posts by %s", user.Username),
+		}, posts, map[int]string{user.ID: user.Username})
+	}
+}
+
+// siteFeed returns handler of feed with published posts of all users.
+func (s *Server) siteFeed(format feedFormat) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		posts, err := s.posts.FetchPublished(ctx.Request.Context(), 0, feedSize)
+		if err != nil {
+			abortWithError(ctx, err)
+			return
+		}
+		authors := map[int]string{}
+		for _, post := range posts {
+			if _, ok := authors[post.UserID]; ok {
+				continue
+			}
+			user, err := s.users.Fetch(ctx.Request.Context(), post.UserID)
+			if err != nil {
+				abortWithError(ctx, err)
+				return
+			}
+			authors[user.ID] = user.Username
+		}
+		writeFeed(ctx, format, feed.Feed{
+			Title:       translate(ctx, "Recent posts"),
+			Description: translate(ctx, "Recent public posts"),
+		}, posts, authors)
+	}
+}
+
+// writeFeed writes feed of posts, or only Not Modified status if client's
+// cached feed is still fresh.
+func writeFeed(ctx *gin.Context, format feedFormat, f feed.Feed, posts []*store.Post, authors map[int]string) {
+	// Unpublishing or deleting post doesn't advance Last-Modified, but
+	// clients which send If-None-Match are told about it by ETag, which
+	// takes precedence
+	etag := feedETag(format, requestLocale(ctx).String(), posts)
+	ctx.Header("ETag", etag)
+	lastModified := feedLastModified(posts)
+	if !lastModified.IsZero() {
+		ctx.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(ctx.Request, etag, lastModified) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	base := baseURL(ctx.Request)
+	f.Self = base + ctx.Request.URL.Path
+	f.ID = f.Self
+	f.Link = base + "/"
+	for _, post := range posts {
+		if post.ModifiedAt.After(f.Updated) {
+			f.Updated = post.ModifiedAt
+		}
+	}
+	if f.Updated.IsZero() {
+		f.Updated = time.Now()
+	}
+	host, _, err := net.SplitHostPort(ctx.Request.Host)
+	if err != nil {
+		host = ctx.Request.Host
+	}
+	for _, post := range posts {
+		f.Items = append(f.Items, feed.Item{
+			ID:        fmt.Sprintf("tag:%s,2021:posts/%d", host, post.ID),
+			Title:     post.Title,
+			Content:   post.Content,
+			Author:    authors[post.UserID],
+			Published: *post.PublishedAt,
+			Updated:   post.ModifiedAt,
+		})
+	}
+	ctx.Header("Content-Type", format.contentType)
+	ctx.Status(http.StatusOK)
+	if err := format.write(ctx.Writer, f); err != nil {
+		logger(ctx).Error().Err(err).Msg("Error writing feed")
+	}
+}
+
+// feedETag returns weak entity tag which changes whenever any of the posts is
+// added, changed or removed, or feed is translated to other locale.
+func feedETag(format feedFormat, locale string, posts []*store.Post) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%s|%s|%d", format.name, locale, len(posts))
+	for _, post := range posts {
+		fmt.Fprintf(hash, "|%d:%d:%d", post.ID, post.Version, post.ModifiedAt.UnixNano())
+	}
+	return `W/"` + hex.EncodeToString(hash.Sum(nil)) + `"`
+}
+
+// feedLastModified returns the most recent publication or modification time
+// of the posts, zero time if there are none.
+func feedLastModified(posts []*store.Post) time.Time {
+	var last time.Time
+	for _, post := range posts {
+		if post.ModifiedAt.After(last) {
+			last = post.ModifiedAt
+		}
+		if post.PublishedAt != nil && post.PublishedAt.After(last) {
+			last = *post.PublishedAt
+		}
+	}
+	return last
+}
+
+// baseURL returns scheme and host request was sent to, taking into account
+// TLS terminating proxies.
+func baseURL(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil || strings.EqualFold(req.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"rgb/internal/store"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *Server) addPublishedTestPost(user *store.User) *store.Post {
+	post := &store.Post{
+		Title:     "Published post",
+		Content:   "Everyone can read it.",
+		Published: true,
+	}
+	if err := s.posts.Add(context.Background(), user, post); err != nil {
+		panic(err)
+	}
+	return post
+}
+
+func performFeedRequest(router http.Handler, path string, header http.Header) *httptest.ResponseRecorder {
+	req := NewRequest(router, "GET", path, "")
+	req.Host = "example.com"
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestUserFeed(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	s.addTestPost(user)
+	post := s.addPublishedTestPost(user)
+	s.addPublishedTestPost(s.addTestUser2())
+
+	rec := performFeedRequest(s, "/users/batman/feed.rss", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/rss+xml; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.NotEmpty(t, rec.Header().Get("ETag"))
+	assert.NotEmpty(t, rec.Header().Get("Last-Modified"))
+
+	var rss struct {
+		Channel struct {
+			Title string `xml:"title"`
+			Items []struct {
+				Title string `xml:"title"`
+				GUID  string `xml:"guid"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	assert.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &rss))
+	assert.Equal(t, "Posts by batman", rss.Channel.Title)
+	if assert.Len(t, rss.Channel.Items, 1) {
+		assert.Equal(t, post.Title, rss.Channel.Items[0].Title)
+		assert.Equal(t, "tag:example.com,2021:posts/2", rss.Channel.Items[0].GUID)
+	}
+
+	rec = performFeedRequest(s, "/users/joker/feed.rss", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestSiteFeed(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	s.addPublishedTestPost(s.addTestUser())
+	s.addPublishedTestPost(s.addTestUser2())
+
+	rec := performFeedRequest(s, "/feed.atom", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	var atom struct {
+		ID      string `xml:"id"`
+		Entries []struct {
+			Author string `xml:"author>name"`
+		} `xml:"entry"`
+	}
+	assert.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &atom))
+	assert.Equal(t, "http://example.com/feed.atom", atom.ID)
+	if assert.Len(t, atom.Entries, 2) {
+		// Most recent first
+		assert.Equal(t, "superman", atom.Entries[0].Author)
+		assert.Equal(t, "batman", atom.Entries[1].Author)
+	}
+}
+
+func TestFeedConditionalGet(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addPublishedTestPost(user)
+	other := s.addPublishedTestPost(user)
+
+	rec := performFeedRequest(s, "/feed.rss", nil)
+	etag := rec.Header().Get("ETag")
+	lastModified := rec.Header().Get("Last-Modified")
+
+	rec = performFeedRequest(s, "/feed.rss", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	rec = performFeedRequest(s, "/feed.rss", http.Header{"If-Modified-Since": {lastModified}})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	// Entity tag takes precedence
+	rec = performFeedRequest(s, "/feed.rss", http.Header{"If-None-Match": {`W/"other"`}, "If-Modified-Since": {lastModified}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	// Atom feed of the same posts has different entity tag
+	rec = performFeedRequest(s, "/feed.atom", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	// So does feed translated to other locale
+	rec = performFeedRequest(s, "/feed.rss", http.Header{"If-None-Match": {etag}, "Accept-Language": {"hr"}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+
+	// Deleting post changes the feed
+	rec = PerformAuthorizedRequest(s, token, "DELETE", "/api/posts/"+fmt.Sprint(other.ID), "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = performFeedRequest(s, "/feed.rss", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+	etag = rec.Header().Get("ETag")
+
+	// Unpublishing post changes the feed
+	rec = performPatchRequest(s, token, "/api/posts/"+fmt.Sprint(post.ID), `{"Published": false}`, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = performFeedRequest(s, "/feed.rss", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+}
+
+func TestFeedKeepsEditedPosts(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addPublishedTestPost(user)
+
+	// Clients which don't send published flag don't unpublish post
+	body := fmt.Sprintf(`{"ID": %d, "Title": "Edited with PUT", "Content": "Everyone can read it."}`, post.ID)
+	rec := PerformAuthorizedRequest(s, token, "PUT", "/api/posts", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = performFeedRequest(s, "/feed.rss", nil)
+	assert.Contains(t, rec.Body.String(), "Edited with PUT")
+
+	body = fmt.Sprintf(`{"Operations": [{"Op": "update", "ID": %d, "Title": "Edited in bulk", "Content": "Everyone can read it."}]}`, post.ID)
+	rec = PerformAuthorizedRequest(s, token, "POST", "/api/posts/bulk", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = performFeedRequest(s, "/feed.rss", nil)
+	assert.Contains(t, rec.Body.String(), "Edited in bulk")
+}
+
+func TestFeedOrderedByPublication(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	draft := s.addTestPost(user)
+	s.addPublishedTestPost(user)
+
+	// Older post published later is the most recent one in feed
+	rec := performPatchRequest(s, token, "/api/posts/"+fmt.Sprint(draft.ID), `{"Published": true}`, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = performFeedRequest(s, "/feed.rss", nil)
+	var rss struct {
+		Items []struct {
+			Title string `xml:"title"`
+		} `xml:"channel>item"`
+	}
+	assert.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &rss))
+	if assert.Len(t, rss.Items, 2) {
+		assert.Equal(t, draft.Title, rss.Items[0].Title)
+	}
+}
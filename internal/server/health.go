@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const readinessCheckTimeout = 2 * time.Second
+
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// AddReadinessCheck registers dependency which must be available for server
+// to receive traffic, e.g. database.
+func (s *Server) AddReadinessCheck(name string, check func(ctx context.Context) error) {
+	s.readinessChecks = append(s.readinessChecks, readinessCheck{name: name, check: check})
+}
+
+// setShuttingDown fails readiness checks and ends event streams, since they
+// would otherwise keep shutdown waiting.
+func (s *Server) setShuttingDown() {
+	atomic.StoreInt32(&s.shuttingDown, 1)
+	s.stopStreams()
+}
+
+func (s *Server) isShuttingDown() bool { return atomic.LoadInt32(&s.shuttingDown) == 1 }
+
+// liveness reports that process is running and able to handle requests.
+func liveness(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readiness reports whether server should receive traffic. It fails if any
+// of registered checks fails or server is shutting down.
+func (s *Server) readiness(ctx *gin.Context) {
+	checkCtx, cancel := context.WithTimeout(ctx.Request.Context(), readinessCheckTimeout)
+	defer cancel()
+
+	ready := true
+	checks := gin.H{"shutdown": "ok"}
+	if s.isShuttingDown() {
+		ready = false
+		checks["shutdown"] = "Server is shutting down."
+	}
+	for _, readinessCheck := range s.readinessChecks {
+		if err := readinessCheck.check(checkCtx); err != nil {
+			ready = false
+			checks[readinessCheck.name] = err.Error()
+			continue
+		}
+		checks[readinessCheck.name] = "ok"
+	}
+
+	if !ready {
+		logger(ctx).Warn().Interface("checks", checks).Msg("Readiness check failed")
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "checks": checks})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ready", "checks": checks})
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiveness(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+
+	rec := performRequest(s, "GET", "/healthz", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ok", jsonRes(rec.Body)["status"])
+}
+
+func TestReadiness(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	s.AddReadinessCheck("database", func(context.Context) error { return nil })
+
+	rec := performRequest(s, "GET", "/readyz", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ready", jsonRes(rec.Body)["status"])
+	assert.Equal(t, "ok", jsonRes(rec.Body)["checks"].(map[string]interface{})["database"])
+}
+
+func TestReadinessCheckFailed(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	s.AddReadinessCheck("database", func(context.Context) error { return errors.New("connection refused") })
+
+	rec := performRequest(s, "GET", "/readyz", "")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "not ready", jsonRes(rec.Body)["status"])
+	assert.Equal(t, "connection refused", jsonRes(rec.Body)["checks"].(map[string]interface{})["database"])
+}
+
+func TestReadinessShuttingDown(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	s.setShuttingDown()
+
+	rec := performRequest(s, "GET", "/readyz", "")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "not ready", jsonRes(rec.Body)["status"])
+	assert.Equal(t, "Server is shutting down.", jsonRes(rec.Body)["checks"].(map[string]interface{})["shutdown"])
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"context"
+	"rgb/internal/jobs"
+	"rgb/internal/store"
+	"rgb/internal/webhooks"
+	"time"
+)
+
+// Finished jobs are kept this long, so failures can be inspected
+const jobRetention = 7 * 24 * time.Hour
+
+// Type of job deleting events which are too old to be resumed
+const purgeEventsJob = "events.purge"
+
+// newJobRunner returns runner with handlers of every background job type
+// registered.
+func (s *Server) newJobRunner() *jobs.Runner {
+	runner := jobs.NewRunner(s.jobs)
+	deliverer := webhooks.NewDeliverer(s.webhooks)
+	deliverer.AllowPrivate = s.cfg.WebhooksAllowPrivate
+	deliverer.Register(runner)
+	runner.Register(jobs.PurgeType, jobs.Purge(s.jobs, jobRetention), jobs.Options{})
+	runner.Every(jobs.PurgeType, 24*time.Hour)
+	runner.Register(purgeEventsJob, func(ctx context.Context, job *store.Job) error {
+		_, err := s.events.Purge(ctx, time.Now().Add(-eventRetention))
+		return err
+	}, jobs.Options{})
+	runner.Every(purgeEventsJob, time.Hour)
+	// Every scheduled post has its own job, recurring one catches posts
+	// whose job failed
+	runner.Register(publishPostsJob, s.publishDue, jobs.Options{MaxAttempts: 10})
+	runner.Every(publishPostsJob, 10*time.Minute)
+	return runner
+}
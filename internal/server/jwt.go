@@ -1,10 +1,11 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"rgb/internal/conf"
+	"rgb/internal/logging"
 	"rgb/internal/store"
 	"strconv"
 	"time"
@@ -13,32 +14,27 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-var (
-	jwtSigner   jwt.Signer
-	jwtVerifier jwt.Verifier
-)
-
-func jwtSetup(conf conf.Config) {
+func (s *Server) jwtSetup() {
 	var err error
-	key := []byte(conf.JwtSecret)
+	key := []byte(s.cfg.JwtSecret)
 
-	jwtSigner, err = jwt.NewSignerHS(jwt.HS256, key)
+	s.jwtSigner, err = jwt.NewSignerHS(jwt.HS256, key)
 	if err != nil {
 		log.Panic().Err(err).Msg("Error creating JWT signer")
 	}
 
-	jwtVerifier, err = jwt.NewVerifierHS(jwt.HS256, key)
+	s.jwtVerifier, err = jwt.NewVerifierHS(jwt.HS256, key)
 	if err != nil {
 		log.Panic().Err(err).Msg("Error creating JWT verifier")
 	}
 }
 
-func generateJWT(user *store.User) string {
+func (s *Server) generateJWT(user *store.User) string {
 	claims := &jwt.RegisteredClaims{
 		ID:        fmt.Sprint(user.ID),
 		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 7)),
 	}
-	builder := jwt.NewBuilder(jwtSigner)
+	builder := jwt.NewBuilder(s.jwtSigner)
 	token, err := builder.Build(claims)
 	if err != nil {
 		log.Panic().Err(err).Msg("Error building JWT")
@@ -46,14 +42,15 @@ func generateJWT(user *store.User) string {
 	return token.String()
 }
 
-func verifyJWT(tokenStr string) (int, error) {
+func (s *Server) verifyJWT(ctx context.Context, tokenStr string) (int, error) {
+	log := logging.Module(ctx, logging.JWT)
 	token, err := jwt.Parse([]byte(tokenStr))
 	if err != nil {
 		log.Error().Err(err).Str("tokenStr", tokenStr).Msg("Error parsing JWT")
 		return 0, err
 	}
 
-	if err := jwtVerifier.Verify(token.Payload(), token.Signature()); err != nil {
+	if err := s.jwtVerifier.Verify(token.Payload(), token.Signature()); err != nil {
 		log.Error().Err(err).Msg("Error verifying token")
 		return 0, err
 	}
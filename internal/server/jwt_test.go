@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"rgb/internal/conf"
 	"testing"
 
@@ -8,38 +9,42 @@ import (
 )
 
 func TestJwtSetup(t *testing.T) {
-	_ = testSetup()
-	assert.NotPanics(t, func() { jwtSetup(conf.NewConfig("dev")) })
-	assert.NotNil(t, jwtSigner)
-	assert.NotNil(t, jwtVerifier)
+	t.Parallel()
+	s := &Server{cfg: conf.NewConfig("dev")}
+	assert.NotPanics(t, func() { s.jwtSetup() })
+	assert.NotNil(t, s.jwtSigner)
+	assert.NotNil(t, s.jwtVerifier)
 }
 
 func TestGenerateJWT(t *testing.T) {
-	_ = testSetup()
-	user := addTestUser()
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
 
-	token := generateJWT(user)
+	token := s.generateJWT(user)
 	assert.NotEmpty(t, token)
 }
 
 func TestVerifyJWT(t *testing.T) {
-	_ = testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
 	assert.NotEmpty(t, token)
 
-	userID, err := verifyJWT(token)
+	userID, err := s.verifyJWT(context.Background(), token)
 	assert.NoError(t, err)
 	assert.Equal(t, user.ID, userID)
 }
 
 func TestVerifyInvalidJWT(t *testing.T) {
-	_ = testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
 	assert.NotEmpty(t, token)
 
-	userID, err := verifyJWT(token + "invalid")
+	userID, err := s.verifyJWT(context.Background(), token+"invalid")
 	assert.Error(t, err)
 	assert.Equal(t, 0, userID)
 }
@@ -2,67 +2,81 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"rgb/internal/conf"
+	"rgb/internal/logging"
 	"rgb/internal/store"
 	"strings"
+	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 )
 
-func testSetup() *gin.Engine {
+func TestMain(m *testing.M) {
 	gin.SetMode(gin.TestMode)
-	store.ResetTestDatabase()
+	logging.ConfigureLogger(conf.NewConfig("dev"))
+	os.Exit(m.Run())
+}
+
+// testSetup returns server backed by in-memory repositories, so every test
+// has its own data and tests can run in parallel.
+func testSetup() *Server {
+	return testSetupWithAdmins()
+}
+
+func testSetupWithAdmins(admins ...string) *Server {
 	cfg := conf.NewConfig("dev")
-	jwtSetup(cfg)
-	return setRouter(cfg)
+	cfg.Admins = admins
+	return New(cfg, store.NewMemoryRepositories())
 }
 
-func addTestUser() *store.User {
+func (s *Server) addTestUser() *store.User {
 	user := &store.User{
 		Username: "batman",
 		Password: "secret123",
 	}
-	err := store.AddUser(user)
+	err := s.users.Add(context.Background(), user)
 	if err != nil {
 		log.Panic().Err(err).Msg("Error adding test user.")
 	}
 	return user
 }
 
-func addTestUser2() *store.User {
+func (s *Server) addTestUser2() *store.User {
 	user := &store.User{
 		Username: "superman",
 		Password: "secret123",
 	}
-	err := store.AddUser(user)
+	err := s.users.Add(context.Background(), user)
 	if err != nil {
 		log.Panic().Err(err).Msg("Error adding test user.")
 	}
 	return user
 }
 
-func addTestPost(user *store.User) *store.Post {
+func (s *Server) addTestPost(user *store.User) *store.Post {
 	post := &store.Post{
 		Title:   "Gotham cronicles",
 		Content: "Joker is planning a big hit tonight.",
 	}
-	err := store.AddPost(user, post)
+	err := s.posts.Add(context.Background(), user, post)
 	if err != nil {
 		log.Panic().Err(err).Msg("Error adding test post.")
 	}
 	return post
 }
 
-func addTestPost2(user *store.User) *store.Post {
+func (s *Server) addTestPost2(user *store.User) *store.Post {
 	post := &store.Post{
 		Title:   "Justice league meeting",
 		Content: "Darkseid is plotting again.",
 	}
-	err := store.AddPost(user, post)
+	err := s.posts.Add(context.Background(), user, post)
 	if err != nil {
 		log.Panic().Err(err).Msg("Error adding test post.")
 	}
@@ -134,7 +148,7 @@ func jsonFieldData(jsonRes map[string]interface{}, field string) interface{} {
 	return jsonData[field]
 }
 
-func NewRequest(router *gin.Engine, method, path, body string) *http.Request {
+func NewRequest(router http.Handler, method, path, body string) *http.Request {
 	req, err := http.NewRequest(method, path, strings.NewReader(body))
 	if err != nil {
 		log.Panic().Err(err).Msg("Error creating new request")
@@ -143,14 +157,14 @@ func NewRequest(router *gin.Engine, method, path, body string) *http.Request {
 	return req
 }
 
-func performRequest(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+func performRequest(router http.Handler, method, path, body string) *httptest.ResponseRecorder {
 	req := NewRequest(router, method, path, body)
 	rec := httptest.NewRecorder()
 	router.ServeHTTP(rec, req)
 	return rec
 }
 
-func PerformAuthorizedRequest(router *gin.Engine, token, method, path, body string) *httptest.ResponseRecorder {
+func PerformAuthorizedRequest(router http.Handler, token, method, path, body string) *httptest.ResponseRecorder {
 	req := NewRequest(router, method, path, body)
 	rec := httptest.NewRecorder()
 	req.Header.Add("Authorization", "Bearer "+token)
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"rgb/internal/conf"
+	"rgb/internal/store"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func performMetricsRequest(s *Server, token string) *httptest.ResponseRecorder {
+	req := NewRequest(s, "GET", "/metrics", "")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+	cfg := conf.NewConfig("dev")
+	cfg.MetricsToken = "scraper-token"
+	s := New(cfg, store.NewMemoryRepositories())
+
+	_ = performRequest(s, "GET", "/api/posts", "")
+	rec := performMetricsRequest(s, "scraper-token")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `rgb_http_requests_total{method="GET",route="/api/posts",status="401"}`)
+	assert.Contains(t, rec.Body.String(), "rgb_http_requests_in_flight")
+	assert.Contains(t, rec.Body.String(), "rgb_auth_signins_total")
+}
+
+func TestMetricsUnauthorized(t *testing.T) {
+	t.Parallel()
+	cfg := conf.NewConfig("dev")
+	cfg.MetricsToken = "scraper-token"
+	s := New(cfg, store.NewMemoryRepositories())
+
+	rec := performMetricsRequest(s, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "rgb_http_requests_total")
+	rec = performMetricsRequest(s, "other-token")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+
+	rec := performMetricsRequest(s, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -1,48 +1,209 @@
 package server
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
-	"fmt"
 	"net/http"
+	"reflect"
+	"regexp"
+	"rgb/internal/i18n"
+	"rgb/internal/logging"
+	"rgb/internal/metrics"
 	"rgb/internal/store"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/text/language"
 )
 
-func authorization(ctx *gin.Context) {
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "requestID"
+	localeKey       = "locale"
+	// access_token query parameter, removed from URL by hideAccessToken
+	accessTokenKey = "accessToken"
+)
+
+// Incoming request IDs are logged and echoed back, so only accept
+// reasonably sized IDs made of safe characters.
+var validRequestID = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,128}$`)
+
+// requestID ensures every request has an ID, propagating the one provided by
+// the client or proxy if it is valid. ID is returned in response header and
+// attached to the request logger stored in request context.
+func requestID(ctx *gin.Context) {
+	id := ctx.GetHeader(requestIDHeader)
+	if !validRequestID.MatchString(id) {
+		id = newRequestID()
+	}
+	ctx.Set(requestIDKey, id)
+	ctx.Header(requestIDHeader, id)
+
+	logContext := log.With().Str("request_id", id)
+	if span := trace.SpanContextFromContext(ctx.Request.Context()); span.IsValid() {
+		logContext = logContext.Str("trace_id", span.TraceID().String())
+	}
+	logger := logContext.Logger()
+	ctx.Request = ctx.Request.WithContext(logger.WithContext(ctx.Request.Context()))
+	ctx.Next()
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		log.Error().Err(err).Msg("Unable to generate request ID")
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
+
+// accessLog writes single log line for every handled request.
+func accessLog(ctx *gin.Context) {
+	start := time.Now()
+	ctx.Next()
+
+	status := ctx.Writer.Status()
+	log := logger(ctx)
+	event := log.Info()
+	switch {
+	case status >= http.StatusInternalServerError:
+		event = log.Error()
+	case status >= http.StatusBadRequest:
+		event = log.Warn()
+	case isProbe(ctx):
+		// Successful probes are too frequent to log on info level
+		event = log.Debug()
+	}
+	if user, exists := ctx.Get("user"); exists {
+		if user, ok := user.(*store.User); ok {
+			event = event.Int("user_id", user.ID)
+		}
+	}
+	event.
+		Str("method", ctx.Request.Method).
+		Str("path", ctx.Request.URL.Path).
+		Str("route", ctx.FullPath()).
+		Int("status", status).
+		Dur("latency", time.Since(start)).
+		Int("size", ctx.Writer.Size()).
+		Str("client_ip", ctx.ClientIP()).
+		Msg("Request handled")
+}
+
+func isProbe(ctx *gin.Context) bool {
+	route := ctx.FullPath()
+	return route == "/healthz" || route == "/readyz" || route == "/metrics"
+}
+
+func (s *Server) trackRequests(ctx *gin.Context) {
+	s.inFlightRequests.Add(1)
+	defer s.inFlightRequests.Done()
+	ctx.Next()
+}
+
+// httpMetrics records count and duration of handled requests.
+func httpMetrics(ctx *gin.Context) {
+	start := time.Now()
+	metrics.HTTPRequestsInFlight.Inc()
+	defer metrics.HTTPRequestsInFlight.Dec()
+	ctx.Next()
+
+	// Use route pattern instead of path, so e.g. every post ID doesn't
+	// create new time series
+	route := ctx.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	status := strconv.Itoa(ctx.Writer.Status())
+	metrics.HTTPRequests.WithLabelValues(ctx.Request.Method, route, status).Inc()
+	metrics.HTTPRequestDuration.WithLabelValues(ctx.Request.Method, route, status).Observe(time.Since(start).Seconds())
+}
+
+// recovery logs panic with request logger and responds with default 500 message.
+func (s *Server) recovery(ctx *gin.Context, recovered interface{}) {
+	logger(ctx).Error().Interface("panic", recovered).Msg("Recovered from panic")
+	s.writeError(ctx, newAPIError(http.StatusInternalServerError, CodeInternal, InternalServerError))
+}
+
+func (s *Server) authorization(ctx *gin.Context) {
 	authHeader := ctx.GetHeader("Authorization")
 	if authHeader == "" {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header missing."})
+		abortWithError(ctx, newAPIError(http.StatusUnauthorized, CodeUnauthorized, "Authorization header missing."))
 		return
 	}
 	headerParts := strings.Split(authHeader, " ")
 	if len(headerParts) != 2 {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format is not valid."})
+		abortWithError(ctx, newAPIError(http.StatusUnauthorized, CodeUnauthorized, "Authorization header format is not valid."))
 		return
 	}
 	if headerParts[0] != "Bearer" {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing bearer part."})
+		abortWithError(ctx, newAPIError(http.StatusUnauthorized, CodeUnauthorized, "Authorization header is missing bearer part."))
 		return
 	}
-	userID, err := verifyJWT(headerParts[1])
+	userID, err := s.verifyJWT(ctx.Request.Context(), headerParts[1])
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		metrics.JWTVerificationFailures.Inc()
+		abortWithError(ctx, newAPIError(http.StatusUnauthorized, CodeUnauthorized, err.Error()))
+		return
+	}
+	user, err := s.users.Fetch(ctx.Request.Context(), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		abortWithError(ctx, newAPIError(http.StatusUnauthorized, CodeUnauthorized, err.Error()))
 		return
 	}
-	user, err := store.FetchUser(userID)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		abortWithError(ctx, err)
 		return
 	}
 	ctx.Set("user", user)
 	ctx.Next()
 }
 
+// metricsAuthorization allows access only to requests with configured
+// metrics token as bearer token.
+func (s *Server) metricsAuthorization(ctx *gin.Context) {
+	expected := "Bearer " + s.cfg.MetricsToken
+	if subtle.ConstantTimeCompare([]byte(ctx.GetHeader("Authorization")), []byte(expected)) != 1 {
+		ctx.Header("WWW-Authenticate", "Bearer")
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	ctx.Next()
+}
+
+// adminOnly allows access only to users listed as admins in config.
+// It must be used after authorization middleware.
+func (s *Server) adminOnly(ctx *gin.Context) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	for _, admin := range s.cfg.Admins {
+		if user.Username == admin {
+			ctx.Next()
+			return
+		}
+	}
+	abortWithError(ctx, store.ErrForbidden)
+}
+
+func logger(ctx *gin.Context) *zerolog.Logger {
+	return logging.Module(ctx.Request.Context(), logging.Server)
+}
+
 func currentUser(ctx *gin.Context) (*store.User, error) {
 	var err error
+	log := logger(ctx)
 	_user, exists := ctx.Get("user")
 	if !exists {
 		err = errors.New("Current context user not set")
@@ -58,53 +219,58 @@ func currentUser(ctx *gin.Context) (*store.User, error) {
 	return user, nil
 }
 
-func customErrors(ctx *gin.Context) {
-	ctx.Next()
-	if len(ctx.Errors) > 0 {
-		for _, err := range ctx.Errors {
-			// Check error type
-			switch err.Type {
-			case gin.ErrorTypePublic:
-				// Show public errors only if nothing has been written yet
-				if !ctx.Writer.Written() {
-					ctx.AbortWithStatusJSON(ctx.Writer.Status(), gin.H{"error": err.Error()})
-				}
-			case gin.ErrorTypeBind:
-				errMap := make(map[string]string)
-				if errs, ok := err.Err.(validator.ValidationErrors); ok {
-					for _, fieldErr := range []validator.FieldError(errs) {
-						errMap[fieldErr.Field()] = customValidationError(fieldErr)
-					}
-				}
-
-				status := http.StatusBadRequest
-				// Preserve current status
-				if ctx.Writer.Status() != http.StatusOK {
-					status = ctx.Writer.Status()
-				}
-				ctx.AbortWithStatusJSON(status, gin.H{"error": errMap})
-			default:
-				// Log other errors
-				log.Error().Err(err.Err).Msg("Other error")
-			}
-		}
-
-		// If there was no public or bind error, display default 500 message
-		if !ctx.Writer.Written() {
-			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": InternalServerError})
-		}
+func init() {
+	// Validations used in binding tags, in addition to built-in ones
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		_ = v.RegisterValidation("future", future)
 	}
 }
 
-func customValidationError(err validator.FieldError) string {
+// future validates that time is in the future.
+func future(fl validator.FieldLevel) bool {
+	t, ok := fl.Field().Interface().(time.Time)
+	return ok && t.After(time.Now())
+}
+
+func customValidationError(ctx *gin.Context, err validator.FieldError) string {
+	field := translate(ctx, err.Field())
 	switch err.Tag() {
 	case "required":
-		return fmt.Sprintf("%s is required.", err.Field())
+		return translate(ctx, "%[1]s is required.", field)
 	case "min":
-		return fmt.Sprintf("%s must be longer than or equal %s characters.", err.Field(), err.Param())
+		return translate(ctx, "%[1]s must be longer than or equal %[2]s characters.", field, err.Param())
 	case "max":
-		return fmt.Sprintf("%s cannot be longer than %s characters.", err.Field(), err.Param())
+		if err.Kind() == reflect.Slice {
+			return translate(ctx, "%[1]s cannot have more than %[2]s items.", field, err.Param())
+		}
+		return translate(ctx, "%[1]s cannot be longer than %[2]s characters.", field, err.Param())
+	case "future":
+		return translate(ctx, "%[1]s must be in the future.", field)
 	default:
-		return err.Error()
+		return translate(ctx, "%[1]s is not valid.", field)
+	}
+}
+
+// localize selects locale of response messages from Accept-Language header.
+func localize(ctx *gin.Context) {
+	locale := i18n.Locale(ctx.GetHeader("Accept-Language"))
+	ctx.Set(localeKey, locale)
+	ctx.Header("Content-Language", locale.String())
+	ctx.Writer.Header().Add("Vary", "Accept-Language")
+	ctx.Next()
+}
+
+// translate returns message translated to locale of the request.
+func translate(ctx *gin.Context, message string, args ...interface{}) string {
+	return i18n.Translate(requestLocale(ctx), message, args...)
+}
+
+// requestLocale returns locale negotiated for request, English by default.
+func requestLocale(ctx *gin.Context) language.Tag {
+	value, _ := ctx.Get(localeKey)
+	locale, ok := value.(language.Tag)
+	if !ok {
+		return language.English
 	}
+	return locale
 }
@@ -1,48 +1,131 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"rgb/internal/store"
 	"testing"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestAuthorizationHeaderInvalidFormat(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
 
-	req := NewRequest(router, "GET", "/api/posts", "")
+	req := NewRequest(s, "GET", "/api/posts", "")
 	rec := httptest.NewRecorder()
 	req.Header.Add("Authorization", "Bearer"+token)
-	router.ServeHTTP(rec, req)
+	s.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusUnauthorized, rec.Code)
 	assert.Equal(t, "Authorization header format is not valid.", jsonRes(rec.Body)["error"])
 }
 
 func TestAuthorizationHeaderMissingBearer(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
 
-	req := NewRequest(router, "GET", "/api/posts", "")
+	req := NewRequest(s, "GET", "/api/posts", "")
 	rec := httptest.NewRecorder()
 	req.Header.Add("Authorization", "Bearr "+token)
-	router.ServeHTTP(rec, req)
+	s.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusUnauthorized, rec.Code)
 	assert.Equal(t, "Authorization header is missing bearer part.", jsonRes(rec.Body)["error"])
 }
 
 func TestAuthorizationInvalidToken(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
 
-	req := NewRequest(router, "GET", "/api/posts", "")
+	req := NewRequest(s, "GET", "/api/posts", "")
 	rec := httptest.NewRecorder()
 	req.Header.Add("Authorization", "Bearer invalid"+token)
-	router.ServeHTTP(rec, req)
+	s.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusUnauthorized, rec.Code)
 	assert.Equal(t, "jwt: token format is not valid", jsonRes(rec.Body)["error"])
 }
+
+// failingUsers fails to fetch users.
+type failingUsers struct {
+	store.UserRepository
+}
+
+func (failingUsers) Fetch(ctx context.Context, id int) (*store.User, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestAuthorizationUserNotFound(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	token := s.generateJWT(&store.User{ID: 42})
+
+	rec := PerformAuthorizedRequest(s, token, "GET", "/api/posts", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "unauthorized", jsonRes(rec.Body)["code"])
+}
+
+func TestAuthorizationFetchFailed(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	s.users = failingUsers{s.users}
+
+	// Valid token isn't rejected because database is unavailable
+	rec := PerformAuthorizedRequest(s, token, "GET", "/api/posts", "")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "internal_error", jsonRes(rec.Body)["code"])
+}
+
+func requestIDRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(requestID)
+	router.GET("/", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, ctx.GetString(requestIDKey))
+	})
+	return router
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	t.Parallel()
+	router := requestIDRouter()
+
+	rec := performRequest(router, "GET", "/", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, rec.Header().Get(requestIDHeader), 32)
+	assert.Equal(t, rec.Header().Get(requestIDHeader), rec.Body.String())
+}
+
+func TestRequestIDPropagated(t *testing.T) {
+	t.Parallel()
+	router := requestIDRouter()
+
+	req := NewRequest(router, "GET", "/", "")
+	req.Header.Add(requestIDHeader, "proxy-id-123")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, "proxy-id-123", rec.Header().Get(requestIDHeader))
+	assert.Equal(t, "proxy-id-123", rec.Body.String())
+}
+
+func TestRequestIDInvalidReplaced(t *testing.T) {
+	t.Parallel()
+	router := requestIDRouter()
+
+	req := NewRequest(router, "GET", "/", "")
+	req.Header.Add(requestIDHeader, "invalid id\n")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.NotEqual(t, "invalid id\n", rec.Header().Get(requestIDHeader))
+	assert.Len(t, rec.Header().Get(requestIDHeader), 32)
+}
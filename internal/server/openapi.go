@@ -0,0 +1,308 @@
+package server
+
+import (
+	"bytes"
+	"embed"
+	"io/fs"
+	"net/http"
+	"path"
+	"reflect"
+	"rgb/internal/logging"
+	"rgb/internal/openapi"
+	"rgb/internal/store"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	openAPIPath   = "/openapi.json"
+	swaggerUIPath = "/docs"
+)
+
+// Swagger UI assets are loaded from CDN until they are vendored by
+// scripts/swagger-ui.sh
+const swaggerUICDN = "https://unpkg.com/swagger-ui-dist@3.52.5/"
+
+var (
+	//go:embed swagger.html
+	swaggerUI []byte
+	//go:embed swaggerui
+	swaggerUIAssets embed.FS
+)
+
+// swaggerUIPage returns Swagger UI page loading vendored assets, or assets
+// from CDN if they aren't vendored.
+func swaggerUIPage() []byte {
+	assets := "docs/"
+	if _, err := fs.Stat(swaggerUIAssets, "swaggerui/swagger-ui-bundle.js"); err != nil {
+		log.Warn().Msg("Swagger UI assets aren't vendored, loading them from " + swaggerUICDN)
+		assets = swaggerUICDN
+	}
+	return bytes.ReplaceAll(swaggerUI, []byte("{{assets}}"), []byte(assets))
+}
+
+// operation describes route for OpenAPI document.
+type operation struct {
+	summary string
+	tag     string
+	// Route requires bearer token
+	auth bool
+	// Request body is bound to value of this type, nil if there is no body
+	request interface{}
+	// Request body is JSON merge patch of request type, applied by handler
+	// instead of being bound
+	patch bool
+	// Schema of successful response
+	response *openapi.Schema
+	// Content type of successful response, JSON if not set
+	contentType string
+	// Schemas of path parameters, string if not set
+	params map[string]*openapi.Schema
+	// Set if route is deprecated
+	deprecation *deprecation
+	// Maximum size of request body in bytes, not limited if not set
+	maxBodySize int64
+}
+
+type route struct {
+	method string
+	path   string
+	op     operation
+}
+
+// handle registers route and records it for OpenAPI document. If operation
+// has request type, body is bound to it before handlers run, and if it is
+// deprecated, deprecation headers are added to response.
+func (s *Server) handle(group *gin.RouterGroup, method, relativePath string, op operation, handlers ...gin.HandlerFunc) {
+	if op.request != nil && !op.patch {
+		handlers = append([]gin.HandlerFunc{bind(op.request)}, handlers...)
+	}
+	if op.maxBodySize > 0 {
+		handlers = append([]gin.HandlerFunc{limitBody(op.maxBodySize)}, handlers...)
+	}
+	if op.deprecation != nil {
+		handlers = append([]gin.HandlerFunc{deprecated(op.deprecation)}, handlers...)
+	}
+	group.Handle(method, relativePath, handlers...)
+	s.routes = append(s.routes, route{
+		method: method,
+		path:   path.Join(group.BasePath(), relativePath),
+		op:     op,
+	})
+}
+
+// limitBody makes reading request body larger than limit fail, so handlers
+// and binding don't read it into memory.
+func limitBody(limit int64) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, limit)
+		ctx.Next()
+	}
+}
+
+// messageResponse returns schema of response with msg and given fields.
+func messageResponse(fields map[string]*openapi.Schema) *openapi.Schema {
+	schema := &openapi.Schema{
+		Type:       "object",
+		Properties: map[string]*openapi.Schema{"msg": {Type: "string"}},
+		Required:   []string{"msg"},
+	}
+	for name, field := range fields {
+		schema.Properties[name] = field
+		schema.Required = append(schema.Required, name)
+	}
+	return schema
+}
+
+func dataResponse(data *openapi.Schema) *openapi.Schema {
+	return messageResponse(map[string]*openapi.Schema{"data": data})
+}
+
+var healthResponse = &openapi.Schema{
+	Type: "object",
+	Properties: map[string]*openapi.Schema{
+		"status": {Type: "string"},
+		"checks": {Type: "object", AdditionalProperties: &openapi.Schema{Type: "string"}},
+	},
+}
+
+func (s *Server) openAPIDocument() openapi.Document {
+	doc := openapi.Document{
+		OpenAPI: openapi.Version,
+		Info:    openapi.Info{Title: "RGB API", Version: "1.0.0"},
+		Paths:   map[string]map[string]openapi.Operation{},
+		Components: openapi.Components{
+			Schemas: map[string]*openapi.Schema{
+				"Error":       errorSchema(),
+				"Problem":     problemSchema(),
+				"Post":        openapi.SchemaOf(store.Post{}),
+				"Webhook":     openapi.SchemaOf(store.Webhook{}),
+				"ModuleLevel": openapi.SchemaOf(logging.ModuleLevel{}),
+			},
+			SecuritySchemes: map[string]openapi.SecurityScheme{
+				"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+	for _, route := range s.routes {
+		path, params := docPath(route.path, route.op.params)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]openapi.Operation{}
+		}
+		doc.Paths[path][strings.ToLower(route.method)] = openAPIOperation(&doc, route.op, params)
+	}
+	return doc
+}
+
+func openAPIOperation(doc *openapi.Document, op operation, params []openapi.Parameter) openapi.Operation {
+	contentType := op.contentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	success := openapi.Response{Description: "Successful response"}
+	if op.response != nil {
+		success.Content = map[string]openapi.MediaType{contentType: {Schema: op.response}}
+	}
+	docOp := openapi.Operation{
+		Summary:    op.summary,
+		Deprecated: op.deprecation != nil,
+		Parameters: params,
+		Responses: map[string]openapi.Response{
+			"200": success,
+			"default": {
+				Description: "Error",
+				Content: map[string]openapi.MediaType{
+					"application/json": {Schema: openapi.Ref("Error")},
+					problemContentType: {Schema: openapi.Ref("Problem")},
+				},
+			},
+		},
+	}
+	if op.tag != "" {
+		docOp.Tags = []string{op.tag}
+	}
+	if op.auth {
+		docOp.Security = []map[string][]string{{"bearer": {}}}
+	}
+	if op.request != nil {
+		name := strings.Title(reflect.TypeOf(op.request).Name())
+		schema := openapi.SchemaOf(op.request)
+		requestType := "application/json"
+		if op.patch {
+			// Every field of merge patch is optional
+			name += "Patch"
+			schema.Required = nil
+			requestType = mergePatchContentType
+			docOp.Parameters = append(docOp.Parameters, openapi.Parameter{
+				Name:   "If-Match",
+				In:     "header",
+				Schema: &openapi.Schema{Type: "string"},
+			})
+		}
+		doc.Components.Schemas[name] = schema
+		docOp.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content:  map[string]openapi.MediaType{requestType: {Schema: openapi.Ref(name)}},
+		}
+	}
+	return docOp
+}
+
+// docPath converts Gin path parameters, e.g. ":id", to OpenAPI ones.
+func docPath(ginPath string, schemas map[string]*openapi.Schema) (string, []openapi.Parameter) {
+	var params []openapi.Parameter
+	segments := strings.Split(ginPath, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+			continue
+		}
+		name := segment[1:]
+		schema, ok := schemas[name]
+		if !ok {
+			schema = &openapi.Schema{Type: "string"}
+		}
+		params = append(params, openapi.Parameter{Name: name, In: "path", Required: true, Schema: schema})
+		segments[i] = "{" + name + "}"
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func errorSchema() *openapi.Schema {
+	fields := &openapi.Schema{Type: "object", AdditionalProperties: &openapi.Schema{Type: "string"}}
+	return &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"error":      {OneOf: []*openapi.Schema{{Type: "string"}, fields}},
+			"code":       {Type: "string"},
+			"fields":     fields,
+			"request_id": {Type: "string"},
+		},
+		Required: []string{"error", "code"},
+	}
+}
+
+func problemSchema() *openapi.Schema {
+	return &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"type":       {Type: "string"},
+			"title":      {Type: "string"},
+			"status":     {Type: "integer"},
+			"detail":     {Type: "string"},
+			"instance":   {Type: "string"},
+			"code":       {Type: "string"},
+			"request_id": {Type: "string"},
+			"errors":     {Type: "object", AdditionalProperties: &openapi.Schema{Type: "string"}},
+		},
+		Required: []string{"type", "title", "status"},
+	}
+}
+
+// registerDocs registers routes serving OpenAPI document, and Swagger UI in
+// dev environment.
+func (s *Server) registerDocs(api *gin.RouterGroup) {
+	s.handle(api, http.MethodGet, openAPIPath, operation{
+		summary:  "OpenAPI document",
+		tag:      "docs",
+		response: &openapi.Schema{Type: "object"},
+	}, func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, s.openAPI)
+	})
+	if s.cfg.Env == "dev" {
+		page := swaggerUIPage()
+		s.handle(api, http.MethodGet, swaggerUIPath, operation{
+			summary:     "Swagger UI",
+			tag:         "docs",
+			response:    &openapi.Schema{Type: "string"},
+			contentType: "text/html",
+		}, func(ctx *gin.Context) {
+			ctx.Data(http.StatusOK, "text/html; charset=utf-8", page)
+		})
+		s.handle(api, http.MethodGet, swaggerUIPath+"/swagger-ui.css", operation{
+			summary:     "Swagger UI stylesheet",
+			tag:         "docs",
+			response:    &openapi.Schema{Type: "string"},
+			contentType: "text/css",
+		}, swaggerUIAsset("swagger-ui.css", "text/css; charset=utf-8"))
+		s.handle(api, http.MethodGet, swaggerUIPath+"/swagger-ui-bundle.js", operation{
+			summary:     "Swagger UI script",
+			tag:         "docs",
+			response:    &openapi.Schema{Type: "string"},
+			contentType: "application/javascript",
+		}, swaggerUIAsset("swagger-ui-bundle.js", "application/javascript; charset=utf-8"))
+	}
+}
+
+// swaggerUIAsset returns handler serving vendored Swagger UI asset.
+func swaggerUIAsset(name, contentType string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		data, err := swaggerUIAssets.ReadFile("swaggerui/" + name)
+		if err != nil {
+			abortWithError(ctx, store.ErrNotFound)
+			return
+		}
+		ctx.Data(http.StatusOK, contentType, data)
+	}
+}
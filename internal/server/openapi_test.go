@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"rgb/internal/openapi"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPIDocumentsAllRoutes(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+
+	for _, route := range s.router.Routes() {
+		path, _ := docPath(route.Path, nil)
+		operations, ok := s.openAPI.Paths[path]
+		if assert.True(t, ok, "route %s %s missing from OpenAPI document", route.Method, route.Path) {
+			assert.Contains(t, operations, strings.ToLower(route.Method), "route %s %s missing from OpenAPI document", route.Method, route.Path)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+
+	rec := performRequest(s, "GET", "/api/openapi.json", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var doc openapi.Document
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+
+	deletePost := doc.Paths["/api/posts/{id}"]["delete"]
+	assert.Equal(t, []map[string][]string{{"bearer": {}}}, deletePost.Security)
+	assert.Equal(t, "id", deletePost.Parameters[0].Name)
+	assert.Equal(t, "integer", deletePost.Parameters[0].Schema.Type)
+
+	post := doc.Components.Schemas["Post"]
+	assert.ElementsMatch(t, []string{"Title", "Content"}, post.Required)
+	assert.Equal(t, 3, *post.Properties["Title"].MinLength)
+	assert.Equal(t, 5000, *post.Properties["Content"].MaxLength)
+	assert.NotContains(t, post.Properties, "UserID")
+}
+
+func TestSwaggerUI(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+
+	rec := performRequest(s, "GET", "/api/docs", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "swagger-ui")
+	assert.NotContains(t, rec.Body.String(), "{{assets}}")
+
+	// Page loads vendored assets once they are downloaded
+	if _, err := fs.Stat(swaggerUIAssets, "swaggerui/swagger-ui-bundle.js"); err == nil {
+		assert.Contains(t, rec.Body.String(), `src="docs/swagger-ui-bundle.js"`)
+		rec = performRequest(s, "GET", "/api/docs/swagger-ui-bundle.js", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	} else {
+		assert.Contains(t, rec.Body.String(), swaggerUICDN)
+	}
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		doc, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":["b"]}`, `{"a":["c","d"]}`, `{"a":["c","d"]}`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+	}
+	for _, test := range tests {
+		patched, err := mergePatch([]byte(test.doc), []byte(test.patch))
+		assert.NoError(t, err)
+		assert.JSONEq(t, test.expected, string(patched), test.patch)
+	}
+
+	_, err := mergePatch([]byte(`{}`), []byte(`{`))
+	assert.Error(t, err)
+}
@@ -1,98 +1,234 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
+	"mime"
 	"net/http"
 	"rgb/internal/store"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 )
 
-func createPost(ctx *gin.Context) {
+func (s *Server) createPost(ctx *gin.Context) {
 	post := ctx.MustGet(gin.BindKey).(*store.Post)
+	// Publishing time is set by store, only imported posts keep their own
+	post.PublishedAt = nil
 	user, err := currentUser(ctx)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(ctx, err)
 		return
 	}
-	if err := store.AddPost(user, post); err != nil {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	err = s.withEvent(ctx.Request.Context(), user, store.EventPostCreated, post, func(ctx context.Context) error {
+		return s.addPost(ctx, user, post)
+	})
+	if err != nil {
+		abortWithError(ctx, err)
 		return
 	}
+	ctx.Header("ETag", versionETag(post.Version))
 	ctx.JSON(http.StatusOK, gin.H{
-		"msg":  "Post created successfully.",
+		"msg":  translate(ctx, "Post created successfully."),
 		"data": post,
 	})
 }
 
-func indexPosts(ctx *gin.Context) {
+func (s *Server) indexPosts(ctx *gin.Context) {
 	user, err := currentUser(ctx)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(ctx, err)
 		return
 	}
-	if err := store.FetchUserPosts(user); err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := s.posts.FetchUserPosts(ctx.Request.Context(), user); err != nil {
+		abortWithError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{
-		"msg":  "Posts fetched successfully.",
+		"msg":  translate(ctx, "Posts fetched successfully."),
 		"data": user.Posts,
 	})
 }
 
-func updatePost(ctx *gin.Context) {
+func (s *Server) updatePost(ctx *gin.Context) {
 	jsonPost := ctx.MustGet(gin.BindKey).(*store.Post)
 	user, err := currentUser(ctx)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": InternalServerError})
+		abortWithError(ctx, err)
 		return
 	}
-	dbPost, err := store.FetchPost(jsonPost.ID)
+	dbPost, err := s.posts.Fetch(ctx.Request.Context(), jsonPost.ID)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		abortWithError(ctx, err)
 		return
 	}
-	if user.ID != dbPost.UserID {
-		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Not authorized."})
+	if err := s.authorizePost(ctx.Request.Context(), user, dbPost, store.RoleEditor); err != nil {
+		abortWithError(ctx, err)
 		return
 	}
+	// Clients which don't send version overwrite concurrent edits, as
+	// before versions were introduced
+	if jsonPost.Version == 0 {
+		jsonPost.Version = dbPost.Version
+	}
+	// PUT replaces only title and content, publishing is changed with PATCH
+	jsonPost.Published = dbPost.Published
+	// Post stays scheduled unless client reschedules it
+	if jsonPost.PublishAt == nil {
+		jsonPost.PublishAt = dbPost.PublishAt
+	}
+	jsonPost.UserID = dbPost.UserID
+	jsonPost.CreatedAt = dbPost.CreatedAt
 	jsonPost.ModifiedAt = time.Now()
-	if err := store.UpdatePost(jsonPost); err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": InternalServerError})
+	// Events of shared posts go to their owner
+	err = s.withEvent(ctx.Request.Context(), &store.User{ID: dbPost.UserID}, store.EventPostUpdated, jsonPost, func(ctx context.Context) error {
+		return s.savePost(ctx, jsonPost)
+	})
+	if err != nil {
+		abortWithError(ctx, err)
 		return
 	}
+	ctx.Header("ETag", versionETag(jsonPost.Version))
 	ctx.JSON(http.StatusOK, gin.H{
-		"msg":  "Post updated successfully.",
+		"msg":  translate(ctx, "Post updated successfully."),
 		"data": jsonPost,
 	})
 }
 
-func deletePost(ctx *gin.Context) {
+// patchPost applies JSON merge patch to post. Version in the patch or ETag
+// in If-Match header make sure the patch is applied to the version of the
+// post client has seen.
+func (s *Server) patchPost(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		abortWithError(ctx, newAPIError(http.StatusBadRequest, CodeValidation, "Not valid ID."))
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(ctx.ContentType())
+	if mediaType != mergePatchContentType && mediaType != binding.MIMEJSON {
+		abortWithError(ctx, newAPIError(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "Content type is not supported."))
+		return
+	}
+	patch, err := ctx.GetRawData()
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	user, err := currentUser(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	dbPost, err := s.posts.Fetch(ctx.Request.Context(), id)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	if err := s.authorizePost(ctx.Request.Context(), user, dbPost, store.RoleEditor); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	if !ifMatch(ctx.GetHeader("If-Match"), versionETag(dbPost.Version)) {
+		abortWithError(ctx, newAPIError(http.StatusPreconditionFailed, CodePreconditionFailed, "Modified in the meantime."))
+		return
+	}
+
+	post, err := patchedPost(dbPost, patch)
+	if err != nil {
+		_ = ctx.Error(err).SetType(gin.ErrorTypeBind)
+		ctx.Abort()
+		return
+	}
+	if post.Version != dbPost.Version {
+		abortWithError(ctx, newAPIError(http.StatusConflict, CodeConflict, "Modified in the meantime."))
+		return
+	}
+	post.ModifiedAt = time.Now()
+	err = s.withEvent(ctx.Request.Context(), &store.User{ID: post.UserID}, store.EventPostUpdated, post, func(ctx context.Context) error {
+		return s.savePost(ctx, post)
+	})
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.Header("ETag", versionETag(post.Version))
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  translate(ctx, "Post updated successfully."),
+		"data": post,
+	})
+}
+
+// patchedPost returns copy of post with JSON merge patch applied and
+// validated. Fields which clients can't change keep their values.
+func patchedPost(post *store.Post, patch []byte) (*store.Post, error) {
+	doc, err := json.Marshal(post)
+	if err != nil {
+		return nil, err
+	}
+	if doc, err = mergePatch(doc, patch); err != nil {
+		return nil, err
+	}
+	patched := new(store.Post)
+	if err := json.Unmarshal(doc, patched); err != nil {
+		return nil, err
+	}
+	patched.ID = post.ID
+	patched.UserID = post.UserID
+	patched.CreatedAt = post.CreatedAt
+	// Publishing time which the patch doesn't change may already be due, so
+	// it isn't validated again
+	if timesEqual(patched.PublishAt, post.PublishAt) {
+		validate := binding.Validator.Engine().(*validator.Validate)
+		if err := validate.StructExcept(patched, "PublishAt"); err != nil {
+			return nil, err
+		}
+		return patched, nil
+	}
+	if err := binding.Validator.ValidateStruct(patched); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+// timesEqual reports whether optional times are both missing or the same
+// instant.
+func timesEqual(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func (s *Server) deletePost(ctx *gin.Context) {
 	paramID := ctx.Param("id")
 	id, err := strconv.Atoi(paramID)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Not valid ID."})
+		abortWithError(ctx, newAPIError(http.StatusBadRequest, CodeValidation, "Not valid ID."))
 		return
 	}
 	user, err := currentUser(ctx)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": InternalServerError})
+		abortWithError(ctx, err)
 		return
 	}
-	post, err := store.FetchPost(id)
+	post, err := s.posts.Fetch(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		abortWithError(ctx, err)
 		return
 	}
-	if user.ID != post.UserID {
-		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Not authorized."})
+	if err := s.authorizePost(ctx.Request.Context(), user, post, roleOwner); err != nil {
+		abortWithError(ctx, err)
 		return
 	}
-	if err := store.DeletePost(post); err != nil {
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	err = s.withEvent(ctx.Request.Context(), user, store.EventPostDeleted, post, func(ctx context.Context) error {
+		return s.posts.Delete(ctx, post)
+	})
+	if err != nil {
+		abortWithError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"msg": "Post deleted successfully."})
+	ctx.JSON(http.StatusOK, gin.H{"msg": translate(ctx, "Post deleted successfully.")})
 }
@@ -3,24 +3,27 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"rgb/internal/store"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestCreatePost(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
 
 	post := store.Post{
 		Title:   "Gotham cronicles",
 		Content: "Joker is planning big hit tonight.",
 	}
 	body := postJSON(post)
-	rec := PerformAuthorizedRequest(router, token, "POST", "/api/posts", body)
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/posts", body)
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Equal(t, "Post created successfully.", jsonRes(rec.Body)["msg"])
 	assert.Equal(t, float64(1), jsonFieldData(jsonRes(rec.Body), "ID"))
@@ -30,116 +33,138 @@ func TestCreatePost(t *testing.T) {
 	assert.NotEmpty(t, post.Content, jsonFieldData(jsonRes(rec.Body), "ModifiedAt"))
 }
 
+func TestCreatePostIgnoresPublishedAt(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+
+	body := `{"Title":"Gotham cronicles","Content":"Joker is planning big hit tonight.","Published":true,"PublishedAt":"2999-01-01T00:00:00Z"}`
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/posts", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	publishedAt, err := time.Parse(time.RFC3339, jsonFieldData(jsonRes(rec.Body), "PublishedAt").(string))
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), publishedAt, time.Minute)
+}
+
 func TestCreatePostUnathorized(t *testing.T) {
-	router := testSetup()
+	t.Parallel()
+	s := testSetup()
 
 	post := store.Post{
 		Title:   "Gotham cronicles",
 		Content: "Joker is planning big hit tonight.",
 	}
 	body := postJSON(post)
-	rec := performRequest(router, "POST", "/api/posts", body)
+	rec := performRequest(s, "POST", "/api/posts", body)
 	assert.Equal(t, http.StatusUnauthorized, rec.Code)
 	assert.Equal(t, "Authorization header missing.", jsonRes(rec.Body)["error"])
 }
 
 func TestCreatePostEmptyTitle(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
 
 	post := store.Post{
 		Title:   "",
 		Content: "Joker is planning big hit tonight.",
 	}
 	body := postJSON(post)
-	rec := PerformAuthorizedRequest(router, token, "POST", "/api/posts", body)
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/posts", body)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Title is required.", jsonFieldError(jsonRes(rec.Body), "Title"))
 }
 
 func TestCreatePostShortTitle(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
 
 	post := store.Post{
 		Title:   "Go",
 		Content: "Joker is planning big hit tonight.",
 	}
 	body := postJSON(post)
-	rec := PerformAuthorizedRequest(router, token, "POST", "/api/posts", body)
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/posts", body)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Title must be longer than or equal 3 characters.", jsonFieldError(jsonRes(rec.Body), "Title"))
 }
 
 func TestCreatePostLongTitle(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
 
 	post := store.Post{
 		Title:   strings.Repeat("G", 51),
 		Content: "Joker is planning big hit tonight.",
 	}
 	body := postJSON(post)
-	rec := PerformAuthorizedRequest(router, token, "POST", "/api/posts", body)
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/posts", body)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Title cannot be longer than 50 characters.", jsonFieldError(jsonRes(rec.Body), "Title"))
 }
 
 func TestCreatePostEmptyContent(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
 
 	post := store.Post{
 		Title:   "Gotham cronicles",
 		Content: "",
 	}
 	body := postJSON(post)
-	rec := PerformAuthorizedRequest(router, token, "POST", "/api/posts", body)
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/posts", body)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Content is required.", jsonFieldError(jsonRes(rec.Body), "Content"))
 }
 
 func TestCreatePostShortContent(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
 
 	post := store.Post{
 		Title:   "Gotham cronicles",
 		Content: "Joke",
 	}
 	body := postJSON(post)
-	rec := PerformAuthorizedRequest(router, token, "POST", "/api/posts", body)
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/posts", body)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Content must be longer than or equal 5 characters.", jsonFieldError(jsonRes(rec.Body), "Content"))
 }
 
 func TestCreatePostLongContent(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
 
 	post := store.Post{
 		Title:   "Gotham cronicles",
 		Content: strings.Repeat("J", 5001),
 	}
 	body := postJSON(post)
-	rec := PerformAuthorizedRequest(router, token, "POST", "/api/posts", body)
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/posts", body)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Content cannot be longer than 5000 characters.", jsonFieldError(jsonRes(rec.Body), "Content"))
 }
 
 func TestIndexPosts(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
-	post := addTestPost(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
 
-	rec := PerformAuthorizedRequest(router, token, "GET", "/api/posts", "")
+	rec := PerformAuthorizedRequest(s, token, "GET", "/api/posts", "")
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Equal(t, "Posts fetched successfully.", jsonRes(rec.Body)["msg"])
 	assert.Equal(t, float64(post.ID), jsonDataSlice(rec.Body)[0]["ID"])
@@ -150,25 +175,27 @@ func TestIndexPosts(t *testing.T) {
 }
 
 func TestIndexPostsUnathorized(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	_ = addTestPost(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	_ = s.addTestPost(user)
 
-	rec := performRequest(router, "GET", "/api/posts", "")
+	rec := performRequest(s, "GET", "/api/posts", "")
 	assert.Equal(t, http.StatusUnauthorized, rec.Code)
 	assert.Equal(t, "Authorization header missing.", jsonRes(rec.Body)["error"])
 }
 
 func TestIndexPostOnlyOwned(t *testing.T) {
-	router := testSetup()
-	user1 := addTestUser()
-	user2 := addTestUser2()
-	token1 := generateJWT(user1)
-	token2 := generateJWT(user2)
-	post1 := addTestPost(user1)
-	post2 := addTestPost2(user2)
-
-	rec := PerformAuthorizedRequest(router, token1, "GET", "/api/posts", "")
+	t.Parallel()
+	s := testSetup()
+	user1 := s.addTestUser()
+	user2 := s.addTestUser2()
+	token1 := s.generateJWT(user1)
+	token2 := s.generateJWT(user2)
+	post1 := s.addTestPost(user1)
+	post2 := s.addTestPost2(user2)
+
+	rec := PerformAuthorizedRequest(s, token1, "GET", "/api/posts", "")
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Equal(t, "Posts fetched successfully.", jsonRes(rec.Body)["msg"])
 	assert.Len(t, jsonDataSlice(rec.Body), 1)
@@ -178,7 +205,7 @@ func TestIndexPostOnlyOwned(t *testing.T) {
 	assert.NotEmpty(t, post1.Content, jsonDataSlice(rec.Body)[0]["CreatedAt"])
 	assert.NotEmpty(t, post1.Content, jsonDataSlice(rec.Body)[0]["ModifiedAt"])
 
-	rec = PerformAuthorizedRequest(router, token2, "GET", "/api/posts", "")
+	rec = PerformAuthorizedRequest(s, token2, "GET", "/api/posts", "")
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Equal(t, "Posts fetched successfully.", jsonRes(rec.Body)["msg"])
 	assert.Len(t, jsonDataSlice(rec.Body), 1)
@@ -190,11 +217,12 @@ func TestIndexPostOnlyOwned(t *testing.T) {
 }
 
 func TestIndexPostsEmpty(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
 
-	rec := PerformAuthorizedRequest(router, token, "GET", "/api/posts", "")
+	rec := PerformAuthorizedRequest(s, token, "GET", "/api/posts", "")
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Empty(t, jsonRes(rec.Body)["data"])
 	assert.NotNil(t, jsonRes(rec.Body)["data"])
@@ -202,140 +230,149 @@ func TestIndexPostsEmpty(t *testing.T) {
 }
 
 func TestUpdatePost(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
-	post := addTestPost(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
 
 	updated := store.Post{
 		ID:      post.ID,
 		Title:   "Gotham at night",
 		Content: "Gotham never sleeps.",
 	}
-	rec := PerformAuthorizedRequest(router, token, "PUT", "/api/posts", postJSON(updated))
+	rec := PerformAuthorizedRequest(s, token, "PUT", "/api/posts", postJSON(updated))
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Equal(t, "Post updated successfully.", jsonRes(rec.Body)["msg"])
 }
 
 func TestUpdatePostUnauthorized(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	post := addTestPost(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	post := s.addTestPost(user)
 
 	updated := store.Post{
 		ID:      post.ID,
 		Title:   "Gotham at night",
 		Content: "Gotham never sleeps.",
 	}
-	rec := performRequest(router, "PUT", "/api/posts", postJSON(updated))
+	rec := performRequest(s, "PUT", "/api/posts", postJSON(updated))
 	assert.Equal(t, http.StatusUnauthorized, rec.Code)
 	assert.Equal(t, "Authorization header missing.", jsonRes(rec.Body)["error"])
 }
 
 func TestUpdatePostEmptyTitle(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
-	post := addTestPost(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
 
 	updated := store.Post{
 		ID:      post.ID,
 		Title:   "",
 		Content: "Gotham never sleeps.",
 	}
-	rec := PerformAuthorizedRequest(router, token, "PUT", "/api/posts", postJSON(updated))
+	rec := PerformAuthorizedRequest(s, token, "PUT", "/api/posts", postJSON(updated))
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Title is required.", jsonFieldError(jsonRes(rec.Body), "Title"))
 }
 
 func TestUpdatePostShortTitle(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
-	post := addTestPost(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
 
 	updated := store.Post{
 		ID:      post.ID,
 		Title:   "Go",
 		Content: "Gotham never sleeps.",
 	}
-	rec := PerformAuthorizedRequest(router, token, "PUT", "/api/posts", postJSON(updated))
+	rec := PerformAuthorizedRequest(s, token, "PUT", "/api/posts", postJSON(updated))
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Title must be longer than or equal 3 characters.", jsonFieldError(jsonRes(rec.Body), "Title"))
 }
 
 func TestUpdatePostLongTitle(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
-	post := addTestPost(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
 
 	updated := store.Post{
 		ID:      post.ID,
 		Title:   strings.Repeat("G", 51),
 		Content: "Gotham never sleeps.",
 	}
-	rec := PerformAuthorizedRequest(router, token, "PUT", "/api/posts", postJSON(updated))
+	rec := PerformAuthorizedRequest(s, token, "PUT", "/api/posts", postJSON(updated))
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Title cannot be longer than 50 characters.", jsonFieldError(jsonRes(rec.Body), "Title"))
 }
 
 func TestUpdatePostEmptyContent(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
-	post := addTestPost(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
 
 	updated := store.Post{
 		ID:      post.ID,
 		Title:   "Gotham at night",
 		Content: "",
 	}
-	rec := PerformAuthorizedRequest(router, token, "PUT", "/api/posts", postJSON(updated))
+	rec := PerformAuthorizedRequest(s, token, "PUT", "/api/posts", postJSON(updated))
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Content is required.", jsonFieldError(jsonRes(rec.Body), "Content"))
 }
 
 func TestUpdatePostShortContent(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
-	post := addTestPost(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
 
 	updated := store.Post{
 		ID:      post.ID,
 		Title:   "Gotham at night",
 		Content: "Goth",
 	}
-	rec := PerformAuthorizedRequest(router, token, "PUT", "/api/posts", postJSON(updated))
+	rec := PerformAuthorizedRequest(s, token, "PUT", "/api/posts", postJSON(updated))
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Content must be longer than or equal 5 characters.", jsonFieldError(jsonRes(rec.Body), "Content"))
 }
 
 func TestUpdatePostLongContent(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
-	post := addTestPost(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
 
 	updated := store.Post{
 		ID:      post.ID,
 		Title:   "Gotham at night",
 		Content: strings.Repeat("G", 5001),
 	}
-	rec := PerformAuthorizedRequest(router, token, "PUT", "/api/posts", postJSON(updated))
+	rec := PerformAuthorizedRequest(s, token, "PUT", "/api/posts", postJSON(updated))
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Content cannot be longer than 5000 characters.", jsonFieldError(jsonRes(rec.Body), "Content"))
 }
 
 func TestUpdateNotOwnedPost(t *testing.T) {
-	router := testSetup()
-	user1 := addTestUser()
-	user2 := addTestUser2()
-	token1 := generateJWT(user1)
-	token2 := generateJWT(user2)
-	post1 := addTestPost(user1)
-	post2 := addTestPost2(user2)
+	t.Parallel()
+	s := testSetup()
+	user1 := s.addTestUser()
+	user2 := s.addTestUser2()
+	token1 := s.generateJWT(user1)
+	token2 := s.generateJWT(user2)
+	post1 := s.addTestPost(user1)
+	post2 := s.addTestPost2(user2)
 	updated1 := store.Post{
 		ID:      post1.ID,
 		Title:   "Gotham at night",
@@ -347,86 +384,204 @@ func TestUpdateNotOwnedPost(t *testing.T) {
 		Content: "Lex has build new underground lab.",
 	}
 
-	rec := PerformAuthorizedRequest(router, token1, "PUT", "/api/posts", postJSON(updated2))
+	rec := PerformAuthorizedRequest(s, token1, "PUT", "/api/posts", postJSON(updated2))
 	assert.Equal(t, http.StatusForbidden, rec.Code)
 	assert.Equal(t, "Not authorized.", jsonRes(rec.Body)["error"])
 
-	rec = PerformAuthorizedRequest(router, token2, "PUT", "/api/posts", postJSON(updated1))
+	rec = PerformAuthorizedRequest(s, token2, "PUT", "/api/posts", postJSON(updated1))
 	assert.Equal(t, http.StatusForbidden, rec.Code)
 	assert.Equal(t, "Not authorized.", jsonRes(rec.Body)["error"])
 }
 
 func TestUpdateNotExistingPost(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
-	_ = addTestPost(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	_ = s.addTestPost(user)
 
 	updated := store.Post{
 		ID:      123,
 		Title:   "Gotham at night",
 		Content: "Gotham never sleeps.",
 	}
-	rec := PerformAuthorizedRequest(router, token, "PUT", "/api/posts", postJSON(updated))
-	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec := PerformAuthorizedRequest(s, token, "PUT", "/api/posts", postJSON(updated))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
 	assert.Equal(t, "Not found.", jsonRes(rec.Body)["error"])
+	assert.Equal(t, "not_found", jsonRes(rec.Body)["code"])
+}
+
+func performPatchRequest(router http.Handler, token, path, body, ifMatch string) *httptest.ResponseRecorder {
+	req := NewRequest(router, "PATCH", path, body)
+	req.Header.Set("Content-Type", mergePatchContentType)
+	req.Header.Add("Authorization", "Bearer "+token)
+	if ifMatch != "" {
+		req.Header.Add("If-Match", ifMatch)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPatchPost(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
+
+	rec := performPatchRequest(s, token, fmt.Sprintf("/api/v1/posts/%d", post.ID), `{"Title": "Gotham at night"}`, `"1"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Post updated successfully.", jsonRes(rec.Body)["msg"])
+	assert.Equal(t, "Gotham at night", jsonFieldData(jsonRes(rec.Body), "Title"))
+	assert.Equal(t, post.Content, jsonFieldData(jsonRes(rec.Body), "Content"))
+	assert.Equal(t, float64(2), jsonFieldData(jsonRes(rec.Body), "Version"))
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+}
+
+func TestPatchPostClearRequiredField(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
+
+	rec := performPatchRequest(s, token, fmt.Sprintf("/api/posts/%d", post.ID), `{"Content": null}`, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Content is required.", jsonFieldError(jsonRes(rec.Body), "Content"))
+}
+
+func TestPatchPostNotValidBody(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
+
+	rec := performPatchRequest(s, token, fmt.Sprintf("/api/posts/%d", post.ID), `{"Title": 5}`, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Title is not valid.", jsonFieldError(jsonRes(rec.Body), "Title"))
+}
+
+func TestPatchPostStaleVersion(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
+	path := fmt.Sprintf("/api/posts/%d", post.ID)
+
+	rec := performPatchRequest(s, token, path, `{"Title": "Gotham at night", "Version": 1}`, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = performPatchRequest(s, token, path, `{"Title": "Gotham at dawn", "Version": 1}`, "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, CodeConflict, jsonRes(rec.Body)["code"])
+	assert.Equal(t, "Modified in the meantime.", jsonRes(rec.Body)["error"])
+}
+
+func TestPatchPostIfMatchFailed(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
+	path := fmt.Sprintf("/api/posts/%d", post.ID)
+
+	rec := performPatchRequest(s, token, path, `{"Title": "Gotham at night"}`, `"1"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = performPatchRequest(s, token, path, `{"Title": "Gotham at dawn"}`, `"1"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	assert.Equal(t, CodePreconditionFailed, jsonRes(rec.Body)["code"])
+}
+
+func TestPatchPostUnsupportedContentType(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
+
+	req := NewRequest(s, "PATCH", fmt.Sprintf("/api/posts/%d", post.ID), `Title=Gotham`)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	assert.Equal(t, CodeUnsupportedMediaType, jsonRes(rec.Body)["code"])
+}
+
+func TestPatchNotOwnedPost(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	post := s.addTestPost(user)
+	token := s.generateJWT(s.addTestUser2())
+
+	rec := performPatchRequest(s, token, fmt.Sprintf("/api/posts/%d", post.ID), `{"Title": "Gotham at night"}`, "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
 }
 
 func TestDeletePost(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
-	post := addTestPost(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
 
-	rec := PerformAuthorizedRequest(router, token, "DELETE", fmt.Sprintf("/api/posts/%d", post.ID), "")
+	rec := PerformAuthorizedRequest(s, token, "DELETE", fmt.Sprintf("/api/posts/%d", post.ID), "")
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Equal(t, "Post deleted successfully.", jsonRes(rec.Body)["msg"])
 }
 
 func TestDeletePostUnauthorized(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	post := addTestPost(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	post := s.addTestPost(user)
 
-	rec := performRequest(router, "DELETE", fmt.Sprintf("/api/posts/%d", post.ID), "")
+	rec := performRequest(s, "DELETE", fmt.Sprintf("/api/posts/%d", post.ID), "")
 	assert.Equal(t, http.StatusUnauthorized, rec.Code)
 	assert.Equal(t, "Authorization header missing.", jsonRes(rec.Body)["error"])
 }
 
 func TestDeleteNotExistingPost(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
 
-	rec := PerformAuthorizedRequest(router, token, "DELETE", "/api/posts/1", "")
-	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec := PerformAuthorizedRequest(s, token, "DELETE", "/api/posts/1", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
 	assert.Equal(t, "Not found.", jsonRes(rec.Body)["error"])
+	assert.Equal(t, "not_found", jsonRes(rec.Body)["code"])
 }
 
 func TestDeletePostInvalidID(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
-	token := generateJWT(user)
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
 
-	rec := PerformAuthorizedRequest(router, token, "DELETE", "/api/posts/invalid", "")
+	rec := PerformAuthorizedRequest(s, token, "DELETE", "/api/posts/invalid", "")
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Not valid ID.", jsonRes(rec.Body)["error"])
 }
 
 func TestDeleteNotOwnedPost(t *testing.T) {
-	router := testSetup()
-	user1 := addTestUser()
-	user2 := addTestUser2()
-	token1 := generateJWT(user1)
-	token2 := generateJWT(user2)
-	post1 := addTestPost(user1)
-	post2 := addTestPost2(user2)
-
-	rec := PerformAuthorizedRequest(router, token1, "DELETE", fmt.Sprintf("/api/posts/%d", post2.ID), "")
+	t.Parallel()
+	s := testSetup()
+	user1 := s.addTestUser()
+	user2 := s.addTestUser2()
+	token1 := s.generateJWT(user1)
+	token2 := s.generateJWT(user2)
+	post1 := s.addTestPost(user1)
+	post2 := s.addTestPost2(user2)
+
+	rec := PerformAuthorizedRequest(s, token1, "DELETE", fmt.Sprintf("/api/posts/%d", post2.ID), "")
 	assert.Equal(t, http.StatusForbidden, rec.Code)
 	assert.Equal(t, "Not authorized.", jsonRes(rec.Body)["error"])
 
-	rec = PerformAuthorizedRequest(router, token2, "DELETE", fmt.Sprintf("/api/posts/%d", post1.ID), "")
+	rec = PerformAuthorizedRequest(s, token2, "DELETE", fmt.Sprintf("/api/posts/%d", post1.ID), "")
 	assert.Equal(t, http.StatusForbidden, rec.Code)
 	assert.Equal(t, "Not authorized.", jsonRes(rec.Body)["error"])
 }
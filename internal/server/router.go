@@ -2,44 +2,295 @@ package server
 
 import (
 	"net/http"
-	"rgb/internal/conf"
+	"rgb/internal/metrics"
+	"rgb/internal/openapi"
 	"rgb/internal/store"
+	"rgb/internal/tracing"
+	"strings"
 
 	"github.com/gin-contrib/static"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func setRouter(cfg conf.Config) *gin.Engine {
-	// Creates default gin router with Logger and Recovery middleware already attached
-	router := gin.Default()
+func (s *Server) setRouter() *gin.Engine {
+	// Creates gin router without any middleware. Gin's Logger is replaced by
+	// structured access log which includes request ID and user ID.
+	router := gin.New()
+	router.Use(
+		hideAccessToken,
+		otelgin.Middleware(tracing.ServiceName),
+		s.trackRequests,
+		requestID,
+		localize,
+		accessLog,
+		httpMetrics,
+		gin.CustomRecovery(s.recovery),
+	)
 
 	// Enables automatic redirection if the current route can't be matched but a
 	// handler for the path with (without) the trailing slash exists.
 	router.RedirectTrailingSlash = true
 
 	// Serve static files to frontend if server is started in production environment
-	if cfg.Env == "prod" {
+	if s.cfg.Env == "prod" {
 		router.Use(static.Serve("/", static.LocalFile("./assets/build", true)))
 	}
 
+	root := &router.RouterGroup
+	// Metrics reveal usage of the server, so they are served only to
+	// scrapers which know configured token
+	if s.cfg.MetricsToken != "" {
+		s.handle(root, http.MethodGet, "/metrics", operation{
+			summary:     "Prometheus metrics",
+			tag:         "operations",
+			response:    &openapi.Schema{Type: "string"},
+			contentType: "text/plain",
+		}, s.metricsAuthorization, gin.WrapH(metrics.Handler()))
+	}
+	s.handle(root, http.MethodGet, "/healthz", operation{
+		summary:  "Liveness check",
+		tag:      "operations",
+		response: healthResponse,
+	}, liveness)
+	s.handle(root, http.MethodGet, "/readyz", operation{
+		summary:  "Readiness check",
+		tag:      "operations",
+		response: healthResponse,
+	}, s.readiness)
+
+	// Feeds are public and not versioned, so their URLs never change
+	feeds := router.Group("/")
+	feeds.Use(s.customErrors)
+	{
+		for _, format := range []feedFormat{rssFormat, atomFormat} {
+			feedResponse := &openapi.Schema{Type: "string"}
+			contentType := strings.Split(format.contentType, ";")[0]
+			s.handle(feeds, http.MethodGet, "/feed."+format.name, operation{
+				summary:     "Feed of recent public posts",
+				tag:         "feeds",
+				response:    feedResponse,
+				contentType: contentType,
+			}, s.siteFeed(format))
+			s.handle(feeds, http.MethodGet, "/users/:username/feed."+format.name, operation{
+				summary:     "Feed of user's recent public posts",
+				tag:         "feeds",
+				response:    feedResponse,
+				contentType: contentType,
+			}, s.userFeed(format))
+		}
+	}
+
 	// Create API route group
 	api := router.Group("/api")
-	api.Use(customErrors)
+	api.Use(s.customErrors)
+	s.registerDocs(api)
+	for _, version := range apiVersions {
+		version.register(s, api.Group("/"+version.name))
+	}
+	// Unversioned API routes are alias of the default version, which is
+	// used by the bundled frontend
+	defaultAPIVersion.register(s, api)
+
+	s.openAPI = s.openAPIDocument()
+
+	router.NoRoute(func(ctx *gin.Context) { ctx.JSON(http.StatusNotFound, gin.H{}) })
+
+	return router
+}
+
+// registerV1 registers routes of version 1 of the API.
+func (s *Server) registerV1(api *gin.RouterGroup) {
 	{
-		api.POST("/signup", gin.Bind(store.User{}), signUp)
-		api.POST("/signin", gin.Bind(store.User{}), signIn)
+		jwtResponse := messageResponse(map[string]*openapi.Schema{"jwt": {Type: "string"}})
+		s.handle(api, http.MethodPost, "/signup", operation{
+			summary:  "Sign up",
+			tag:      "users",
+			request:  store.User{},
+			response: jwtResponse,
+		}, s.signUp)
+		s.handle(api, http.MethodPost, "/signin", operation{
+			summary:  "Sign in",
+			tag:      "users",
+			request:  store.User{},
+			response: jwtResponse,
+		}, s.signIn)
 	}
 
 	authorized := api.Group("/")
-	authorized.Use(authorization)
+	authorized.Use(s.authorization)
 	{
-		authorized.GET("/posts", indexPosts)
-		authorized.POST("/posts", gin.Bind(store.Post{}), createPost)
-		authorized.PUT("/posts", gin.Bind(store.Post{}), updatePost)
-		authorized.DELETE("/posts/:id", deletePost)
+		s.handle(authorized, http.MethodGet, "/posts", operation{
+			summary:  "List current user's posts",
+			tag:      "posts",
+			auth:     true,
+			response: dataResponse(&openapi.Schema{Type: "array", Items: openapi.Ref("Post")}),
+		}, s.indexPosts)
+		s.handle(authorized, http.MethodPost, "/posts", operation{
+			summary:  "Create post",
+			tag:      "posts",
+			auth:     true,
+			request:  store.Post{},
+			response: dataResponse(openapi.Ref("Post")),
+		}, s.createPost)
+		s.handle(authorized, http.MethodPut, "/posts", operation{
+			summary:     "Update post, replaced by PATCH /posts/{id}",
+			tag:         "posts",
+			auth:        true,
+			request:     store.Post{},
+			response:    dataResponse(openapi.Ref("Post")),
+			deprecation: &deprecation{},
+		}, s.updatePost)
+		s.handle(authorized, http.MethodPatch, "/posts/:id", operation{
+			summary:  "Update post with JSON merge patch",
+			tag:      "posts",
+			auth:     true,
+			request:  store.Post{},
+			patch:    true,
+			response: dataResponse(openapi.Ref("Post")),
+			params:   map[string]*openapi.Schema{"id": {Type: "integer"}},
+		}, s.patchPost)
+		s.handle(authorized, http.MethodPost, "/posts/bulk", operation{
+			summary:     "Create, update and delete posts in bulk",
+			tag:         "posts",
+			auth:        true,
+			request:     bulkRequest{},
+			response:    dataResponse(&openapi.Schema{Type: "array", Items: openapi.SchemaOf(bulkResult{})}),
+			maxBodySize: maxBulkBodySize,
+		}, s.bulkPosts)
+		s.handle(authorized, http.MethodGet, "/posts/scheduled", operation{
+			summary:  "List current user's posts scheduled for publishing",
+			tag:      "posts",
+			auth:     true,
+			response: dataResponse(&openapi.Schema{Type: "array", Items: openapi.Ref("Post")}),
+		}, s.indexScheduledPosts)
+		s.handle(authorized, http.MethodPut, "/posts/:id/schedule", operation{
+			summary:  "Schedule or reschedule publishing of post",
+			tag:      "posts",
+			auth:     true,
+			request:  scheduleRequest{},
+			response: dataResponse(openapi.Ref("Post")),
+			params:   map[string]*openapi.Schema{"id": {Type: "integer"}},
+		}, s.schedulePost)
+		s.handle(authorized, http.MethodDelete, "/posts/:id/schedule", operation{
+			summary:  "Cancel scheduled publishing of post",
+			tag:      "posts",
+			auth:     true,
+			response: dataResponse(openapi.Ref("Post")),
+			params:   map[string]*openapi.Schema{"id": {Type: "integer"}},
+		}, s.unschedulePost)
+		s.handle(authorized, http.MethodGet, "/posts/shared", operation{
+			summary:  "List posts other users shared with current user",
+			tag:      "posts",
+			auth:     true,
+			response: dataResponse(&openapi.Schema{Type: "array", Items: openapi.SchemaOf(store.Collaborator{})}),
+		}, s.indexSharedPosts)
+		s.handle(authorized, http.MethodGet, "/posts/:id/collaborators", operation{
+			summary:  "List users post is shared with",
+			tag:      "posts",
+			auth:     true,
+			response: dataResponse(&openapi.Schema{Type: "array", Items: openapi.SchemaOf(store.Collaborator{})}),
+			params:   map[string]*openapi.Schema{"id": {Type: "integer"}},
+		}, s.indexCollaborators)
+		s.handle(authorized, http.MethodPut, "/posts/:id/collaborators", operation{
+			summary:  "Share post with user, or change their role",
+			tag:      "posts",
+			auth:     true,
+			request:  collaboratorRequest{},
+			response: dataResponse(openapi.SchemaOf(store.Collaborator{})),
+			params:   map[string]*openapi.Schema{"id": {Type: "integer"}},
+		}, s.grantRole)
+		s.handle(authorized, http.MethodDelete, "/posts/:id/collaborators/:username", operation{
+			summary:  "Stop sharing post with user",
+			tag:      "posts",
+			auth:     true,
+			response: messageResponse(nil),
+			params:   map[string]*openapi.Schema{"id": {Type: "integer"}, "username": {Type: "string"}},
+		}, s.revokeRole)
+		s.handle(authorized, http.MethodDelete, "/posts/:id", operation{
+			summary:  "Delete post",
+			tag:      "posts",
+			auth:     true,
+			response: messageResponse(nil),
+			params:   map[string]*openapi.Schema{"id": {Type: "integer"}},
+		}, s.deletePost)
 	}
 
-	router.NoRoute(func(ctx *gin.Context) { ctx.JSON(http.StatusNotFound, gin.H{}) })
+	{
+		s.handle(authorized, http.MethodGet, "/webhooks", operation{
+			summary:  "List current user's webhooks",
+			tag:      "webhooks",
+			auth:     true,
+			response: dataResponse(&openapi.Schema{Type: "array", Items: openapi.Ref("Webhook")}),
+		}, s.indexWebhooks)
+		s.handle(authorized, http.MethodPost, "/webhooks", operation{
+			summary:  "Create webhook",
+			tag:      "webhooks",
+			auth:     true,
+			request:  webhookRequest{},
+			response: dataResponse(openapi.Ref("Webhook")),
+		}, s.createWebhook)
+		s.handle(authorized, http.MethodDelete, "/webhooks/:id", operation{
+			summary:  "Delete webhook",
+			tag:      "webhooks",
+			auth:     true,
+			response: messageResponse(nil),
+			params:   map[string]*openapi.Schema{"id": {Type: "integer"}},
+		}, s.deleteWebhook)
+		s.handle(authorized, http.MethodGet, "/webhooks/:id/deliveries", operation{
+			summary:  "List most recent deliveries to webhook",
+			tag:      "webhooks",
+			auth:     true,
+			response: dataResponse(&openapi.Schema{Type: "array", Items: openapi.SchemaOf(store.Delivery{})}),
+			params:   map[string]*openapi.Schema{"id": {Type: "integer"}},
+		}, s.indexDeliveries)
+	}
 
-	return router
+	// EventSource can't set headers, so stream also accepts token in query
+	stream := api.Group("/")
+	stream.Use(tokenFromQuery, s.authorization)
+	{
+		s.handle(stream, http.MethodGet, "/events", operation{
+			summary:     "Stream current user's post events as server-sent events",
+			tag:         "posts",
+			auth:        true,
+			response:    &openapi.Schema{Type: "string"},
+			contentType: "text/event-stream",
+		}, s.streamEvents)
+	}
+
+	me := authorized.Group("/me")
+	{
+		s.handle(me, http.MethodGet, "/export", operation{
+			summary:     "Export posts as zip archive of Markdown files",
+			tag:         "posts",
+			auth:        true,
+			response:    &openapi.Schema{Type: "string", Format: "binary"},
+			contentType: "application/zip",
+		}, s.exportPosts)
+		s.handle(me, http.MethodPost, "/import", operation{
+			summary:  "Import posts from zip archive uploaded as file form field",
+			tag:      "posts",
+			auth:     true,
+			response: dataResponse(openapi.SchemaOf(importResult{})),
+		}, s.importPosts)
+	}
+
+	admin := authorized.Group("/admin")
+	admin.Use(s.adminOnly)
+	{
+		s.handle(admin, http.MethodGet, "/log-levels", operation{
+			summary:  "List log levels",
+			tag:      "admin",
+			auth:     true,
+			response: dataResponse(&openapi.Schema{Type: "object", AdditionalProperties: openapi.Ref("ModuleLevel")}),
+		}, indexLogLevels)
+		s.handle(admin, http.MethodPut, "/log-levels/:module", operation{
+			summary:  "Temporarily change module's log level",
+			tag:      "admin",
+			auth:     true,
+			request:  logLevelChange{},
+			response: dataResponse(openapi.Ref("ModuleLevel")),
+		}, setLogLevel)
+	}
 }
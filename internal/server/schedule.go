@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"rgb/internal/logging"
+	"rgb/internal/store"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Type of job publishing scheduled posts which are due
+const publishPostsJob = "posts.publish"
+
+type scheduleRequest struct {
+	PublishAt time.Time `binding:"required,future"`
+}
+
+// addPost saves new post and schedules its publishing.
+func (s *Server) addPost(ctx context.Context, user *store.User, post *store.Post) error {
+	if post.Published {
+		post.PublishAt = nil
+	}
+	if err := s.posts.Add(ctx, user, post); err != nil {
+		return err
+	}
+	return s.schedulePublishing(ctx, post)
+}
+
+// savePost updates post and schedules its publishing. Publishing post
+// cancels its schedule.
+func (s *Server) savePost(ctx context.Context, post *store.Post) error {
+	if post.Published {
+		post.PublishAt = nil
+	}
+	if err := s.posts.Update(ctx, post); err != nil {
+		return err
+	}
+	return s.schedulePublishing(ctx, post)
+}
+
+// schedulePublishing enqueues job publishing posts at post's publishing
+// time. Job publishes every due post, so job of post which was rescheduled
+// or cancelled in the meantime doesn't do anything.
+func (s *Server) schedulePublishing(ctx context.Context, post *store.Post) error {
+	if post.PublishAt == nil {
+		return nil
+	}
+	return s.jobs.Enqueue(ctx, &store.Job{Type: publishPostsJob, RunAt: *post.PublishAt})
+}
+
+// publishDue publishes scheduled posts which are due and emits their update
+// events. Posts are published by single UPDATE, so concurrent runs on
+// different instances don't publish post twice.
+func (s *Server) publishDue(ctx context.Context, job *store.Job) error {
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		posts, err := s.posts.PublishDue(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		for _, post := range posts {
+			if err := s.emit(ctx, &store.User{ID: post.UserID}, store.EventPostUpdated, post); err != nil {
+				return err
+			}
+		}
+		if len(posts) > 0 {
+			logging.Module(ctx, logging.Server).Info().Int("published", len(posts)).Msg("Published scheduled posts")
+		}
+		return nil
+	})
+}
+
+func (s *Server) indexScheduledPosts(ctx *gin.Context) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	posts, err := s.posts.FetchScheduled(ctx.Request.Context(), user)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  translate(ctx, "Scheduled posts fetched successfully."),
+		"data": posts,
+	})
+}
+
+// schedulePost schedules or reschedules publishing of post.
+func (s *Server) schedulePost(ctx *gin.Context) {
+	req := ctx.MustGet(gin.BindKey).(*scheduleRequest)
+	_, post, ok := s.postWithRole(ctx, store.RoleEditor)
+	if !ok {
+		return
+	}
+	if post.Published {
+		abortWithError(ctx, newAPIError(http.StatusConflict, CodeConflict, "Post is already published."))
+		return
+	}
+	publishAt := req.PublishAt
+	post.PublishAt = &publishAt
+	s.saveSchedule(ctx, post, "Post scheduled successfully.")
+}
+
+// unschedulePost cancels scheduled publishing of post.
+func (s *Server) unschedulePost(ctx *gin.Context) {
+	_, post, ok := s.postWithRole(ctx, store.RoleEditor)
+	if !ok {
+		return
+	}
+	if post.PublishAt == nil {
+		abortWithError(ctx, newAPIError(http.StatusNotFound, CodeNotFound, "Post is not scheduled."))
+		return
+	}
+	post.PublishAt = nil
+	s.saveSchedule(ctx, post, "Post schedule cancelled successfully.")
+}
+
+// saveSchedule saves post's publishing time and emits update event to post's
+// owner.
+func (s *Server) saveSchedule(ctx *gin.Context, post *store.Post, msg string) {
+	post.ModifiedAt = time.Now()
+	err := s.withEvent(ctx.Request.Context(), &store.User{ID: post.UserID}, store.EventPostUpdated, post, func(ctx context.Context) error {
+		return s.savePost(ctx, post)
+	})
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.Header("ETag", versionETag(post.Version))
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  translate(ctx, msg),
+		"data": post,
+	})
+}
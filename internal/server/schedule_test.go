@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func scheduleJSON(publishAt time.Time) string {
+	return fmt.Sprintf(`{"PublishAt": %q}`, publishAt.Format(time.RFC3339))
+}
+
+func TestSchedulePost(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
+	s.addTestPost2(user)
+	path := fmt.Sprintf("/api/v1/posts/%d/schedule", post.ID)
+
+	rec := PerformAuthorizedRequest(s, token, "PUT", path, scheduleJSON(time.Now().Add(time.Hour)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Post scheduled successfully.", jsonRes(rec.Body)["msg"])
+	assert.NotNil(t, jsonFieldData(jsonRes(rec.Body), "PublishAt"))
+
+	// Rescheduling replaces publishing time
+	publishAt := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	rec = PerformAuthorizedRequest(s, token, "PUT", path, scheduleJSON(publishAt))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = PerformAuthorizedRequest(s, token, "GET", "/api/v1/posts/scheduled", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	scheduled := jsonDataSlice(rec.Body)
+	if assert.Len(t, scheduled, 1) {
+		assert.Equal(t, float64(post.ID), scheduled[0]["ID"])
+		assert.Equal(t, publishAt.Format(time.RFC3339), scheduled[0]["PublishAt"])
+	}
+
+	rec = PerformAuthorizedRequest(s, token, "DELETE", path, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Post schedule cancelled successfully.", jsonRes(rec.Body)["msg"])
+	assert.Nil(t, jsonFieldData(jsonRes(rec.Body), "PublishAt"))
+	rec = PerformAuthorizedRequest(s, token, "GET", "/api/v1/posts/scheduled", "")
+	assert.Empty(t, jsonDataSlice(rec.Body))
+
+	rec = PerformAuthorizedRequest(s, token, "DELETE", path, "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "Post is not scheduled.", jsonRes(rec.Body)["error"])
+}
+
+func TestSchedulePostNotValid(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
+	path := fmt.Sprintf("/api/v1/posts/%d/schedule", post.ID)
+
+	rec := PerformAuthorizedRequest(s, token, "PUT", path, scheduleJSON(time.Now().Add(-time.Hour)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "PublishAt must be in the future.", jsonFieldError(jsonRes(rec.Body), "PublishAt"))
+
+	rec = PerformAuthorizedRequest(s, s.generateJWT(s.addTestUser2()), "PUT", path, scheduleJSON(time.Now().Add(time.Hour)))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	post.Published = true
+	assert.NoError(t, s.posts.Update(context.Background(), post))
+	rec = PerformAuthorizedRequest(s, token, "PUT", path, scheduleJSON(time.Now().Add(time.Hour)))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "Post is already published.", jsonRes(rec.Body)["error"])
+}
+
+func TestPublishScheduledPosts(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
+	later := s.addTestPost2(user)
+
+	for _, p := range []int{post.ID, later.ID} {
+		path := fmt.Sprintf("/api/v1/posts/%d/schedule", p)
+		rec := PerformAuthorizedRequest(s, token, "PUT", path, scheduleJSON(time.Now().Add(time.Hour)))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	// Publishing job of the first post runs once it is due
+	ctx := context.Background()
+	post, err := s.posts.Fetch(ctx, post.ID)
+	assert.NoError(t, err)
+	due := time.Now().Add(-time.Second)
+	post.PublishAt = &due
+	assert.NoError(t, s.posts.Update(ctx, post))
+	assert.NoError(t, s.publishDue(ctx, nil))
+
+	post, err = s.posts.Fetch(ctx, post.ID)
+	assert.NoError(t, err)
+	assert.True(t, post.Published)
+	assert.Nil(t, post.PublishAt)
+	later, err = s.posts.Fetch(ctx, later.ID)
+	assert.NoError(t, err)
+	assert.False(t, later.Published)
+	assert.NotNil(t, later.PublishAt)
+
+	events, err := s.events.FetchAfter(ctx, user.ID, 0, 10)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, events) {
+		assert.Equal(t, "post.updated", events[len(events)-1].Name)
+		assert.Contains(t, string(events[len(events)-1].Payload), `"Published":true`)
+	}
+}
+
+func TestEditScheduledPost(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
+	rec := PerformAuthorizedRequest(s, token, "PUT", fmt.Sprintf("/api/v1/posts/%d/schedule", post.ID), scheduleJSON(time.Now().Add(time.Hour)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Editing post with PUT or in bulk doesn't cancel its schedule
+	body := fmt.Sprintf(`{"ID": %d, "Title": "Edited with PUT", "Content": "Joker is planning a big hit tonight."}`, post.ID)
+	rec = PerformAuthorizedRequest(s, token, "PUT", "/api/posts", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = PerformAuthorizedRequest(s, token, "GET", "/api/v1/posts/scheduled", "")
+	if scheduled := jsonDataSlice(rec.Body); assert.Len(t, scheduled, 1) {
+		assert.Equal(t, "Edited with PUT", scheduled[0]["Title"])
+	}
+
+	body = fmt.Sprintf(`{"Operations": [{"Op": "update", "ID": %d, "Title": "Edited in bulk", "Content": "Joker is planning a big hit tonight."}]}`, post.ID)
+	rec = PerformAuthorizedRequest(s, token, "POST", "/api/v1/posts/bulk", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = PerformAuthorizedRequest(s, token, "GET", "/api/v1/posts/scheduled", "")
+	if scheduled := jsonDataSlice(rec.Body); assert.Len(t, scheduled, 1) {
+		assert.Equal(t, "Edited in bulk", scheduled[0]["Title"])
+	}
+}
+
+func TestPatchDueScheduledPost(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	post := s.addTestPost(user)
+	// Post which is due, but wasn't published by job yet
+	publishAt := time.Now().Add(-time.Minute)
+	post.PublishAt = &publishAt
+	assert.NoError(t, s.posts.Update(context.Background(), post))
+
+	rec := performPatchRequest(s, token, fmt.Sprintf("/api/posts/%d", post.ID), `{"Title": "Edited while due"}`, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Edited while due", jsonFieldData(jsonRes(rec.Body), "Title"))
+
+	// Changed publishing time is still validated
+	patch := fmt.Sprintf(`{"PublishAt": %q}`, time.Now().Add(-time.Hour).Format(time.RFC3339))
+	rec = performPatchRequest(s, token, fmt.Sprintf("/api/posts/%d", post.ID), patch, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
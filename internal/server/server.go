@@ -3,43 +3,137 @@ package server
 import (
 	"context"
 	"errors"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"rgb/internal/conf"
 	"rgb/internal/database"
+	"rgb/internal/metrics"
+	"rgb/internal/migrations"
+	"rgb/internal/openapi"
 	"rgb/internal/store"
+	"rgb/internal/tracing"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/cristalhq/jwt/v3"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
 	"github.com/rs/zerolog/log"
 )
 
 const InternalServerError = "Something went wrong!"
 
+// Server handles API requests. Its dependencies are passed to New instead of
+// being read from package globals, so tests can use in-memory repositories.
+type Server struct {
+	cfg           conf.Config
+	tx            store.Transactor
+	users         store.UserRepository
+	posts         store.PostRepository
+	webhooks      store.WebhookRepository
+	jobs          store.JobRepository
+	events        store.EventRepository
+	collaborators store.CollaboratorRepository
+
+	jwtSigner   jwt.Signer
+	jwtVerifier jwt.Verifier
+
+	readinessChecks []readinessCheck
+	// Set at the start of graceful shutdown, so load balancers stop sending
+	// new traffic before server stops accepting connections
+	shuttingDown int32
+	// Requests being handled, so shutdown can wait for them after cancelling
+	inFlightRequests sync.WaitGroup
+
+	// Open event streams, closed by streamsDone when server shuts down
+	streams         *streamHub
+	streamHeartbeat time.Duration
+	streamsDone     chan struct{}
+	stopStreamsOnce sync.Once
+
+	router *gin.Engine
+	// Routes registered with handle, and OpenAPI document describing them
+	routes  []route
+	openAPI openapi.Document
+}
+
+func New(cfg conf.Config, repos store.Repositories) *Server {
+	s := &Server{
+		cfg:           cfg,
+		tx:            repos.Tx,
+		users:         repos.Users,
+		posts:         repos.Posts,
+		webhooks:      repos.Webhooks,
+		jobs:          repos.Jobs,
+		events:        repos.Events,
+		collaborators: repos.Collaborators,
+
+		streams:         newStreamHub(),
+		streamHeartbeat: streamHeartbeat,
+		streamsDone:     make(chan struct{}),
+	}
+	s.jwtSetup()
+	s.router = s.setRouter()
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.router.ServeHTTP(w, req)
+}
+
 func Start(cfg conf.Config) {
-	jwtSetup(cfg)
+	shutdownTracing := tracing.Setup(cfg)
+
+	db := store.NewDBConnection(database.NewDBOptions(cfg))
+	store.SetQueryRetries(cfg.DbQueryRetries)
+	store.SetQueryTimeout(cfg.DbQueryTimeout)
+	if err := store.WaitForDB(context.Background(), db, cfg.DbStartupTimeout); err != nil {
+		log.Fatal().Err(err).Msg("Database not reachable")
+	}
+	migrate(cfg, db)
+	metrics.RegisterDBPool(func() *pg.DB { return db })
+
+	repos := store.NewRepositories(db)
+	s := New(cfg, repos)
+	s.AddReadinessCheck("database", db.Ping)
+	s.AddReadinessCheck("migrations", func(ctx context.Context) error {
+		return migrations.CheckVersion(db.WithContext(ctx))
+	})
+
+	// Every request context is derived from this one, so cancelling it
+	// cancels database queries of all in-flight requests
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
 
-	store.SetDBConnection(database.NewDBOptions(cfg))
+	// Background jobs run until server shuts down, jobs which are not done
+	// by then are left in the queue for the next start
+	runner := s.newJobRunner()
+	runner.Start()
 
-	router := setRouter(cfg)
+	listenCtx, stopListening := context.WithCancel(context.Background())
+	defer stopListening()
+	go s.listenEvents(listenCtx)
 
 	server := &http.Server{
-		Addr:    cfg.Host + ":" + cfg.Port,
-		Handler: router,
+		Addr:        cfg.Host + ":" + cfg.Port,
+		Handler:     s,
+		BaseContext: func(net.Listener) context.Context { return requestsCtx },
 	}
 
 	// Initializing the server in a goroutine so that
 	// it won't block the graceful shutdown handling below
 	go func() {
-		if err := server.ListenAndServe(); err != nil && errors.Is(err, http.ErrServerClosed) {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Error().Err(err).Msg("Server ListenAndServe error")
 		}
 	}()
 
 	// Wait for interrupt signal to gracefully shutdown the server with
-	// a timeout of 5 seconds.
-	quit := make(chan os.Signal)
+	// a configured timeout.
+	quit := make(chan os.Signal, 1)
 	// kill (no param) default send syscall.SIGTERM
 	// kill -2 is syscall.SIGINT
 	// kill -9 is syscall.SIGKILL but can't be catch, so don't need add it
@@ -47,14 +141,71 @@ func Start(cfg conf.Config) {
 	<-quit
 	log.Info().Msg("Shutting down server...")
 
-	// The context is used to inform the server it has 5 seconds to finish
-	// the request it is currently handling
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// Fail readiness checks first and keep serving while load balancers
+	// notice it and stop routing new requests to this instance
+	s.setShuttingDown()
+	if cfg.ShutdownDrainDelay > 0 {
+		log.Info().Dur("delay", cfg.ShutdownDrainDelay).Msg("Draining traffic...")
+		time.Sleep(cfg.ShutdownDrainDelay)
+	}
+
+	// The context is used to inform the server how long it has to finish
+	// the requests it is currently handling
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
+	// Jobs and tracing are shut down even if requests didn't finish in time,
+	// and the process exits with failure status afterwards
+	forced := false
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatal().Err(err).Msg("Server forced to shutdown")
+		// Abort queries of requests which didn't finish in time and give
+		// them a moment to send cancel requests to the database
+		cancelRequests()
+		waitTimeout(&s.inFlightRequests, time.Second)
+		log.Error().Err(err).Msg("Server forced to shutdown")
+		forced = true
+	}
+	// Jobs share the shutdown timeout with requests, and the ones which
+	// don't finish in time are interrupted
+	if err := runner.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("Background jobs forced to stop")
+	}
+	// Traces of the interrupted work are still worth flushing
+	if ctx.Err() != nil {
+		var cancelFlush context.CancelFunc
+		ctx, cancelFlush = context.WithTimeout(context.Background(), time.Second)
+		defer cancelFlush()
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error().Err(err).Msg("Error flushing traces")
 	}
 
+	if forced {
+		log.Error().Msg("Server exiting after forced shutdown.")
+		os.Exit(1)
+	}
 	log.Info().Msg("Server exiting.")
 }
+
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+func migrate(cfg conf.Config, db *pg.DB) {
+	if cfg.AutoMigrate {
+		if err := migrations.Migrate(db); err != nil {
+			log.Fatal().Err(err).Msg("Error migrating database")
+		}
+	}
+	if err := migrations.CheckVersion(db); err != nil {
+		log.Fatal().Err(err).Msg("Database schema doesn't match this version of the server")
+	}
+}
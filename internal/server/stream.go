@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"rgb/internal/store"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Comment sent when there were no events for this long keeps connection
+	// open through proxies
+	streamHeartbeat = 15 * time.Second
+	// Maximum number of events fetched at once
+	streamBatchSize = 100
+	// Milliseconds clients wait before reconnecting
+	streamRetry = 3000
+	// Events older than this can't be resumed
+	eventRetention = 24 * time.Hour
+)
+
+// Events streamed to clients of the user they are about.
+var streamedEvents = map[string]bool{
+	store.EventPostCreated: true,
+	store.EventPostUpdated: true,
+	store.EventPostDeleted: true,
+}
+
+// streamHub wakes streams of users whose events were added.
+type streamHub struct {
+	mu          sync.Mutex
+	subscribers map[int]map[chan struct{}]bool
+}
+
+func newStreamHub() *streamHub {
+	return &streamHub{subscribers: map[int]map[chan struct{}]bool{}}
+}
+
+// subscribe returns channel receiving value when user's event is added, and
+// function which unsubscribes it.
+func (h *streamHub) subscribe(userID int) (<-chan struct{}, func()) {
+	// Buffered, so wake up isn't lost while stream is writing events
+	wake := make(chan struct{}, 1)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = map[chan struct{}]bool{}
+	}
+	h.subscribers[userID][wake] = true
+	return wake, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subscribers[userID], wake)
+		if len(h.subscribers[userID]) == 0 {
+			delete(h.subscribers, userID)
+		}
+	}
+}
+
+func (h *streamHub) notify(userID int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for wake := range h.subscribers[userID] {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// listenEvents wakes streams when events are added by any server instance,
+// until ctx is done. Listening is restarted if it fails.
+func (s *Server) listenEvents(ctx context.Context) {
+	for {
+		err := s.events.Listen(ctx, s.streams.notify)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Error().Err(err).Msg("Listening for events failed, retrying")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// stopStreams ends all open streams, so clients reconnect to other
+// instances.
+func (s *Server) stopStreams() {
+	s.stopStreamsOnce.Do(func() { close(s.streamsDone) })
+}
+
+// hideAccessToken moves access_token query parameter to context, so tokens
+// don't end up in traces and logs. It runs before tracing middleware, which
+// records request URI when request starts.
+func hideAccessToken(ctx *gin.Context) {
+	query := ctx.Request.URL.Query()
+	if tokens, ok := query["access_token"]; ok {
+		ctx.Set(accessTokenKey, tokens[0])
+		query.Del("access_token")
+		ctx.Request.URL.RawQuery = query.Encode()
+		ctx.Request.RequestURI = ctx.Request.URL.RequestURI()
+	}
+	ctx.Next()
+}
+
+// tokenFromQuery lets clients which can't set headers, e.g. browser's
+// EventSource, send bearer token in access_token query parameter.
+func tokenFromQuery(ctx *gin.Context) {
+	if token := ctx.GetString(accessTokenKey); token != "" && ctx.GetHeader("Authorization") == "" {
+		ctx.Request.Header.Set("Authorization", "Bearer "+token)
+	}
+	ctx.Next()
+}
+
+// streamEvents streams current user's post events as server-sent events.
+// Stream resumes after ID from Last-Event-ID header, or starts with events
+// added after the request if there is none.
+func (s *Server) streamEvents(ctx *gin.Context) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	reqCtx := ctx.Request.Context()
+	// Subscribe before fetching, so events added in the meantime wake stream
+	wake, unsubscribe := s.streams.subscribe(user.ID)
+	defer unsubscribe()
+
+	var lastID int64
+	if header := ctx.GetHeader("Last-Event-ID"); header != "" {
+		lastID, err = strconv.ParseInt(header, 10, 64)
+		if err != nil || lastID < 0 {
+			abortWithError(ctx, newAPIError(http.StatusBadRequest, CodeValidation, "Last-Event-ID header is not valid."))
+			return
+		}
+	} else if lastID, err = s.events.LastID(reqCtx, user.ID); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	// Disables response buffering in nginx
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	fmt.Fprintf(ctx.Writer, "retry: %d\n\n", streamRetry)
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(s.streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		events, err := s.events.FetchAfter(reqCtx, user.ID, lastID, streamBatchSize)
+		if err != nil {
+			// Client reconnects and resumes after the last sent event
+			return
+		}
+		for _, event := range events {
+			writeEvent(ctx.Writer, event)
+			lastID = event.ID
+		}
+		if len(events) > 0 {
+			ctx.Writer.Flush()
+		}
+		if len(events) == streamBatchSize {
+			continue
+		}
+		select {
+		case <-reqCtx.Done():
+			return
+		case <-s.streamsDone:
+			return
+		case <-wake:
+		case <-heartbeat.C:
+			// Events are fetched after heartbeat too, in case notification
+			// was lost
+			_, _ = io.WriteString(ctx.Writer, ": heartbeat\n\n")
+			ctx.Writer.Flush()
+		}
+	}
+}
+
+// writeEvent writes event in server-sent events format. Payload is JSON
+// without new lines, so it fits in one data line.
+func writeEvent(w io.Writer, event *store.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Name, event.Payload)
+}
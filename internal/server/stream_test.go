@@ -0,0 +1,183 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+// openStream starts listening for events and opens stream on test server.
+// It returns channel receiving non-empty lines of the stream, which is
+// closed when stream ends.
+func openStream(t *testing.T, s *Server, header http.Header, query string) (*http.Response, <-chan string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go s.listenEvents(ctx)
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/v1/events"+query, nil)
+	assert.NoError(t, err)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	res, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	lines := make(chan string, 100)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				lines <- line
+			}
+		}
+	}()
+	return res, lines
+}
+
+func nextLine(t *testing.T, lines <-chan string) string {
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for stream")
+		return ""
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+
+	res, lines := openStream(t, s, http.Header{"Authorization": {"Bearer " + token}}, "")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	assert.Equal(t, "retry: 3000", nextLine(t, lines))
+
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/posts", `{"Title": "Gotham cronicles", "Content": "Joker is planning big hit tonight."}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "id: 1", nextLine(t, lines))
+	assert.Equal(t, "event: post.created", nextLine(t, lines))
+	data := nextLine(t, lines)
+	assert.True(t, strings.HasPrefix(data, "data: "))
+	assert.Contains(t, data, `"Title":"Gotham cronicles"`)
+
+	rec = PerformAuthorizedRequest(s, token, "DELETE", "/api/posts/1", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "id: 2", nextLine(t, lines))
+	assert.Equal(t, "event: post.deleted", nextLine(t, lines))
+}
+
+func TestStreamOtherUsersEvents(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	s.streamHeartbeat = 10 * time.Millisecond
+	user := s.addTestUser()
+	token2 := s.generateJWT(s.addTestUser2())
+
+	_, lines := openStream(t, s, http.Header{"Authorization": {"Bearer " + token2}}, "")
+	assert.Equal(t, "retry: 3000", nextLine(t, lines))
+	rec := PerformAuthorizedRequest(s, s.generateJWT(user), "POST", "/api/posts", `{"Title": "Gotham cronicles", "Content": "Joker is planning big hit tonight."}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, ": heartbeat", nextLine(t, lines))
+	}
+}
+
+func TestStreamResume(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	for i := 0; i < 3; i++ {
+		body := fmt.Sprintf(`{"Title": "Post %d", "Content": "Joker is planning big hit tonight."}`, i)
+		rec := PerformAuthorizedRequest(s, token, "POST", "/api/posts", body)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// Token in query is used by EventSource, which can't set headers
+	_, lines := openStream(t, s, http.Header{"Last-Event-ID": {"1"}}, "?access_token="+token)
+	assert.Equal(t, "retry: 3000", nextLine(t, lines))
+	assert.Equal(t, "id: 2", nextLine(t, lines))
+	assert.Equal(t, "event: post.created", nextLine(t, lines))
+	assert.Contains(t, nextLine(t, lines), `"Title":"Post 1"`)
+	assert.Equal(t, "id: 3", nextLine(t, lines))
+}
+
+// Not parallel, since it replaces global tracer provider
+func TestAccessTokenNotTraced(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	s := testSetup()
+	token := s.generateJWT(s.addTestUser())
+
+	res, lines := openStream(t, s, nil, "?access_token="+token+"&lang=en")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "retry: 3000", nextLine(t, lines))
+	s.stopStreams()
+	for range lines {
+	}
+
+	var requestSpans []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "/api/v1/events" {
+			requestSpans = append(requestSpans, span)
+		}
+	}
+	if assert.Len(t, requestSpans, 1) {
+		assert.Contains(t, requestSpans[0].Attributes(), semconv.HTTPTargetKey.String("/api/v1/events?lang=en"))
+		for _, attr := range requestSpans[0].Attributes() {
+			assert.NotContains(t, attr.Value.Emit(), token)
+		}
+	}
+}
+
+func TestStreamEndsOnShutdown(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	token := s.generateJWT(s.addTestUser())
+
+	_, lines := openStream(t, s, http.Header{"Authorization": {"Bearer " + token}}, "")
+	assert.Equal(t, "retry: 3000", nextLine(t, lines))
+	s.setShuttingDown()
+	select {
+	case _, ok := <-lines:
+		assert.False(t, ok)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stream didn't end")
+	}
+}
+
+func TestStreamNotValid(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	token := s.generateJWT(s.addTestUser())
+
+	rec := performRequest(s, "GET", "/api/v1/events", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := NewRequest(s, "GET", "/api/v1/events", "")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Last-Event-ID", "first")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Last-Event-ID header is not valid.", jsonRes(rec.Body)["error"])
+}
@@ -1,34 +1,47 @@
 package server
 
 import (
+	"context"
 	"net/http"
+	"rgb/internal/metrics"
 	"rgb/internal/store"
 
 	"github.com/gin-gonic/gin"
 )
 
-func signUp(ctx *gin.Context) {
+func (s *Server) signUp(ctx *gin.Context) {
 	user := ctx.MustGet(gin.BindKey).(*store.User)
-	if err := store.AddUser(user); err != nil {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := s.users.Add(ctx.Request.Context(), user); err != nil {
+		abortWithError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{
-		"msg": "Signed up successfully.",
-		"jwt": generateJWT(user),
+		"msg": translate(ctx, "Signed up successfully."),
+		"jwt": s.generateJWT(user),
 	})
 }
 
-func signIn(ctx *gin.Context) {
+func (s *Server) signIn(ctx *gin.Context) {
 	user := ctx.MustGet(gin.BindKey).(*store.User)
-	user, err := store.Authenticate(user.Username, user.Password)
+	user, err := s.users.Authenticate(ctx.Request.Context(), user.Username, user.Password)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Sign in failed."})
+		metrics.FailedSignIns.Inc()
+		abortWithError(ctx, newAPIError(http.StatusUnauthorized, CodeUnauthorized, "Sign in failed."))
 		return
 	}
+	metrics.SignIns.Inc()
+	// Sign in doesn't change anything, so it isn't failed if event can't be
+	// saved. Transaction saves event with all its deliveries or not at all.
+	data := gin.H{"ID": user.ID, "Username": user.Username}
+	err = s.tx.WithTx(ctx.Request.Context(), func(ctx context.Context) error {
+		return s.emit(ctx, user, store.EventUserSignedIn, data)
+	})
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error emitting sign in event")
+	}
 
 	ctx.JSON(http.StatusOK, gin.H{
-		"msg": "Signed in successfully.",
-		"jwt": generateJWT(user),
+		"msg": translate(ctx, "Signed in successfully."),
+		"jwt": s.generateJWT(user),
 	})
 }
@@ -1,6 +1,8 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"rgb/internal/store"
 	"strings"
@@ -10,147 +12,185 @@ import (
 )
 
 func TestSignUp(t *testing.T) {
-	router := testSetup()
+	t.Parallel()
+	s := testSetup()
 
 	body := userJSON(store.User{
 		Username: "batman",
 		Password: "secret123",
 	})
-	rec := performRequest(router, "POST", "/api/signup", body)
+	rec := performRequest(s, "POST", "/api/signup", body)
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Equal(t, "Signed up successfully.", jsonRes(rec.Body)["msg"])
 	assert.NotEmpty(t, jsonRes(rec.Body)["jwt"])
 }
 
 func TestSignUpEmptyUsername(t *testing.T) {
-	router := testSetup()
+	t.Parallel()
+	s := testSetup()
 
 	body := userJSON(store.User{
 		Username: "",
 		Password: "secret123",
 	})
-	rec := performRequest(router, "POST", "/api/signup", body)
+	rec := performRequest(s, "POST", "/api/signup", body)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Username is required.", jsonFieldError(jsonRes(rec.Body), "Username"))
 	assert.Empty(t, jsonRes(rec.Body)["jwt"])
 }
 
 func TestSignUpShortUsername(t *testing.T) {
-	router := testSetup()
+	t.Parallel()
+	s := testSetup()
 
 	body := userJSON(store.User{
 		Username: "batm",
 		Password: "secret123",
 	})
-	rec := performRequest(router, "POST", "/api/signup", body)
+	rec := performRequest(s, "POST", "/api/signup", body)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Username must be longer than or equal 5 characters.", jsonFieldError(jsonRes(rec.Body), "Username"))
 	assert.Empty(t, jsonRes(rec.Body)["jwt"])
 }
 
 func TestSignUpLongUsername(t *testing.T) {
-	router := testSetup()
+	t.Parallel()
+	s := testSetup()
 
 	body := userJSON(store.User{
 		Username: strings.Repeat("b", 31),
 		Password: "secret123",
 	})
-	rec := performRequest(router, "POST", "/api/signup", body)
+	rec := performRequest(s, "POST", "/api/signup", body)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Username cannot be longer than 30 characters.", jsonFieldError(jsonRes(rec.Body), "Username"))
 	assert.Empty(t, jsonRes(rec.Body)["jwt"])
 }
 
 func TestSignUpExistingUsername(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
 
 	body := userJSON(store.User{
 		Username: user.Username,
 		Password: user.Password,
 	})
-	rec := performRequest(router, "POST", "/api/signup", body)
-	assert.Equal(t, http.StatusBadRequest, rec.Code)
-	assert.Equal(t, "Username already exists.", jsonRes(rec.Body)["error"])
+	rec := performRequest(s, "POST", "/api/signup", body)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "conflict", jsonRes(rec.Body)["code"])
+	assert.Equal(t, "Username already exists.", jsonFieldError(jsonRes(rec.Body), "Username"))
 	assert.Empty(t, jsonRes(rec.Body)["jwt"])
 }
 
 func TestSignUpEmptyPassword(t *testing.T) {
-	router := testSetup()
+	t.Parallel()
+	s := testSetup()
 
 	body := userJSON(store.User{
 		Username: "batman",
 		Password: "",
 	})
-	rec := performRequest(router, "POST", "/api/signup", body)
+	rec := performRequest(s, "POST", "/api/signup", body)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Password is required.", jsonFieldError(jsonRes(rec.Body), "Password"))
 	assert.Empty(t, jsonRes(rec.Body)["jwt"])
 }
 
 func TestSignUpShortPassword(t *testing.T) {
-	router := testSetup()
+	t.Parallel()
+	s := testSetup()
 
 	body := userJSON(store.User{
 		Username: "batman",
 		Password: "secret",
 	})
-	rec := performRequest(router, "POST", "/api/signup", body)
+	rec := performRequest(s, "POST", "/api/signup", body)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Password must be longer than or equal 7 characters.", jsonFieldError(jsonRes(rec.Body), "Password"))
 	assert.Empty(t, jsonRes(rec.Body)["jwt"])
 }
 
 func TestSignUpLongPassword(t *testing.T) {
-	router := testSetup()
+	t.Parallel()
+	s := testSetup()
 
 	body := userJSON(store.User{
 		Username: "batman",
 		Password: strings.Repeat("s", 33),
 	})
-	rec := performRequest(router, "POST", "/api/signup", body)
+	rec := performRequest(s, "POST", "/api/signup", body)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 	assert.Equal(t, "Password cannot be longer than 32 characters.", jsonFieldError(jsonRes(rec.Body), "Password"))
 	assert.Empty(t, jsonRes(rec.Body)["jwt"])
 }
 
 func TestSignIn(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
 
 	body := userJSON(store.User{
 		Username: user.Username,
 		Password: user.Password,
 	})
-	rec := performRequest(router, "POST", "/api/signin", body)
+	rec := performRequest(s, "POST", "/api/signin", body)
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Equal(t, "Signed in successfully.", jsonRes(rec.Body)["msg"])
 	assert.NotEmpty(t, jsonRes(rec.Body)["jwt"])
 }
 
+// failingJobs fails to enqueue jobs.
+type failingJobs struct {
+	store.JobRepository
+}
+
+func (failingJobs) Enqueue(ctx context.Context, job *store.Job) error {
+	return errors.New("connection refused")
+}
+
+func TestSignInEventRolledBack(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	hook := &store.Webhook{URL: "https://example.com/hooks", Events: []string{store.EventUserSignedIn}, Secret: "0123456789abcdef"}
+	assert.NoError(t, s.webhooks.Add(context.Background(), user, hook))
+	s.jobs = failingJobs{s.jobs}
+
+	// Delivery isn't saved without job sending it
+	body := userJSON(store.User{Username: user.Username, Password: user.Password})
+	rec := performRequest(s, "POST", "/api/signin", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	deliveries, err := s.webhooks.FetchDeliveries(context.Background(), hook.ID, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, deliveries)
+}
+
 func TestSignInInvalidUsername(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
 
 	body := userJSON(store.User{
 		Username: "invalid",
 		Password: user.Password,
 	})
-	rec := performRequest(router, "POST", "/api/signin", body)
+	rec := performRequest(s, "POST", "/api/signin", body)
 	assert.Equal(t, http.StatusUnauthorized, rec.Code)
 	assert.Equal(t, "Sign in failed.", jsonRes(rec.Body)["error"])
 	assert.Empty(t, jsonRes(rec.Body)["jwt"])
 }
 
 func TestSignInInvalidPassword(t *testing.T) {
-	router := testSetup()
-	user := addTestUser()
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
 
 	body := userJSON(store.User{
 		Username: user.Username,
 		Password: "invalid",
 	})
-	rec := performRequest(router, "POST", "/api/signin", body)
+	rec := performRequest(s, "POST", "/api/signin", body)
 	assert.Equal(t, http.StatusUnauthorized, rec.Code)
 	assert.Equal(t, "Sign in failed.", jsonRes(rec.Body)["error"])
 	assert.Empty(t, jsonRes(rec.Body)["jwt"])
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiVersion registers routes of single API version under /api/<name>.
+// Versions don't inherit routes, every version registers its full route set
+// on its own group. Handlers of routes which didn't change can be shared
+// between versions, only changed routes need new handlers.
+type apiVersion struct {
+	name     string
+	register func(s *Server, api *gin.RouterGroup)
+}
+
+var (
+	v1 = apiVersion{name: "v1", register: (*Server).registerV1}
+
+	apiVersions = []apiVersion{v1}
+	// Version served at unversioned /api routes
+	defaultAPIVersion = v1
+)
+
+// deprecation describes deprecated route.
+type deprecation struct {
+	// Time after which route might stop working, zero if not planned
+	sunset time.Time
+	// Path of route which replaces deprecated one, if there is one
+	successor string
+}
+
+// deprecated adds Deprecation, Sunset and Link headers to responses of
+// deprecated route, so clients can notice it before the route is removed.
+func deprecated(d *deprecation) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Header("Deprecation", "true")
+		if !d.sunset.IsZero() {
+			ctx.Header("Sunset", d.sunset.UTC().Format(http.TimeFormat))
+		}
+		if d.successor != "" {
+			ctx.Header("Link", "<"+d.successor+`>; rel="successor-version"`)
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionedAPI(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	s.addTestPost(user)
+	token := s.generateJWT(user)
+
+	for _, path := range []string{"/api/v1/posts", "/api/posts"} {
+		rec := PerformAuthorizedRequest(s, token, "GET", path, "")
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+		assert.Len(t, jsonDataSlice(rec.Body), 1, path)
+		assert.Empty(t, rec.Header().Get("Deprecation"), path)
+	}
+}
+
+func TestDeprecatedRoute(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	router := gin.New()
+	sunset := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	s.handle(&router.RouterGroup, http.MethodGet, "/old", operation{
+		summary:     "Old route",
+		deprecation: &deprecation{sunset: sunset, successor: "/api/v2/new"},
+	}, func(ctx *gin.Context) { ctx.JSON(http.StatusOK, gin.H{}) })
+
+	rec := performRequest(router, "GET", "/old", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+	assert.Equal(t, "Tue, 01 Jan 2030 00:00:00 GMT", rec.Header().Get("Sunset"))
+	assert.Equal(t, `</api/v2/new>; rel="successor-version"`, rec.Header().Get("Link"))
+
+	doc := s.openAPIDocument()
+	assert.True(t, doc.Paths["/old"]["get"].Deprecated)
+	assert.False(t, doc.Paths["/api/v1/posts"]["get"].Deprecated)
+}
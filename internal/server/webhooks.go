@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"rgb/internal/store"
+	"rgb/internal/webhooks"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Number of most recent deliveries in delivery log
+const deliveryLogSize = 50
+
+type webhookRequest struct {
+	URL    string   `binding:"required,url,max=2000"`
+	Events []string `binding:"required,gt=0,dive,oneof=post.created post.updated post.deleted user.signed_in"`
+	// Key deliveries are signed with
+	Secret string `binding:"required,min=16,max=256"`
+}
+
+// event is JSON body of webhook deliveries.
+type event struct {
+	// Same for every delivery of the event
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// emit saves delivery of event to every user's webhook subscribed to it, and
+// job sending it, and saves event for user's streams. Called with ctx of
+// transaction which saves the change event is about.
+func (s *Server) emit(ctx context.Context, user *store.User, name string, data interface{}) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(event{
+		ID:        hex.EncodeToString(id),
+		Event:     name,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+	if streamedEvents[name] {
+		if err := s.events.Add(ctx, &store.Event{UserID: user.ID, Name: name, Payload: payload}); err != nil {
+			return err
+		}
+	}
+	ids, err := s.webhooks.Enqueue(ctx, user.ID, name, payload)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		job, err := webhooks.NewJob(id)
+		if err != nil {
+			return err
+		}
+		if err := s.jobs.Enqueue(ctx, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withEvent runs save and emits event in one transaction, so event is
+// emitted if and only if the change is saved. Data is marshalled after save,
+// so it includes e.g. IDs of created records.
+func (s *Server) withEvent(ctx context.Context, user *store.User, name string, data interface{}, save func(ctx context.Context) error) error {
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := save(ctx); err != nil {
+			return err
+		}
+		return s.emit(ctx, user, name, data)
+	})
+}
+
+func (s *Server) createWebhook(ctx *gin.Context) {
+	req := ctx.MustGet(gin.BindKey).(*webhookRequest)
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		apiErr := newAPIError(http.StatusBadRequest, CodeValidation, "Request body is not valid.")
+		apiErr.fields = map[string]string{"URL": translate(ctx, "%[1]s is not valid.", translate(ctx, "URL"))}
+		abortWithError(ctx, apiErr)
+		return
+	}
+	if !s.cfg.WebhooksAllowPrivate && webhooks.CheckHost(u.Hostname()) != nil {
+		apiErr := newAPIError(http.StatusBadRequest, CodeValidation, "Request body is not valid.")
+		apiErr.fields = map[string]string{"URL": translate(ctx, "%[1]s must be public address.", translate(ctx, "URL"))}
+		abortWithError(ctx, apiErr)
+		return
+	}
+	user, err := currentUser(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	hook := &store.Webhook{URL: req.URL, Events: req.Events, Secret: req.Secret}
+	if err := s.webhooks.Add(ctx.Request.Context(), user, hook); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  translate(ctx, "Webhook created successfully."),
+		"data": hook,
+	})
+}
+
+func (s *Server) indexWebhooks(ctx *gin.Context) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	hooks, err := s.webhooks.FetchUserWebhooks(ctx.Request.Context(), user)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  translate(ctx, "Webhooks fetched successfully."),
+		"data": hooks,
+	})
+}
+
+func (s *Server) deleteWebhook(ctx *gin.Context) {
+	hook, ok := s.ownWebhook(ctx)
+	if !ok {
+		return
+	}
+	if err := s.webhooks.Delete(ctx.Request.Context(), hook); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": translate(ctx, "Webhook deleted successfully.")})
+}
+
+// indexDeliveries returns log of most recent deliveries to webhook.
+func (s *Server) indexDeliveries(ctx *gin.Context) {
+	hook, ok := s.ownWebhook(ctx)
+	if !ok {
+		return
+	}
+	deliveries, err := s.webhooks.FetchDeliveries(ctx.Request.Context(), hook.ID, deliveryLogSize)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  translate(ctx, "Deliveries fetched successfully."),
+		"data": deliveries,
+	})
+}
+
+// ownWebhook returns webhook from path if it belongs to current user, and
+// aborts the request otherwise.
+func (s *Server) ownWebhook(ctx *gin.Context) (*store.Webhook, bool) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		abortWithError(ctx, newAPIError(http.StatusBadRequest, CodeValidation, "Not valid ID."))
+		return nil, false
+	}
+	user, err := currentUser(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return nil, false
+	}
+	hook, err := s.webhooks.Fetch(ctx.Request.Context(), id)
+	if err != nil {
+		abortWithError(ctx, err)
+		return nil, false
+	}
+	if hook.UserID != user.ID {
+		abortWithError(ctx, store.ErrForbidden)
+		return nil, false
+	}
+	return hook, true
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"rgb/internal/webhooks"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateWebhook(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+
+	body := `{"URL": "https://example.com/hooks", "Events": ["post.created"], "Secret": "0123456789abcdef"}`
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/v1/webhooks", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Webhook created successfully.", jsonRes(rec.Body)["msg"])
+	assert.Equal(t, "https://example.com/hooks", jsonFieldData(jsonRes(rec.Body), "URL"))
+	assert.Nil(t, jsonFieldData(jsonRes(rec.Body), "Secret"))
+
+	rec = PerformAuthorizedRequest(s, token, "GET", "/api/v1/webhooks", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, jsonDataSlice(rec.Body), 1)
+}
+
+func TestCreateWebhookNotValid(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+
+	body := `{"URL": "ftp://example.com/hooks", "Events": ["post.created"], "Secret": "0123456789abcdef"}`
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/webhooks", body)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "URL is not valid.", jsonFieldError(jsonRes(rec.Body), "URL"))
+
+	body = `{"URL": "https://example.com/hooks", "Events": ["post.liked"], "Secret": "secret"}`
+	rec = PerformAuthorizedRequest(s, token, "POST", "/api/webhooks", body)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Events[0] is not valid.", jsonFieldError(jsonRes(rec.Body), "Events[0]"))
+	assert.Equal(t, "Secret must be longer than or equal 16 characters.", jsonFieldError(jsonRes(rec.Body), "Secret"))
+
+	for _, url := range []string{"http://localhost:8080/hooks", "http://169.254.169.254/latest/meta-data", "http://[::1]/hooks", "https://10.0.0.1/hooks"} {
+		body = fmt.Sprintf(`{"URL": %q, "Events": ["post.created"], "Secret": "0123456789abcdef"}`, url)
+		rec = PerformAuthorizedRequest(s, token, "POST", "/api/webhooks", body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, url)
+		assert.Equal(t, "URL must be public address.", jsonFieldError(jsonRes(rec.Body), "URL"))
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	// Receiver listens on loopback address
+	s.cfg.WebhooksAllowPrivate = true
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+
+	received := make(chan *http.Request, 1)
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ = ioutil.ReadAll(req.Body)
+		received <- req
+	}))
+	defer receiver.Close()
+
+	hookBody := fmt.Sprintf(`{"URL": %q, "Events": ["post.created", "post.deleted"], "Secret": "0123456789abcdef"}`, receiver.URL)
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/webhooks", hookBody)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	hookID := int(jsonFieldData(jsonRes(rec.Body), "ID").(float64))
+	post := s.addTestPost(user)
+	// Not subscribed
+	rec = performPatchRequest(s, token, fmt.Sprintf("/api/posts/%d", post.ID), `{"Title": "Gotham at night"}`, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = PerformAuthorizedRequest(s, token, "DELETE", fmt.Sprintf("/api/posts/%d", post.ID), "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, 1, s.newJobRunner().RunDue(context.Background()))
+	req := <-received
+	assert.Equal(t, "post.deleted", req.Header.Get(webhooks.EventHeader))
+	assert.Equal(t, webhooks.Sign("0123456789abcdef", req.Header.Get(webhooks.TimestampHeader), body), req.Header.Get(webhooks.SignatureHeader))
+	var payload event
+	assert.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, "post.deleted", payload.Event)
+	assert.NotEmpty(t, payload.ID)
+	assert.Equal(t, "Gotham at night", payload.Data.(map[string]interface{})["Title"])
+
+	rec = PerformAuthorizedRequest(s, token, "GET", fmt.Sprintf("/api/webhooks/%d/deliveries", hookID), "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	deliveries := jsonDataSlice(rec.Body)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, "delivered", deliveries[0]["Status"])
+		assert.Equal(t, float64(http.StatusOK), deliveries[0]["ResponseStatus"])
+	}
+}
+
+func TestNotOwnedWebhook(t *testing.T) {
+	t.Parallel()
+	s := testSetup()
+	user := s.addTestUser()
+	token := s.generateJWT(user)
+	body := `{"URL": "https://example.com/hooks", "Events": ["post.created"], "Secret": "0123456789abcdef"}`
+	rec := PerformAuthorizedRequest(s, token, "POST", "/api/webhooks", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	token2 := s.generateJWT(s.addTestUser2())
+	rec = PerformAuthorizedRequest(s, token2, "GET", "/api/webhooks/1/deliveries", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = PerformAuthorizedRequest(s, token2, "DELETE", "/api/webhooks/1", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = PerformAuthorizedRequest(s, token, "DELETE", "/api/webhooks/1", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// Roles of users posts are shared with.
+const (
+	// Viewer can read post
+	RoleViewer = "viewer"
+	// Editor can read and update post, but not delete it or share it
+	RoleEditor = "editor"
+)
+
+// Collaborator is user post is shared with.
+type Collaborator struct {
+	tableName struct{} `pg:"post_collaborators,alias:collaborator"`
+
+	PostID int   `pg:",pk"`
+	Post   *Post `json:",omitempty" pg:"rel:has-one"`
+	UserID int   `pg:",pk"`
+	User   *User `json:"-" pg:"rel:has-one"`
+	// Set when collaborator is loaded with user
+	Username  string `json:",omitempty" pg:"-"`
+	Role      string
+	CreatedAt time.Time
+}
+
+var _ pg.AfterSelectHook = (*Collaborator)(nil)
+
+func (c *Collaborator) AfterSelect(ctx context.Context) error {
+	if c.User != nil {
+		c.Username = c.User.Username
+	}
+	return nil
+}
+
+type CollaboratorRepository interface {
+	// Grant shares post with user, replacing role they already have.
+	Grant(ctx context.Context, collaborator *Collaborator) error
+	// Revoke stops sharing post with user. ErrNotFound is returned if post
+	// isn't shared with them.
+	Revoke(ctx context.Context, postID, userID int) error
+	// Role returns role of user on post, or empty string if post isn't
+	// shared with them.
+	Role(ctx context.Context, postID, userID int) (string, error)
+	// FetchPostCollaborators returns users post is shared with, ordered by
+	// username.
+	FetchPostCollaborators(ctx context.Context, postID int) ([]*Collaborator, error)
+	// FetchShared returns posts shared with user, ordered by post ID.
+	FetchShared(ctx context.Context, user *User) ([]*Collaborator, error)
+}
+
+type pgCollaboratorRepository struct {
+	db *pg.DB
+}
+
+func NewCollaboratorRepository(db *pg.DB) CollaboratorRepository {
+	return &pgCollaboratorRepository{db: db}
+}
+
+func (r *pgCollaboratorRepository) Grant(ctx context.Context, collaborator *Collaborator) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	_, err := conn(ctx, r.db).ModelContext(ctx, collaborator).
+		OnConflict("(post_id, user_id) DO UPDATE").
+		Set("role = EXCLUDED.role").
+		Returning("*").
+		Insert()
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error granting post role")
+	}
+	return dbError(err)
+}
+
+func (r *pgCollaboratorRepository) Revoke(ctx context.Context, postID, userID int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	res, err := conn(ctx, r.db).ModelContext(ctx, &Collaborator{PostID: postID, UserID: userID}).
+		WherePK().
+		Delete()
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error revoking post role")
+		return dbError(err)
+	}
+	if res.RowsAffected() == 0 {
+		return notFound(nil)
+	}
+	return nil
+}
+
+func (r *pgCollaboratorRepository) Role(ctx context.Context, postID, userID int) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	collaborator := &Collaborator{PostID: postID, UserID: userID}
+	err := retry(ctx, func() error {
+		return conn(ctx, r.db).ModelContext(ctx, collaborator).Column("role").WherePK().Select()
+	})
+	if errors.Is(err, pg.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error fetching post role")
+		return "", dbError(err)
+	}
+	return collaborator.Role, nil
+}
+
+func (r *pgCollaboratorRepository) FetchPostCollaborators(ctx context.Context, postID int) ([]*Collaborator, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var collaborators []*Collaborator
+	err := retry(ctx, func() error {
+		collaborators = []*Collaborator{}
+		return conn(ctx, r.db).ModelContext(ctx, &collaborators).
+			Relation("User").
+			Where("collaborator.post_id = ?", postID).
+			Order("user.username ASC").
+			Select()
+	})
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error fetching post collaborators")
+		return nil, dbError(err)
+	}
+	return collaborators, nil
+}
+
+func (r *pgCollaboratorRepository) FetchShared(ctx context.Context, user *User) ([]*Collaborator, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var shared []*Collaborator
+	err := retry(ctx, func() error {
+		shared = []*Collaborator{}
+		return conn(ctx, r.db).ModelContext(ctx, &shared).
+			Relation("Post").
+			Where("collaborator.user_id = ?", user.ID).
+			Order("collaborator.post_id ASC").
+			Select()
+	})
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error fetching shared posts")
+		return nil, dbError(err)
+	}
+	return shared, nil
+}
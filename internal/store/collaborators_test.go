@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrantAndRevokeRole(t *testing.T) {
+	testSetup()
+	ctx := context.Background()
+	owner, err := addTestUser()
+	assert.NoError(t, err)
+	post, err := addTestPost(owner)
+	assert.NoError(t, err)
+	user := &User{Username: "robin", Password: "secret123"}
+	assert.NoError(t, users.Add(ctx, user))
+
+	role, err := collaborators.Role(ctx, post.ID, user.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, role)
+	assert.NoError(t, collaborators.Grant(ctx, &Collaborator{PostID: post.ID, UserID: user.ID, Role: RoleViewer}))
+	// Granting again changes role
+	assert.NoError(t, collaborators.Grant(ctx, &Collaborator{PostID: post.ID, UserID: user.ID, Role: RoleEditor}))
+	role, err = collaborators.Role(ctx, post.ID, user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, RoleEditor, role)
+
+	postCollaborators, err := collaborators.FetchPostCollaborators(ctx, post.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, postCollaborators, 1) {
+		assert.Equal(t, "robin", postCollaborators[0].Username)
+		assert.Equal(t, RoleEditor, postCollaborators[0].Role)
+	}
+	shared, err := collaborators.FetchShared(ctx, user)
+	assert.NoError(t, err)
+	if assert.Len(t, shared, 1) {
+		assert.Equal(t, post.Title, shared[0].Post.Title)
+	}
+	shared, err = collaborators.FetchShared(ctx, owner)
+	assert.NoError(t, err)
+	assert.Empty(t, shared)
+
+	assert.NoError(t, collaborators.Revoke(ctx, post.ID, user.ID))
+	err = collaborators.Revoke(ctx, post.ID, user.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestGrantRoleNotExistingUser(t *testing.T) {
+	testSetup()
+	owner, err := addTestUser()
+	assert.NoError(t, err)
+	post, err := addTestPost(owner)
+	assert.NoError(t, err)
+
+	err = collaborators.Grant(context.Background(), &Collaborator{PostID: post.ID, UserID: owner.ID + 1, Role: RoleViewer})
+	assert.ErrorIs(t, err, ErrValidation)
+}
+
+func TestDeletePostRevokesRoles(t *testing.T) {
+	testSetup()
+	ctx := context.Background()
+	owner, err := addTestUser()
+	assert.NoError(t, err)
+	post, err := addTestPost(owner)
+	assert.NoError(t, err)
+	user := &User{Username: "robin", Password: "secret123"}
+	assert.NoError(t, users.Add(ctx, user))
+	assert.NoError(t, collaborators.Grant(ctx, &Collaborator{PostID: post.ID, UserID: user.ID, Role: RoleViewer}))
+
+	assert.NoError(t, posts.Delete(ctx, post))
+	shared, err := collaborators.FetchShared(ctx, user)
+	assert.NoError(t, err)
+	assert.Empty(t, shared)
+}
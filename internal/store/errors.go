@@ -0,0 +1,81 @@
+package store
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// Kinds of errors returned by store. Check for them with errors.Is, since
+// returned errors carry more specific message.
+var (
+	ErrNotFound   = errors.New("Not found.")
+	ErrConflict   = errors.New("Already exists.")
+	ErrForbidden  = errors.New("Not authorized.")
+	ErrValidation = errors.New("Not valid.")
+)
+
+// Error is store error with message which can be shown to users.
+type Error struct {
+	// One of Err* kinds
+	Kind    error
+	Message string
+	// Field which caused the error, if known
+	Field string
+	// Underlying error, e.g. from database
+	Err error
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func (e *Error) Is(target error) bool { return target == e.Kind }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+func notFound(err error) error {
+	return &Error{Kind: ErrNotFound, Message: ErrNotFound.Error(), Err: err}
+}
+
+func conflict(field string, err error) error {
+	return &Error{Kind: ErrConflict, Message: field + " already exists.", Field: field, Err: err}
+}
+
+// stale returns error for update of record which was modified in the
+// meantime.
+func stale(err error) error {
+	return &Error{Kind: ErrConflict, Message: "Modified in the meantime.", Err: err}
+}
+
+// dbError converts database error to store error. Errors which users can't
+// do anything about are returned unchanged.
+func dbError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, pg.ErrNoRows) {
+		return notFound(err)
+	}
+	var pgErr pg.Error
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch code := pgErr.Field('C'); {
+	case code == "23505": // unique_violation
+		return conflict(extractColumnName(pgErr.Field('n')), err)
+	case strings.HasPrefix(code, "23"): // Class 23 - integrity constraint violation
+		return &Error{Kind: ErrValidation, Message: ErrValidation.Error(), Err: err}
+	}
+	return err
+}
+
+// extractColumnName returns column name from constraint name created by
+// Postgres, e.g. "Username" from "users_username_key".
+func extractColumnName(constraint string) string {
+	reg := regexp.MustCompile(`.+_(.+)_.+`)
+	if reg.MatchString(constraint) {
+		return strings.Title(reg.FindStringSubmatch(constraint)[1])
+	}
+	return "Unknown"
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePGError map[byte]string
+
+func (e fakePGError) Error() string { return e['M'] }
+
+func (e fakePGError) Field(field byte) string { return e[field] }
+
+func (e fakePGError) IntegrityViolation() bool { return e['C'][:2] == "23" }
+
+func TestDBErrorNotFound(t *testing.T) {
+	err := dbError(fmt.Errorf("query: %w", pg.ErrNoRows))
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, err, pg.ErrNoRows)
+	assert.Equal(t, "Not found.", err.Error())
+}
+
+func TestDBErrorUniqueViolation(t *testing.T) {
+	err := dbError(fakePGError{'C': "23505", 'n': "users_username_key"})
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, "Username already exists.", err.Error())
+	var storeErr *Error
+	assert.True(t, errors.As(err, &storeErr))
+	assert.Equal(t, "Username", storeErr.Field)
+}
+
+func TestDBErrorConstraintViolation(t *testing.T) {
+	err := dbError(fakePGError{'C': "23503"})
+	assert.ErrorIs(t, err, ErrValidation)
+}
+
+func TestDBErrorOther(t *testing.T) {
+	pgErr := fakePGError{'C': "40001"}
+	assert.Equal(t, pgErr, dbError(pgErr))
+	assert.True(t, isSerializationFailure(dbError(pgErr)))
+	assert.Nil(t, dbError(nil))
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// EventsChannel is Postgres notification channel of added events. Payload
+// of notifications is ID of the user event belongs to.
+const EventsChannel = "events"
+
+// First key of transaction level advisory locks serializing adding events of
+// the same user, second one is user ID.
+const eventsLockKey = 7241812
+
+// Event is change streamed to clients of the user it belongs to.
+type Event struct {
+	ID      int64
+	UserID  int
+	Name    string
+	Payload json.RawMessage `pg:",type:jsonb"`
+	// Set by database
+	CreatedAt time.Time
+}
+
+type EventRepository interface {
+	// Add saves event. Listeners are notified when transaction which added
+	// it commits. Transactions adding events of the same user wait for each
+	// other, so events are committed in ID order.
+	Add(ctx context.Context, event *Event) error
+	// FetchAfter returns at most limit oldest user's events with ID greater
+	// than afterID.
+	FetchAfter(ctx context.Context, userID int, afterID int64, limit int) ([]*Event, error)
+	// LastID returns ID of the most recent user's event, 0 if there are none.
+	LastID(ctx context.Context, userID int) (int64, error)
+	// Listen calls fn with user ID of every added event until ctx is done or
+	// listening fails. Notifications can be lost, e.g. while connection is
+	// being reestablished, so listeners should also check for new events
+	// periodically.
+	Listen(ctx context.Context, fn func(userID int)) error
+	// Purge deletes events created before given time and returns their
+	// number.
+	Purge(ctx context.Context, before time.Time) (int, error)
+}
+
+type pgEventRepository struct {
+	db *pg.DB
+}
+
+func NewEventRepository(db *pg.DB) EventRepository {
+	return &pgEventRepository{db: db}
+}
+
+func (r *pgEventRepository) Add(ctx context.Context, event *Event) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	// IDs are allocated on insert, not on commit. Without the lock, event
+	// committed after one with greater ID would be skipped by streams which
+	// already sent the greater ID.
+	err := NewTransactor(r.db).WithTx(ctx, func(ctx context.Context) error {
+		_, err := conn(ctx, r.db).ExecContext(ctx, "SELECT pg_advisory_xact_lock(?, ?)", eventsLockKey, event.UserID)
+		if err != nil {
+			return err
+		}
+		// Trigger notifies listeners
+		_, err = conn(ctx, r.db).ModelContext(ctx, event).Returning("*").Insert()
+		return err
+	})
+	if err != nil {
+		logger(ctx).Error().Err(err).Str("event", event.Name).Msg("Error inserting event")
+	}
+	return dbError(err)
+}
+
+func (r *pgEventRepository) FetchAfter(ctx context.Context, userID int, afterID int64, limit int) ([]*Event, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var events []*Event
+	err := retry(ctx, func() error {
+		events = []*Event{}
+		return conn(ctx, r.db).ModelContext(ctx, &events).
+			Where("user_id = ?", userID).
+			Where("id > ?", afterID).
+			Order("id ASC").
+			Limit(limit).
+			Select()
+	})
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error fetching events")
+		return nil, dbError(err)
+	}
+	return events, nil
+}
+
+func (r *pgEventRepository) LastID(ctx context.Context, userID int) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var id int64
+	err := retry(ctx, func() error {
+		return conn(ctx, r.db).ModelContext(ctx, (*Event)(nil)).
+			ColumnExpr("coalesce(max(id), 0)").
+			Where("user_id = ?", userID).
+			Select(&id)
+	})
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error fetching last event ID")
+		return 0, dbError(err)
+	}
+	return id, nil
+}
+
+func (r *pgEventRepository) Listen(ctx context.Context, fn func(userID int)) error {
+	listener := r.db.Listen(ctx)
+	defer listener.Close()
+	// Listener reconnects on its own once it is listening
+	if err := listener.Listen(ctx, EventsChannel); err != nil {
+		logger(ctx).Error().Err(err).Msg("Error listening for events")
+		return err
+	}
+	notifications := listener.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case notification, ok := <-notifications:
+			if !ok {
+				return errors.New("events listener closed")
+			}
+			userID, err := strconv.Atoi(notification.Payload)
+			if err != nil {
+				logger(ctx).Warn().Str("payload", notification.Payload).Msg("Not valid event notification")
+				continue
+			}
+			fn(userID)
+		}
+	}
+}
+
+func (r *pgEventRepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	res, err := conn(ctx, r.db).ModelContext(ctx, (*Event)(nil)).Where("created_at < ?", before).Delete()
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error purging events")
+		return 0, dbError(err)
+	}
+	return res.RowsAffected(), nil
+}
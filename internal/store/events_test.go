@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddAndFetchEvents(t *testing.T) {
+	testSetup()
+	ctx := context.Background()
+	user, err := addTestUser()
+	assert.NoError(t, err)
+
+	lastID, err := events.LastID(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Zero(t, lastID)
+	for _, name := range []string{EventPostCreated, EventPostUpdated, EventPostDeleted} {
+		event := &Event{UserID: user.ID, Name: name, Payload: json.RawMessage(`{"event":"` + name + `"}`)}
+		assert.NoError(t, events.Add(ctx, event))
+	}
+
+	fetched, err := events.FetchAfter(ctx, user.ID, 1, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, fetched, 2) {
+		assert.Equal(t, int64(2), fetched[0].ID)
+		assert.Equal(t, EventPostUpdated, fetched[0].Name)
+		assert.JSONEq(t, `{"event":"post.updated"}`, string(fetched[0].Payload))
+	}
+	lastID, err = events.LastID(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), lastID)
+
+	purged, err := events.Purge(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, purged)
+}
+
+func TestListenEvents(t *testing.T) {
+	testSetup()
+	user, err := addTestUser()
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notified := make(chan int, 1)
+	go func() { _ = events.Listen(ctx, func(userID int) { notified <- userID }) }()
+	// Give listener time to start listening
+	time.Sleep(100 * time.Millisecond)
+
+	assert.NoError(t, events.Add(ctx, &Event{UserID: user.ID, Name: EventPostCreated, Payload: json.RawMessage(`{}`)}))
+	select {
+	case userID := <-notified:
+		assert.Equal(t, user.ID, userID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Not notified")
+	}
+}
+
+func TestEventsCommittedInIDOrder(t *testing.T) {
+	testSetup()
+	ctx := context.Background()
+	user, err := addTestUser()
+	assert.NoError(t, err)
+
+	added := make(chan struct{})
+	commit := make(chan struct{})
+	first := make(chan error, 1)
+	go func() {
+		first <- transactor.WithTx(ctx, func(ctx context.Context) error {
+			if err := events.Add(ctx, &Event{UserID: user.ID, Name: EventPostCreated, Payload: json.RawMessage(`{}`)}); err != nil {
+				return err
+			}
+			close(added)
+			<-commit
+			return nil
+		})
+	}()
+	select {
+	case <-added:
+	case err := <-first:
+		t.Fatalf("First transaction failed: %v", err)
+	}
+
+	// Event with greater ID can't be committed while the first one isn't
+	second := make(chan error, 1)
+	go func() {
+		second <- transactor.WithTx(ctx, func(ctx context.Context) error {
+			return events.Add(ctx, &Event{UserID: user.ID, Name: EventPostUpdated, Payload: json.RawMessage(`{}`)})
+		})
+	}()
+	select {
+	case err := <-second:
+		t.Fatalf("Second transaction committed first: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	fetched, err := events.FetchAfter(ctx, user.ID, 0, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, fetched)
+
+	close(commit)
+	assert.NoError(t, <-first)
+	assert.NoError(t, <-second)
+	fetched, err = events.FetchAfter(ctx, user.ID, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, fetched, 2) {
+		assert.Equal(t, EventPostCreated, fetched[0].Name)
+		assert.Equal(t, EventPostUpdated, fetched[1].Name)
+	}
+}
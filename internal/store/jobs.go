@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// Statuses of background jobs.
+const (
+	JobPending = "pending"
+	JobDone    = "done"
+	// Job failed too many times and won't be run again
+	JobFailed = "failed"
+)
+
+// Job is unit of background work, run by handler registered for its type.
+type Job struct {
+	ID      int
+	Type    string
+	Payload json.RawMessage `pg:",type:jsonb"`
+	// Only one pending job can have the same key, empty if job isn't unique
+	Key      string
+	Status   string
+	Attempts int `pg:",use_zero"`
+	// Time when job is due. Claimed jobs are leased by moving it forward.
+	RunAt      time.Time
+	LastError  string
+	CreatedAt  time.Time
+	FinishedAt *time.Time
+}
+
+type JobRepository interface {
+	// Enqueue saves pending job, due immediately if RunAt is not set. It
+	// should be called in the same transaction as the change job is about,
+	// so job exists if and only if the change is saved. Job with the same key
+	// as another pending job is not saved.
+	Enqueue(ctx context.Context, job *Job) error
+	// Claim returns at most limit due pending jobs of given types, counts
+	// attempt, and postpones them by lease, so they aren't claimed again
+	// while running. Job whose lease expired, e.g. because instance
+	// running it crashed, is claimed again.
+	Claim(ctx context.Context, types []string, limit int, lease time.Duration) ([]*Job, error)
+	// Update saves status, attempts, due time, error and finish time of job
+	// claimed with given attempt. ErrConflict is returned if job was claimed
+	// again in the meantime, e.g. because its lease expired.
+	Update(ctx context.Context, job *Job, attempt int) error
+	Fetch(ctx context.Context, id int) (*Job, error)
+	// Purge deletes jobs finished before given time and returns their number.
+	Purge(ctx context.Context, before time.Time) (int, error)
+}
+
+type pgJobRepository struct {
+	db *pg.DB
+}
+
+func NewJobRepository(db *pg.DB) JobRepository {
+	return &pgJobRepository{db: db}
+}
+
+func (r *pgJobRepository) Enqueue(ctx context.Context, job *Job) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	q := conn(ctx, r.db).ModelContext(ctx, job)
+	if job.Key != "" {
+		q = q.OnConflict("(key) WHERE status = 'pending' DO NOTHING")
+	}
+	_, err := q.Returning("*").Insert()
+	// Job whose key is taken isn't inserted, so nothing is returned
+	if err != nil && !errors.Is(err, pg.ErrNoRows) {
+		logger(ctx).Error().Err(err).Str("type", job.Type).Msg("Error enqueueing job")
+		return dbError(err)
+	}
+	return nil
+}
+
+func (r *pgJobRepository) Claim(ctx context.Context, types []string, limit int, lease time.Duration) ([]*Job, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	jobs := []*Job{}
+	// SKIP LOCKED lets every instance claim different jobs without waiting
+	// for each other
+	_, err := conn(ctx, r.db).QueryContext(ctx, &jobs, `
+		UPDATE jobs SET run_at = now() + ? * interval '1 millisecond', attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE status = ? AND run_at <= now() AND type IN (?)
+			ORDER BY run_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		lease.Milliseconds(), JobPending, pg.In(types), limit)
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error claiming jobs")
+		return nil, dbError(err)
+	}
+	return jobs, nil
+}
+
+func (r *pgJobRepository) Update(ctx context.Context, job *Job, attempt int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	res, err := conn(ctx, r.db).ModelContext(ctx, job).
+		Column("status", "attempts", "run_at", "last_error", "finished_at").
+		WherePK().
+		Where("attempts = ?", attempt).
+		Update()
+	if err == nil && res.RowsAffected() == 0 {
+		return stale(nil)
+	}
+	if err != nil {
+		logger(ctx).Error().Err(err).Int("job_id", job.ID).Msg("Error updating job")
+	}
+	return dbError(err)
+}
+
+func (r *pgJobRepository) Fetch(ctx context.Context, id int) (*Job, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	job := &Job{ID: id}
+	err := retry(ctx, func() error {
+		return conn(ctx, r.db).ModelContext(ctx, job).WherePK().Select()
+	})
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error fetching job")
+		return nil, dbError(err)
+	}
+	return job, nil
+}
+
+func (r *pgJobRepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	res, err := conn(ctx, r.db).ModelContext(ctx, (*Job)(nil)).
+		Where("status != ?", JobPending).
+		Where("finished_at < ?", before).
+		Delete()
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error purging jobs")
+		return 0, dbError(err)
+	}
+	return res.RowsAffected(), nil
+}
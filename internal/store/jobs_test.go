@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnqueueAndClaimJobs(t *testing.T) {
+	testSetup()
+	ctx := context.Background()
+
+	job := &Job{Type: "test", Payload: json.RawMessage(`{"ID":1}`), Key: "unique"}
+	assert.NoError(t, jobs.Enqueue(ctx, job))
+	assert.Equal(t, 1, job.ID)
+	assert.Equal(t, JobPending, job.Status)
+	// Pending job with the same key already exists
+	duplicate := &Job{Type: "test", Key: "unique"}
+	assert.NoError(t, jobs.Enqueue(ctx, duplicate))
+	assert.Zero(t, duplicate.ID)
+	// Not due yet
+	assert.NoError(t, jobs.Enqueue(ctx, &Job{Type: "test", RunAt: time.Now().Add(time.Hour)}))
+	assert.NoError(t, jobs.Enqueue(ctx, &Job{Type: "other"}))
+
+	claimed, err := jobs.Claim(ctx, []string{"test"}, 10, time.Minute)
+	assert.NoError(t, err)
+	if assert.Len(t, claimed, 1) {
+		assert.Equal(t, job.ID, claimed[0].ID)
+		assert.Equal(t, 1, claimed[0].Attempts)
+		assert.JSONEq(t, `{"ID":1}`, string(claimed[0].Payload))
+	}
+	// Claimed jobs are leased
+	again, err := jobs.Claim(ctx, []string{"test"}, 10, time.Minute)
+	assert.NoError(t, err)
+	assert.Empty(t, again)
+
+	finished := time.Now().Add(-time.Hour)
+	job = claimed[0]
+	job.Status = JobDone
+	job.FinishedAt = &finished
+	// Update of attempt which lost its lease is rejected
+	assert.ErrorIs(t, jobs.Update(ctx, job, 2), ErrConflict)
+	assert.NoError(t, jobs.Update(ctx, job, 1))
+	fetched, err := jobs.Fetch(ctx, job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, JobDone, fetched.Status)
+
+	purged, err := jobs.Purge(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+}
@@ -1,18 +1,43 @@
 package store
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	transactor    Transactor
+	users         UserRepository
+	posts         PostRepository
+	webhooks      WebhookRepository
+	jobs          JobRepository
+	events        EventRepository
+	collaborators CollaboratorRepository
+)
 
 func testSetup() {
 	gin.SetMode(gin.TestMode)
-	ResetTestDatabase()
+	db := ResetTestDatabase()
+	transactor = NewTransactor(db)
+	users = NewUserRepository(db)
+	posts = NewPostRepository(db)
+	webhooks = NewWebhookRepository(db)
+	jobs = NewJobRepository(db)
+	events = NewEventRepository(db)
+	collaborators = NewCollaboratorRepository(db)
 }
 
 func addTestUser() (*User, error) {
+	return addTestUserCtx(context.Background())
+}
+
+func addTestUserCtx(ctx context.Context) (*User, error) {
 	user := &User{
 		Username: "batman",
 		Password: "secret123",
 	}
-	err := AddUser(user)
+	err := users.Add(ctx, user)
 	return user, err
 }
 
@@ -21,6 +46,16 @@ func addTestPost(user *User) (*Post, error) {
 		Title:   "Gotham cronicles",
 		Content: "Joker is planning big hit tonight.",
 	}
-	err := AddPost(user, post)
+	err := posts.Add(context.Background(), user, post)
 	return post, err
 }
+
+func addTestWebhook(user *User) (*Webhook, error) {
+	hook := &Webhook{
+		URL:    "http://localhost:9000/hooks",
+		Events: []string{EventPostCreated, EventPostDeleted},
+		Secret: "0123456789abcdef",
+	}
+	err := webhooks.Add(context.Background(), user, hook)
+	return hook, err
+}
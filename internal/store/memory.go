@@ -0,0 +1,845 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// In-memory repositories mirror behaviour of Postgres ones, including error
+// messages, so handlers can be tested without database.
+
+type memoryRepository interface {
+	// snapshot saves current data and returns function which restores it.
+	snapshot() (restore func())
+}
+
+type memoryTxKey struct{}
+
+// memoryTransactor rolls back changes by restoring repositories' data saved
+// when transaction started. Transactions are serialized, but unlike in
+// database, they are not isolated from changes made outside of them.
+type memoryTransactor struct {
+	mu    sync.Mutex
+	repos []memoryRepository
+}
+
+func (t *memoryTransactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	// Nested transaction already holds the lock and acts as savepoint
+	if ctx.Value(memoryTxKey{}) == nil {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		ctx = context.WithValue(ctx, memoryTxKey{}, true)
+	}
+	restores := make([]func(), len(t.repos))
+	for i, repo := range t.repos {
+		restores[i] = repo.snapshot()
+	}
+	rollback := func() {
+		for _, restore := range restores {
+			restore()
+		}
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			rollback()
+			panic(recovered)
+		}
+		if err != nil {
+			rollback()
+		}
+	}()
+	return fn(ctx)
+}
+
+type memoryUserRepository struct {
+	mu     sync.RWMutex
+	lastID int
+	users  map[int]*User
+}
+
+func NewMemoryUserRepository() UserRepository {
+	return &memoryUserRepository{users: map[int]*User{}}
+}
+
+func (r *memoryUserRepository) snapshot() func() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	lastID := r.lastID
+	users := make(map[int]*User, len(r.users))
+	for id, user := range r.users {
+		copied := *user
+		users[id] = &copied
+	}
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.lastID = lastID
+		r.users = users
+	}
+}
+
+func (r *memoryUserRepository) Add(ctx context.Context, user *User) error {
+	if err := hashPassword(ctx, user); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.users {
+		if existing.Username == user.Username {
+			return conflict("Username", nil)
+		}
+	}
+	r.lastID++
+	now := time.Now()
+	user.ID = r.lastID
+	user.CreatedAt = now
+	user.ModifiedAt = now
+	r.users[user.ID] = copyUser(user)
+	return nil
+}
+
+func (r *memoryUserRepository) Authenticate(ctx context.Context, username, password string) (*User, error) {
+	user, err := r.FetchByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPassword(ctx, user, password); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *memoryUserRepository) Fetch(ctx context.Context, id int) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, notFound(nil)
+	}
+	return copyUser(user), nil
+}
+
+func (r *memoryUserRepository) FetchByUsername(ctx context.Context, username string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, user := range r.users {
+		if user.Username == username {
+			return copyUser(user), nil
+		}
+	}
+	return nil, notFound(nil)
+}
+
+// copyUser returns user as it would be read from database, without plain
+// text password and loaded posts.
+func copyUser(user *User) *User {
+	copied := *user
+	copied.Password = ""
+	copied.Posts = []*Post{}
+	return &copied
+}
+
+type memoryPostRepository struct {
+	mu     sync.RWMutex
+	lastID int
+	posts  map[int]*Post
+}
+
+func NewMemoryPostRepository() PostRepository {
+	return &memoryPostRepository{posts: map[int]*Post{}}
+}
+
+func (r *memoryPostRepository) snapshot() func() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	lastID := r.lastID
+	posts := make(map[int]*Post, len(r.posts))
+	for id, post := range r.posts {
+		copied := *post
+		posts[id] = &copied
+	}
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.lastID = lastID
+		r.posts = posts
+	}
+}
+
+func (r *memoryPostRepository) Add(ctx context.Context, user *User, post *Post) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastID++
+	now := time.Now()
+	post.ID = r.lastID
+	post.UserID = user.ID
+	setPublishedAt(post)
+	// Same as database defaults, which are only used for zero values
+	if post.CreatedAt.IsZero() {
+		post.CreatedAt = now
+	}
+	if post.ModifiedAt.IsZero() {
+		post.ModifiedAt = now
+	}
+	post.Version = 1
+	copied := *post
+	r.posts[post.ID] = &copied
+	return nil
+}
+
+func (r *memoryPostRepository) FetchUserPosts(ctx context.Context, user *User) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	posts := []*Post{}
+	for _, post := range r.posts {
+		if post.UserID == user.ID {
+			copied := *post
+			posts = append(posts, &copied)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+	user.Posts = posts
+	return nil
+}
+
+func (r *memoryPostRepository) EachUserPost(ctx context.Context, user *User, fn func(*Post) error) error {
+	// Posts are copied first, so fn can use repository
+	copied := &User{ID: user.ID}
+	if err := r.FetchUserPosts(ctx, copied); err != nil {
+		return err
+	}
+	for _, post := range copied.Posts {
+		if err := fn(post); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memoryPostRepository) FetchPublished(ctx context.Context, userID, limit int) ([]*Post, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	posts := []*Post{}
+	for _, post := range r.posts {
+		if post.Published && (userID == 0 || post.UserID == userID) {
+			copied := *post
+			posts = append(posts, &copied)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		if !posts[i].PublishedAt.Equal(*posts[j].PublishedAt) {
+			return posts[i].PublishedAt.After(*posts[j].PublishedAt)
+		}
+		return posts[i].ID > posts[j].ID
+	})
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+	return posts, nil
+}
+
+func (r *memoryPostRepository) Fetch(ctx context.Context, id int) (*Post, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	post, ok := r.posts[id]
+	if !ok {
+		return nil, notFound(nil)
+	}
+	copied := *post
+	return &copied, nil
+}
+
+func (r *memoryPostRepository) Update(ctx context.Context, post *Post) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.posts[post.ID]
+	if !ok || stored.Version != post.Version {
+		return stale(nil)
+	}
+	post.Version++
+	stored.Title = post.Title
+	stored.Content = post.Content
+	if !post.Published {
+		stored.PublishedAt = nil
+	} else if stored.PublishedAt == nil {
+		modifiedAt := post.ModifiedAt
+		stored.PublishedAt = &modifiedAt
+	}
+	post.PublishedAt = stored.PublishedAt
+	stored.Published = post.Published
+	stored.PublishAt = post.PublishAt
+	stored.ModifiedAt = post.ModifiedAt
+	stored.Version = post.Version
+	return nil
+}
+
+func (r *memoryPostRepository) FetchScheduled(ctx context.Context, user *User) ([]*Post, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	posts := []*Post{}
+	for _, post := range r.posts {
+		if post.UserID == user.ID && post.PublishAt != nil {
+			copied := *post
+			posts = append(posts, &copied)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		if !posts[i].PublishAt.Equal(*posts[j].PublishAt) {
+			return posts[i].PublishAt.Before(*posts[j].PublishAt)
+		}
+		return posts[i].ID < posts[j].ID
+	})
+	return posts, nil
+}
+
+func (r *memoryPostRepository) PublishDue(ctx context.Context, now time.Time) ([]*Post, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	posts := []*Post{}
+	for _, post := range r.posts {
+		if post.PublishAt != nil && !post.PublishAt.After(now) {
+			publishedAt := now
+			post.Published = true
+			post.PublishedAt = &publishedAt
+			post.PublishAt = nil
+			post.ModifiedAt = now
+			post.Version++
+			copied := *post
+			posts = append(posts, &copied)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+	return posts, nil
+}
+
+func (r *memoryPostRepository) Delete(ctx context.Context, post *Post) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.posts, post.ID)
+	return nil
+}
+
+type memoryWebhookRepository struct {
+	mu             sync.RWMutex
+	lastID         int
+	hooks          map[int]*Webhook
+	lastDeliveryID int
+	deliveries     map[int]*Delivery
+}
+
+func NewMemoryWebhookRepository() WebhookRepository {
+	return &memoryWebhookRepository{hooks: map[int]*Webhook{}, deliveries: map[int]*Delivery{}}
+}
+
+func (r *memoryWebhookRepository) snapshot() func() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	lastID, lastDeliveryID := r.lastID, r.lastDeliveryID
+	hooks := make(map[int]*Webhook, len(r.hooks))
+	for id, hook := range r.hooks {
+		hooks[id] = copyWebhook(hook)
+	}
+	deliveries := make(map[int]*Delivery, len(r.deliveries))
+	for id, delivery := range r.deliveries {
+		copied := *delivery
+		deliveries[id] = &copied
+	}
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.lastID, r.lastDeliveryID = lastID, lastDeliveryID
+		r.hooks = hooks
+		r.deliveries = deliveries
+	}
+}
+
+func copyWebhook(hook *Webhook) *Webhook {
+	copied := *hook
+	copied.Events = append([]string{}, hook.Events...)
+	return &copied
+}
+
+func (r *memoryWebhookRepository) Add(ctx context.Context, user *User, hook *Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastID++
+	hook.ID = r.lastID
+	hook.UserID = user.ID
+	hook.CreatedAt = time.Now()
+	r.hooks[hook.ID] = copyWebhook(hook)
+	return nil
+}
+
+func (r *memoryWebhookRepository) FetchUserWebhooks(ctx context.Context, user *User) ([]*Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	hooks := []*Webhook{}
+	for _, hook := range r.hooks {
+		if hook.UserID == user.ID {
+			hooks = append(hooks, copyWebhook(hook))
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+	return hooks, nil
+}
+
+func (r *memoryWebhookRepository) Fetch(ctx context.Context, id int) (*Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	hook, ok := r.hooks[id]
+	if !ok {
+		return nil, notFound(nil)
+	}
+	return copyWebhook(hook), nil
+}
+
+func (r *memoryWebhookRepository) Delete(ctx context.Context, hook *Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.hooks, hook.ID)
+	// Same as ON DELETE CASCADE
+	for id, delivery := range r.deliveries {
+		if delivery.WebhookID == hook.ID {
+			delete(r.deliveries, id)
+		}
+	}
+	return nil
+}
+
+func (r *memoryWebhookRepository) Enqueue(ctx context.Context, userID int, event string, payload []byte) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	hookIDs := make([]int, 0, len(r.hooks))
+	for id := range r.hooks {
+		hookIDs = append(hookIDs, id)
+	}
+	sort.Ints(hookIDs)
+	ids := []int{}
+	for _, id := range hookIDs {
+		hook := r.hooks[id]
+		if hook.UserID != userID || !containsString(hook.Events, event) {
+			continue
+		}
+		r.lastDeliveryID++
+		r.deliveries[r.lastDeliveryID] = &Delivery{
+			ID:        r.lastDeliveryID,
+			WebhookID: hook.ID,
+			Event:     event,
+			Payload:   append([]byte{}, payload...),
+			Status:    DeliveryPending,
+			CreatedAt: now,
+		}
+		ids = append(ids, r.lastDeliveryID)
+	}
+	return ids, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryWebhookRepository) FetchDelivery(ctx context.Context, id int) (*Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	delivery, ok := r.deliveries[id]
+	if !ok {
+		return nil, notFound(nil)
+	}
+	copied := *delivery
+	copied.Webhook = copyWebhook(r.hooks[delivery.WebhookID])
+	return &copied, nil
+}
+
+func (r *memoryWebhookRepository) UpdateDelivery(ctx context.Context, delivery *Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.deliveries[delivery.ID]
+	if !ok {
+		return nil
+	}
+	stored.Status = delivery.Status
+	stored.Attempts = delivery.Attempts
+	stored.ResponseStatus = delivery.ResponseStatus
+	stored.LastError = delivery.LastError
+	stored.DeliveredAt = delivery.DeliveredAt
+	return nil
+}
+
+func (r *memoryWebhookRepository) FetchDeliveries(ctx context.Context, webhookID, limit int) ([]*Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	deliveries := []*Delivery{}
+	for _, delivery := range r.deliveries {
+		if delivery.WebhookID == webhookID {
+			copied := *delivery
+			deliveries = append(deliveries, &copied)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+type memoryJobRepository struct {
+	mu     sync.RWMutex
+	lastID int
+	jobs   map[int]*Job
+}
+
+func NewMemoryJobRepository() JobRepository {
+	return &memoryJobRepository{jobs: map[int]*Job{}}
+}
+
+func (r *memoryJobRepository) snapshot() func() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	lastID := r.lastID
+	jobs := make(map[int]*Job, len(r.jobs))
+	for id, job := range r.jobs {
+		jobs[id] = copyJob(job)
+	}
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.lastID = lastID
+		r.jobs = jobs
+	}
+}
+
+func copyJob(job *Job) *Job {
+	copied := *job
+	copied.Payload = append(json.RawMessage{}, job.Payload...)
+	return &copied
+}
+
+func (r *memoryJobRepository) Enqueue(ctx context.Context, job *Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if job.Key != "" {
+		for _, existing := range r.jobs {
+			if existing.Key == job.Key && existing.Status == JobPending {
+				return nil
+			}
+		}
+	}
+	r.lastID++
+	now := time.Now()
+	job.ID = r.lastID
+	job.Status = JobPending
+	job.CreatedAt = now
+	if job.RunAt.IsZero() {
+		job.RunAt = now
+	}
+	if len(job.Payload) == 0 {
+		job.Payload = json.RawMessage(`{}`)
+	}
+	r.jobs[job.ID] = copyJob(job)
+	return nil
+}
+
+func (r *memoryJobRepository) Claim(ctx context.Context, types []string, limit int, lease time.Duration) ([]*Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	due := []*Job{}
+	for _, job := range r.jobs {
+		if job.Status == JobPending && !job.RunAt.After(now) && containsString(types, job.Type) {
+			due = append(due, job)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].RunAt.Equal(due[j].RunAt) {
+			return due[i].RunAt.Before(due[j].RunAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	claimed := make([]*Job, len(due))
+	for i, job := range due {
+		job.RunAt = now.Add(lease)
+		job.Attempts++
+		claimed[i] = copyJob(job)
+	}
+	return claimed, nil
+}
+
+func (r *memoryJobRepository) Update(ctx context.Context, job *Job, attempt int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.jobs[job.ID]
+	if !ok || stored.Attempts != attempt {
+		return stale(nil)
+	}
+	stored.Status = job.Status
+	stored.Attempts = job.Attempts
+	stored.RunAt = job.RunAt
+	stored.LastError = job.LastError
+	stored.FinishedAt = job.FinishedAt
+	return nil
+}
+
+func (r *memoryJobRepository) Fetch(ctx context.Context, id int) (*Job, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, notFound(nil)
+	}
+	return copyJob(job), nil
+}
+
+func (r *memoryJobRepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	purged := 0
+	for id, job := range r.jobs {
+		if job.Status != JobPending && job.FinishedAt != nil && job.FinishedAt.Before(before) {
+			delete(r.jobs, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+type memoryEventRepository struct {
+	mu     sync.RWMutex
+	lastID int64
+	events []*Event
+	// Functions of active Listen calls, by their order
+	listeners    map[int]func(userID int)
+	lastListener int
+}
+
+func NewMemoryEventRepository() EventRepository {
+	return &memoryEventRepository{listeners: map[int]func(int){}}
+}
+
+func (r *memoryEventRepository) snapshot() func() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	lastID := r.lastID
+	events := append([]*Event{}, r.events...)
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.lastID = lastID
+		r.events = events
+	}
+}
+
+func (r *memoryEventRepository) Add(ctx context.Context, event *Event) error {
+	r.mu.Lock()
+	r.lastID++
+	event.ID = r.lastID
+	event.CreatedAt = time.Now()
+	copied := *event
+	r.events = append(r.events, &copied)
+	listeners := make([]func(int), 0, len(r.listeners))
+	for _, fn := range r.listeners {
+		listeners = append(listeners, fn)
+	}
+	r.mu.Unlock()
+
+	// Unlike in database, listeners are notified before transaction ends
+	for _, fn := range listeners {
+		fn(event.UserID)
+	}
+	return nil
+}
+
+func (r *memoryEventRepository) FetchAfter(ctx context.Context, userID int, afterID int64, limit int) ([]*Event, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	events := []*Event{}
+	for _, event := range r.events {
+		if event.UserID == userID && event.ID > afterID && len(events) < limit {
+			copied := *event
+			events = append(events, &copied)
+		}
+	}
+	return events, nil
+}
+
+func (r *memoryEventRepository) LastID(ctx context.Context, userID int) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var id int64
+	for _, event := range r.events {
+		if event.UserID == userID {
+			id = event.ID
+		}
+	}
+	return id, nil
+}
+
+func (r *memoryEventRepository) Listen(ctx context.Context, fn func(userID int)) error {
+	r.mu.Lock()
+	r.lastListener++
+	id := r.lastListener
+	r.listeners[id] = fn
+	r.mu.Unlock()
+
+	<-ctx.Done()
+	r.mu.Lock()
+	delete(r.listeners, id)
+	r.mu.Unlock()
+	return ctx.Err()
+}
+
+func (r *memoryEventRepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := []*Event{}
+	for _, event := range r.events {
+		if !event.CreatedAt.Before(before) {
+			kept = append(kept, event)
+		}
+	}
+	purged := len(r.events) - len(kept)
+	r.events = kept
+	return purged, nil
+}
+
+type memoryCollaboratorRepository struct {
+	mu            sync.RWMutex
+	collaborators []*Collaborator
+	// Used to load relations and to skip collaborators on deleted posts,
+	// same as ON DELETE CASCADE
+	users UserRepository
+	posts PostRepository
+}
+
+func NewMemoryCollaboratorRepository(users UserRepository, posts PostRepository) CollaboratorRepository {
+	return &memoryCollaboratorRepository{collaborators: []*Collaborator{}, users: users, posts: posts}
+}
+
+func (r *memoryCollaboratorRepository) snapshot() func() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	collaborators := make([]*Collaborator, len(r.collaborators))
+	for i, collaborator := range r.collaborators {
+		copied := *collaborator
+		collaborators[i] = &copied
+	}
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.collaborators = collaborators
+	}
+}
+
+func (r *memoryCollaboratorRepository) find(postID, userID int) *Collaborator {
+	for _, collaborator := range r.collaborators {
+		if collaborator.PostID == postID && collaborator.UserID == userID {
+			return collaborator
+		}
+	}
+	return nil
+}
+
+func (r *memoryCollaboratorRepository) Grant(ctx context.Context, collaborator *Collaborator) error {
+	// Same as foreign key violations
+	if _, err := r.posts.Fetch(ctx, collaborator.PostID); err != nil {
+		return &Error{Kind: ErrValidation, Message: ErrValidation.Error(), Err: err}
+	}
+	if _, err := r.users.Fetch(ctx, collaborator.UserID); err != nil {
+		return &Error{Kind: ErrValidation, Message: ErrValidation.Error(), Err: err}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing := r.find(collaborator.PostID, collaborator.UserID); existing != nil {
+		existing.Role = collaborator.Role
+		collaborator.CreatedAt = existing.CreatedAt
+		return nil
+	}
+	collaborator.CreatedAt = time.Now()
+	r.collaborators = append(r.collaborators, &Collaborator{
+		PostID:    collaborator.PostID,
+		UserID:    collaborator.UserID,
+		Role:      collaborator.Role,
+		CreatedAt: collaborator.CreatedAt,
+	})
+	return nil
+}
+
+func (r *memoryCollaboratorRepository) Revoke(ctx context.Context, postID, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, collaborator := range r.collaborators {
+		if collaborator.PostID == postID && collaborator.UserID == userID {
+			r.collaborators = append(r.collaborators[:i], r.collaborators[i+1:]...)
+			return nil
+		}
+	}
+	return notFound(nil)
+}
+
+func (r *memoryCollaboratorRepository) Role(ctx context.Context, postID, userID int) (string, error) {
+	if _, err := r.posts.Fetch(ctx, postID); err != nil {
+		return "", nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if collaborator := r.find(postID, userID); collaborator != nil {
+		return collaborator.Role, nil
+	}
+	return "", nil
+}
+
+func (r *memoryCollaboratorRepository) FetchPostCollaborators(ctx context.Context, postID int) ([]*Collaborator, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	collaborators := []*Collaborator{}
+	if _, err := r.posts.Fetch(ctx, postID); err != nil {
+		return collaborators, nil
+	}
+	for _, collaborator := range r.collaborators {
+		if collaborator.PostID != postID {
+			continue
+		}
+		user, err := r.users.Fetch(ctx, collaborator.UserID)
+		if err != nil {
+			continue
+		}
+		copied := *collaborator
+		copied.User = user
+		copied.Username = user.Username
+		collaborators = append(collaborators, &copied)
+	}
+	sort.Slice(collaborators, func(i, j int) bool { return collaborators[i].Username < collaborators[j].Username })
+	return collaborators, nil
+}
+
+func (r *memoryCollaboratorRepository) FetchShared(ctx context.Context, user *User) ([]*Collaborator, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	shared := []*Collaborator{}
+	for _, collaborator := range r.collaborators {
+		if collaborator.UserID != user.ID {
+			continue
+		}
+		post, err := r.posts.Fetch(ctx, collaborator.PostID)
+		if err != nil {
+			continue
+		}
+		copied := *collaborator
+		copied.Post = post
+		shared = append(shared, &copied)
+	}
+	sort.Slice(shared, func(i, j int) bool { return shared[i].PostID < shared[j].PostID })
+	return shared, nil
+}
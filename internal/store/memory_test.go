@@ -0,0 +1,257 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryUsers(t *testing.T) {
+	ctx := context.Background()
+	repos := NewMemoryRepositories()
+	user := &User{Username: "batman", Password: "secret123"}
+	assert.NoError(t, repos.Users.Add(ctx, user))
+	assert.Equal(t, 1, user.ID)
+	assert.NotEmpty(t, user.HashedPassword)
+
+	err := repos.Users.Add(ctx, &User{Username: "batman", Password: "secret123"})
+	assert.EqualError(t, err, "Username already exists.")
+
+	authUser, err := repos.Users.Authenticate(ctx, "batman", "secret123")
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, authUser.ID)
+	assert.Empty(t, authUser.Password)
+
+	_, err = repos.Users.Authenticate(ctx, "batman", "invalid")
+	assert.Error(t, err)
+	_, err = repos.Users.Authenticate(ctx, "invalid", "secret123")
+	assert.EqualError(t, err, "Not found.")
+
+	fetchedUser, err := repos.Users.Fetch(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, user.Username, fetchedUser.Username)
+	_, err = repos.Users.Fetch(ctx, 2)
+	assert.EqualError(t, err, "Not found.")
+	fetchedUser, err = repos.Users.FetchByUsername(ctx, "batman")
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, fetchedUser.ID)
+	_, err = repos.Users.FetchByUsername(ctx, "invalid")
+	assert.EqualError(t, err, "Not found.")
+}
+
+func TestMemoryPosts(t *testing.T) {
+	ctx := context.Background()
+	repos := NewMemoryRepositories()
+	user := &User{ID: 1}
+	post := &Post{Title: "Gotham cronicles", Content: "Joker is planning big hit tonight."}
+	assert.NoError(t, repos.Posts.Add(ctx, user, post))
+	assert.Equal(t, 1, post.ID)
+	assert.Equal(t, user.ID, post.UserID)
+
+	assert.NoError(t, repos.Posts.FetchUserPosts(ctx, user))
+	assert.Equal(t, []*Post{post}, user.Posts)
+	assert.NoError(t, repos.Posts.FetchUserPosts(ctx, &User{ID: 2}))
+	published, err := repos.Posts.FetchPublished(ctx, 0, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, published)
+	publishedPost := &Post{Title: "Justice league meeting", Content: "Darkseid is plotting again.", Published: true}
+	assert.NoError(t, repos.Posts.Add(ctx, &User{ID: 2}, publishedPost))
+	published, err = repos.Posts.FetchPublished(ctx, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Post{publishedPost}, published)
+	published, err = repos.Posts.FetchPublished(ctx, user.ID, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, published)
+
+	var iterated []*Post
+	assert.NoError(t, repos.Posts.EachUserPost(ctx, user, func(post *Post) error {
+		iterated = append(iterated, post)
+		return nil
+	}))
+	assert.Equal(t, []*Post{post}, iterated)
+
+	update := &Post{ID: post.ID, Title: "New title", Content: post.Content, Version: post.Version}
+	assert.NoError(t, repos.Posts.Update(ctx, update))
+	assert.Equal(t, 2, update.Version)
+	fetchedPost, err := repos.Posts.Fetch(ctx, post.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "New title", fetchedPost.Title)
+	assert.Equal(t, post.Content, fetchedPost.Content)
+	assert.Equal(t, 2, fetchedPost.Version)
+	err = repos.Posts.Update(ctx, &Post{ID: post.ID, Title: "Stale title", Version: 1})
+	assert.ErrorIs(t, err, ErrConflict)
+
+	due := time.Now().Add(-time.Minute)
+	update.PublishAt = &due
+	assert.NoError(t, repos.Posts.Update(ctx, update))
+	scheduled, err := repos.Posts.FetchScheduled(ctx, user)
+	assert.NoError(t, err)
+	assert.Len(t, scheduled, 1)
+	published, err = repos.Posts.PublishDue(ctx, time.Now())
+	assert.NoError(t, err)
+	if assert.Len(t, published, 1) {
+		assert.True(t, published[0].Published)
+		assert.NotNil(t, published[0].PublishedAt)
+		assert.Nil(t, published[0].PublishAt)
+	}
+	scheduled, err = repos.Posts.FetchScheduled(ctx, user)
+	assert.NoError(t, err)
+	assert.Empty(t, scheduled)
+	// Post published last comes first
+	published, err = repos.Posts.FetchPublished(ctx, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, published, 2) {
+		assert.Equal(t, post.ID, published[0].ID)
+	}
+
+	assert.NoError(t, repos.Posts.Delete(ctx, post))
+	_, err = repos.Posts.Fetch(ctx, post.ID)
+	assert.EqualError(t, err, "Not found.")
+}
+
+func TestMemoryWebhooks(t *testing.T) {
+	ctx := context.Background()
+	repos := NewMemoryRepositories()
+	user := &User{ID: 1}
+	hook := &Webhook{URL: "http://localhost:9000/hooks", Events: []string{EventPostCreated}, Secret: "0123456789abcdef"}
+	assert.NoError(t, repos.Webhooks.Add(ctx, user, hook))
+	assert.Equal(t, 1, hook.ID)
+
+	hooks, err := repos.Webhooks.FetchUserWebhooks(ctx, user)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Webhook{hook}, hooks)
+
+	ids, err := repos.Webhooks.Enqueue(ctx, user.ID, EventPostCreated, []byte(`{}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, ids)
+	ids, err = repos.Webhooks.Enqueue(ctx, user.ID, EventPostDeleted, []byte(`{}`))
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+	ids, err = repos.Webhooks.Enqueue(ctx, 2, EventPostCreated, []byte(`{}`))
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+	delivery, err := repos.Webhooks.FetchDelivery(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, hook.URL, delivery.Webhook.URL)
+
+	deliveries, err := repos.Webhooks.FetchDeliveries(ctx, hook.ID, 10)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+
+	assert.NoError(t, repos.Webhooks.Delete(ctx, hook))
+	_, err = repos.Webhooks.Fetch(ctx, hook.ID)
+	assert.EqualError(t, err, "Not found.")
+	deliveries, err = repos.Webhooks.FetchDeliveries(ctx, hook.ID, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, deliveries)
+}
+
+func TestMemoryJobs(t *testing.T) {
+	ctx := context.Background()
+	repos := NewMemoryRepositories()
+	job := &Job{Type: "test", Key: "unique"}
+	assert.NoError(t, repos.Jobs.Enqueue(ctx, job))
+	assert.Equal(t, 1, job.ID)
+	// Pending job with the same key already exists
+	duplicate := &Job{Type: "test", Key: "unique"}
+	assert.NoError(t, repos.Jobs.Enqueue(ctx, duplicate))
+	assert.Zero(t, duplicate.ID)
+	assert.NoError(t, repos.Jobs.Enqueue(ctx, &Job{Type: "test", RunAt: time.Now().Add(time.Hour)}))
+	assert.NoError(t, repos.Jobs.Enqueue(ctx, &Job{Type: "other"}))
+
+	claimed, err := repos.Jobs.Claim(ctx, []string{"test"}, 10, time.Minute)
+	assert.NoError(t, err)
+	if assert.Len(t, claimed, 1) {
+		assert.Equal(t, job.ID, claimed[0].ID)
+		assert.Equal(t, 1, claimed[0].Attempts)
+	}
+	claimed, err = repos.Jobs.Claim(ctx, []string{"test"}, 10, time.Minute)
+	assert.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	finished := time.Now().Add(-time.Hour)
+	job.Status = JobDone
+	job.Attempts = 1
+	job.FinishedAt = &finished
+	assert.ErrorIs(t, repos.Jobs.Update(ctx, job, 2), ErrConflict)
+	assert.NoError(t, repos.Jobs.Update(ctx, job, 1))
+	purged, err := repos.Jobs.Purge(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	_, err = repos.Jobs.Fetch(ctx, job.ID)
+	assert.EqualError(t, err, "Not found.")
+}
+
+func TestMemoryEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repos := NewMemoryRepositories()
+	notified := make(chan int, 1)
+	listening := make(chan error)
+	go func() { listening <- repos.Events.Listen(ctx, func(userID int) { notified <- userID }) }()
+	assert.Eventually(t, func() bool {
+		assert.NoError(t, repos.Events.Add(ctx, &Event{UserID: 1, Name: EventPostCreated}))
+		select {
+		case userID := <-notified:
+			return userID == 1
+		default:
+			return false
+		}
+	}, time.Second, time.Millisecond)
+
+	err := repos.Tx.WithTx(ctx, func(ctx context.Context) error {
+		assert.NoError(t, repos.Events.Add(ctx, &Event{UserID: 1, Name: EventPostDeleted}))
+		return ErrValidation
+	})
+	assert.ErrorIs(t, err, ErrValidation)
+	lastID, err := repos.Events.LastID(ctx, 1)
+	assert.NoError(t, err)
+	fetched, err := repos.Events.FetchAfter(ctx, 1, 0, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, lastID, fetched[len(fetched)-1].ID)
+	assert.Equal(t, EventPostCreated, fetched[len(fetched)-1].Name)
+
+	cancel()
+	assert.ErrorIs(t, <-listening, context.Canceled)
+}
+
+func TestMemoryCollaborators(t *testing.T) {
+	ctx := context.Background()
+	repos := NewMemoryRepositories()
+	owner := &User{Username: "batman", Password: "secret123"}
+	assert.NoError(t, repos.Users.Add(ctx, owner))
+	user := &User{Username: "robin", Password: "secret123"}
+	assert.NoError(t, repos.Users.Add(ctx, user))
+	post := &Post{Title: "Gotham cronicles", Content: "Joker is planning big hit tonight."}
+	assert.NoError(t, repos.Posts.Add(ctx, owner, post))
+
+	err := repos.Collaborators.Grant(ctx, &Collaborator{PostID: post.ID, UserID: 42, Role: RoleViewer})
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.NoError(t, repos.Collaborators.Grant(ctx, &Collaborator{PostID: post.ID, UserID: user.ID, Role: RoleViewer}))
+	assert.NoError(t, repos.Collaborators.Grant(ctx, &Collaborator{PostID: post.ID, UserID: user.ID, Role: RoleEditor}))
+	role, err := repos.Collaborators.Role(ctx, post.ID, user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, RoleEditor, role)
+	postCollaborators, err := repos.Collaborators.FetchPostCollaborators(ctx, post.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, postCollaborators, 1) {
+		assert.Equal(t, "robin", postCollaborators[0].Username)
+	}
+	shared, err := repos.Collaborators.FetchShared(ctx, user)
+	assert.NoError(t, err)
+	if assert.Len(t, shared, 1) {
+		assert.Equal(t, post.ID, shared[0].Post.ID)
+	}
+
+	assert.NoError(t, repos.Posts.Delete(ctx, post))
+	shared, err = repos.Collaborators.FetchShared(ctx, user)
+	assert.NoError(t, err)
+	assert.Empty(t, shared)
+	role, err = repos.Collaborators.Role(ctx, post.ID, user.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, role)
+	assert.NoError(t, repos.Collaborators.Revoke(ctx, post.ID, user.ID))
+	assert.ErrorIs(t, repos.Collaborators.Revoke(ctx, post.ID, user.ID), ErrNotFound)
+}
@@ -1,10 +1,12 @@
 package store
 
 import (
+	"context"
+	"errors"
 	"time"
 
+	"github.com/go-pg/pg/v10"
 	"github.com/go-pg/pg/v10/orm"
-	"github.com/rs/zerolog/log"
 )
 
 type Post struct {
@@ -13,54 +15,205 @@ type Post struct {
 	Content    string `binding:"required,min=5,max=5000"`
 	CreatedAt  time.Time
 	ModifiedAt time.Time
-	UserID     int `json:"-"`
+	// Incremented on every update, used to detect concurrent edits
+	Version int
+	// Published posts are readable by everyone, e.g. in feeds
+	Published bool
+	// Time when post was last published, set by store
+	PublishedAt *time.Time
+	// Time when post is published by scheduler, nil if it isn't scheduled
+	PublishAt *time.Time `binding:"omitempty,future"`
+	UserID    int        `json:"-"`
 }
 
-func AddPost(user *User, post *Post) error {
+type PostRepository interface {
+	// Add saves post as owned by user.
+	Add(ctx context.Context, user *User, post *Post) error
+	// FetchUserPosts loads all user's posts into user.Posts.
+	FetchUserPosts(ctx context.Context, user *User) error
+	// EachUserPost calls fn for every user's post ordered by ID, without
+	// loading all of them into memory. Iteration stops at first error.
+	EachUserPost(ctx context.Context, user *User, fn func(*Post) error) error
+	// FetchPublished returns at most limit most recently published posts of
+	// user with given ID, or of all users if ID is 0.
+	FetchPublished(ctx context.Context, userID, limit int) ([]*Post, error)
+	Fetch(ctx context.Context, id int) (*Post, error)
+	// FetchScheduled returns user's posts scheduled for publishing, ordered
+	// by time when they will be published.
+	FetchScheduled(ctx context.Context, user *User) ([]*Post, error)
+	// Update saves post's title, content, published flag, publishing time and
+	// modification time if its version matches stored one, and increments
+	// the version. Time when post was published is set when it is published,
+	// and cleared when it is unpublished. ErrConflict is returned if post was
+	// modified in the meantime.
+	Update(ctx context.Context, post *Post) error
+	// PublishDue publishes posts scheduled for publishing at or before given
+	// time and returns them. Running it again doesn't change already
+	// published posts.
+	PublishDue(ctx context.Context, now time.Time) ([]*Post, error)
+	Delete(ctx context.Context, post *Post) error
+}
+
+type pgPostRepository struct {
+	db *pg.DB
+}
+
+func NewPostRepository(db *pg.DB) PostRepository {
+	return &pgPostRepository{db: db}
+}
+
+func (r *pgPostRepository) Add(ctx context.Context, user *User, post *Post) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	post.UserID = user.ID
-	_, err := db.Model(post).Returning("*").Insert()
+	setPublishedAt(post)
+	_, err := conn(ctx, r.db).ModelContext(ctx, post).Returning("*").Insert()
 	if err != nil {
-		log.Error().Err(err).Msg("Error inserting new post")
+		logger(ctx).Error().Err(err).Msg("Error inserting new post")
 	}
 	return dbError(err)
 }
 
-func FetchUserPosts(user *User) error {
-	err := db.Model(user).
-		WherePK().
-		Relation("Posts", func(q *orm.Query) (*orm.Query, error) {
-			return q.Order("id ASC"), nil
-		}).
-		Select()
+func (r *pgPostRepository) FetchUserPosts(ctx context.Context, user *User) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	err := retry(ctx, func() error {
+		return conn(ctx, r.db).ModelContext(ctx, user).
+			WherePK().
+			Relation("Posts", func(q *orm.Query) (*orm.Query, error) {
+				return q.Order("id ASC"), nil
+			}).
+			Select()
+	})
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error fetching user's posts")
+	}
+	return dbError(err)
+}
+
+// EachUserPost isn't limited by query timeout, since fn might take long,
+// e.g. when it writes posts to slow client.
+func (r *pgPostRepository) EachUserPost(ctx context.Context, user *User, fn func(*Post) error) error {
+	err := conn(ctx, r.db).ModelContext(ctx, (*Post)(nil)).
+		Where("user_id = ?", user.ID).
+		Order("id ASC").
+		ForEach(fn)
 	if err != nil {
-		log.Error().Err(err).Msg("Error fetching user's posts")
+		logger(ctx).Error().Err(err).Msg("Error iterating user's posts")
 	}
 	return dbError(err)
 }
 
-func FetchPost(id int) (*Post, error) {
+func (r *pgPostRepository) FetchPublished(ctx context.Context, userID, limit int) ([]*Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var posts []*Post
+	err := retry(ctx, func() error {
+		posts = []*Post{}
+		q := conn(ctx, r.db).ModelContext(ctx, &posts).
+			Where("published").
+			Order("published_at DESC", "id DESC").
+			Limit(limit)
+		if userID != 0 {
+			q = q.Where("user_id = ?", userID)
+		}
+		return q.Select()
+	})
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error fetching published posts")
+		return nil, dbError(err)
+	}
+	return posts, nil
+}
+
+func (r *pgPostRepository) Fetch(ctx context.Context, id int) (*Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	post := new(Post)
 	post.ID = id
-	err := db.Model(post).WherePK().Select()
+	err := retry(ctx, func() error {
+		return conn(ctx, r.db).ModelContext(ctx, post).WherePK().Select()
+	})
 	if err != nil {
-		log.Error().Err(err).Msg("Error fetching post")
+		logger(ctx).Error().Err(err).Msg("Error fetching post")
 		return nil, dbError(err)
 	}
 	return post, nil
 }
 
-func UpdatePost(post *Post) error {
-	_, err := db.Model(post).WherePK().UpdateNotZero()
+func (r *pgPostRepository) Update(ctx context.Context, post *Post) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	res, err := conn(ctx, r.db).ModelContext(ctx, post).
+		Set("title = ?title, content = ?content, published = ?published, publish_at = ?publish_at, modified_at = ?modified_at, version = version + 1").
+		Set("published_at = CASE WHEN ?published THEN COALESCE(published_at, ?modified_at) END").
+		WherePK().
+		Where("version = ?version").
+		Returning("version, published_at").
+		Update()
+	if errors.Is(err, pg.ErrNoRows) || (err == nil && res.RowsAffected() == 0) {
+		return stale(err)
+	}
 	if err != nil {
-		log.Error().Err(err).Msg("Error updating post")
+		logger(ctx).Error().Err(err).Msg("Error updating post")
 	}
 	return dbError(err)
 }
 
-func DeletePost(post *Post) error {
-	_, err := db.Model(post).WherePK().Delete()
+func (r *pgPostRepository) FetchScheduled(ctx context.Context, user *User) ([]*Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var posts []*Post
+	err := retry(ctx, func() error {
+		posts = []*Post{}
+		return conn(ctx, r.db).ModelContext(ctx, &posts).
+			Where("user_id = ?", user.ID).
+			Where("publish_at IS NOT NULL").
+			Order("publish_at ASC", "id ASC").
+			Select()
+	})
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error fetching scheduled posts")
+		return nil, dbError(err)
+	}
+	return posts, nil
+}
+
+func (r *pgPostRepository) PublishDue(ctx context.Context, now time.Time) ([]*Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	posts := []*Post{}
+	// Rows are locked by UPDATE, so concurrent runs don't publish post twice
+	_, err := conn(ctx, r.db).QueryContext(ctx, &posts, `
+		UPDATE posts
+		SET published = TRUE, published_at = ?0, publish_at = NULL, modified_at = ?0, version = version + 1
+		WHERE publish_at <= ?0
+		RETURNING *`,
+		now)
 	if err != nil {
-		log.Error().Err(err).Msg("Error deleting post")
+		logger(ctx).Error().Err(err).Msg("Error publishing scheduled posts")
+		return nil, dbError(err)
+	}
+	return posts, nil
+}
+
+func (r *pgPostRepository) Delete(ctx context.Context, post *Post) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	_, err := conn(ctx, r.db).ModelContext(ctx, post).WherePK().Delete()
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error deleting post")
 	}
 	return dbError(err)
 }
+
+// setPublishedAt sets time when new post was published, unless it is already
+// known, e.g. for imported posts.
+func setPublishedAt(post *Post) {
+	if !post.Published {
+		post.PublishedAt = nil
+	} else if post.PublishedAt == nil {
+		now := time.Now()
+		post.PublishedAt = &now
+	}
+}
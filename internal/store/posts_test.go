@@ -1,7 +1,9 @@
 package store
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -23,17 +25,86 @@ func TestFetchUserPosts(t *testing.T) {
 	post, err := addTestPost(user)
 	assert.NoError(t, err)
 
-	err = FetchUserPosts(user)
+	err = posts.FetchUserPosts(context.Background(), user)
 	assert.NoError(t, err)
 	assert.Equal(t, post, user.Posts[0])
 }
 
+func TestEachUserPost(t *testing.T) {
+	testSetup()
+	user, err := addTestUser()
+	assert.NoError(t, err)
+	post, err := addTestPost(user)
+	assert.NoError(t, err)
+
+	var iterated []*Post
+	err = posts.EachUserPost(context.Background(), user, func(post *Post) error {
+		iterated = append(iterated, post)
+		return nil
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, iterated, 1) {
+		assert.Equal(t, post.ID, iterated[0].ID)
+		assert.Equal(t, post.Content, iterated[0].Content)
+	}
+}
+
+func TestFetchPublishedPosts(t *testing.T) {
+	testSetup()
+	user, err := addTestUser()
+	assert.NoError(t, err)
+	_, err = addTestPost(user)
+	assert.NoError(t, err)
+	post := &Post{Title: "Published post", Content: "Everyone can read it.", Published: true}
+	assert.NoError(t, posts.Add(context.Background(), user, post))
+
+	published, err := posts.FetchPublished(context.Background(), user.ID, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, published, 1) {
+		assert.Equal(t, post.ID, published[0].ID)
+		assert.NotNil(t, published[0].PublishedAt)
+	}
+	published, err = posts.FetchPublished(context.Background(), user.ID+1, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, published)
+}
+
+func TestPublishDuePosts(t *testing.T) {
+	testSetup()
+	user, err := addTestUser()
+	assert.NoError(t, err)
+	ctx := context.Background()
+	due := time.Now().Add(-time.Minute)
+	later := time.Now().Add(time.Hour)
+	duePost := &Post{Title: "Due post", Content: "Published by scheduler.", PublishAt: &due}
+	assert.NoError(t, posts.Add(ctx, user, duePost))
+	laterPost := &Post{Title: "Later post", Content: "Published tomorrow.", PublishAt: &later}
+	assert.NoError(t, posts.Add(ctx, user, laterPost))
+
+	scheduled, err := posts.FetchScheduled(ctx, user)
+	assert.NoError(t, err)
+	assert.Len(t, scheduled, 2)
+	published, err := posts.PublishDue(ctx, time.Now())
+	assert.NoError(t, err)
+	if assert.Len(t, published, 1) {
+		assert.Equal(t, duePost.ID, published[0].ID)
+		assert.True(t, published[0].Published)
+		assert.Nil(t, published[0].PublishAt)
+		assert.Equal(t, 2, published[0].Version)
+	}
+	scheduled, err = posts.FetchScheduled(ctx, user)
+	assert.NoError(t, err)
+	if assert.Len(t, scheduled, 1) {
+		assert.Equal(t, laterPost.ID, scheduled[0].ID)
+	}
+}
+
 func TestFetchUserPostsEmpty(t *testing.T) {
 	testSetup()
 	user, err := addTestUser()
 	assert.NoError(t, err)
 
-	err = FetchUserPosts(user)
+	err = posts.FetchUserPosts(context.Background(), user)
 	assert.NoError(t, err)
 	assert.Empty(t, user.Posts)
 	assert.NotNil(t, user.Posts)
@@ -46,7 +117,7 @@ func TestFetchPost(t *testing.T) {
 	post, err := addTestPost(user)
 	assert.NoError(t, err)
 
-	fetchedPost, err := FetchPost(post.ID)
+	fetchedPost, err := posts.Fetch(context.Background(), post.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, post.ID, fetchedPost.ID)
 	assert.Equal(t, post.Title, fetchedPost.Title)
@@ -57,7 +128,7 @@ func TestFetchPost(t *testing.T) {
 func TestFetchNotExistingPost(t *testing.T) {
 	testSetup()
 
-	fetchedPost, err := FetchPost(1)
+	fetchedPost, err := posts.Fetch(context.Background(), 1)
 	assert.Error(t, err)
 	assert.Nil(t, fetchedPost)
 	assert.Equal(t, "Not found.", err.Error())
@@ -72,8 +143,25 @@ func TestUpdatePost(t *testing.T) {
 
 	post.Title = "New title"
 	post.Content = "New content"
-	err = UpdatePost(post)
+	err = posts.Update(context.Background(), post)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, post.Version)
+}
+
+func TestUpdateStalePost(t *testing.T) {
+	testSetup()
+	user, err := addTestUser()
 	assert.NoError(t, err)
+	post, err := addTestPost(user)
+	assert.NoError(t, err)
+
+	stalePost := *post
+	post.Title = "New title"
+	assert.NoError(t, posts.Update(context.Background(), post))
+	stalePost.Title = "Stale title"
+	err = posts.Update(context.Background(), &stalePost)
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Equal(t, "Modified in the meantime.", err.Error())
 }
 
 func TestDeletePost(t *testing.T) {
@@ -83,6 +171,6 @@ func TestDeletePost(t *testing.T) {
 	post, err := addTestPost(user)
 	assert.NoError(t, err)
 
-	err = DeletePost(post)
+	err = posts.Delete(context.Background(), post)
 	assert.NoError(t, err)
 }
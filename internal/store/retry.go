@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+const (
+	minRetryBackoff = 50 * time.Millisecond
+	maxRetryBackoff = 5 * time.Second
+)
+
+// Number of times idempotent queries are retried on transient errors
+var queryRetries = 2
+
+func SetQueryRetries(retries int) { queryRetries = retries }
+
+// WaitForDB blocks until database responds to ping, retrying with
+// exponential backoff until timeout passes.
+func WaitForDB(ctx context.Context, db *pg.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for attempt := 0; ; attempt++ {
+		err := db.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		logger(ctx).Warn().Err(err).Int("attempt", attempt+1).Msg("Database not reachable")
+		if sleep(ctx, backoff(attempt)) != nil {
+			return err
+		}
+	}
+}
+
+// retry runs idempotent query again if it fails with transient error.
+// Queries which modify data must not be retried, since they might have been
+// executed before connection failed. Queries in a transaction are not
+// retried, since failed query aborts the whole transaction.
+func retry(ctx context.Context, query func() error) error {
+	err := query()
+	if txFromContext(ctx) != nil {
+		return err
+	}
+	for attempt := 0; attempt < queryRetries && isTransient(err); attempt++ {
+		logger(ctx).Warn().Err(err).Int("attempt", attempt+1).Msg("Retrying query")
+		if sleep(ctx, backoff(attempt)) != nil {
+			return err
+		}
+		err = query()
+	}
+	return err
+}
+
+// isTransient reports whether query failed because of connection issues or
+// server state which might be resolved by trying again.
+func isTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pgErr pg.Error
+	if errors.As(err, &pgErr) {
+		code := pgErr.Field('C')
+		switch code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"53300", // too_many_connections
+			"57P01", // admin_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		// Class 08 - connection exception
+		return strings.HasPrefix(code, "08")
+	}
+	return false
+}
+
+func backoff(attempt int) time.Duration {
+	backoff := minRetryBackoff << uint(attempt)
+	if backoff > maxRetryBackoff || backoff <= 0 {
+		return maxRetryBackoff
+	}
+	return backoff
+}
+
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
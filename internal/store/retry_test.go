@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTransient(t *testing.T) {
+	assert.False(t, isTransient(nil))
+	assert.False(t, isTransient(pg.ErrNoRows))
+	assert.False(t, isTransient(context.Canceled))
+	assert.False(t, isTransient(context.DeadlineExceeded))
+	assert.True(t, isTransient(io.EOF))
+	assert.True(t, isTransient(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, minRetryBackoff, backoff(0))
+	assert.Equal(t, 2*minRetryBackoff, backoff(1))
+	assert.Equal(t, maxRetryBackoff, backoff(20))
+	assert.Equal(t, maxRetryBackoff, backoff(100))
+}
+
+func TestRetryTransientError(t *testing.T) {
+	calls := 0
+	err := retry(context.Background(), func() error {
+		calls++
+		if calls < 2 {
+			return io.EOF
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	calls := 0
+	err := retry(context.Background(), func() error {
+		calls++
+		return io.EOF
+	})
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, queryRetries+1, calls)
+}
+
+func TestRetryPermanentError(t *testing.T) {
+	calls := 0
+	err := retry(context.Background(), func() error {
+		calls++
+		return pg.ErrNoRows
+	})
+	assert.ErrorIs(t, err, pg.ErrNoRows)
+	assert.Equal(t, 1, calls)
+}
+
+func TestWaitForDBUnreachable(t *testing.T) {
+	db := NewDBConnection(&pg.Options{Addr: "localhost:1", DialTimeout: 10 * time.Millisecond})
+	defer db.Close()
+
+	err := WaitForDB(context.Background(), db, 200*time.Millisecond)
+	assert.Error(t, err)
+}
@@ -1,36 +1,99 @@
 package store
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"regexp"
 	"rgb/internal/conf"
 	"rgb/internal/database"
-	"strings"
+	"rgb/internal/logging"
+	"rgb/internal/metrics"
+	"rgb/internal/tracing"
+	"time"
 
 	"github.com/go-pg/pg/v10"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
-// Database connector
-var db *pg.DB
+// Repositories groups all repositories app needs, so they can be passed
+// around together.
+type Repositories struct {
+	Tx            Transactor
+	Users         UserRepository
+	Posts         PostRepository
+	Webhooks      WebhookRepository
+	Jobs          JobRepository
+	Events        EventRepository
+	Collaborators CollaboratorRepository
+}
+
+// NewRepositories returns repositories backed by Postgres database.
+func NewRepositories(db *pg.DB) Repositories {
+	return Repositories{
+		Tx:            NewTransactor(db),
+		Users:         NewUserRepository(db),
+		Posts:         NewPostRepository(db),
+		Webhooks:      NewWebhookRepository(db),
+		Jobs:          NewJobRepository(db),
+		Events:        NewEventRepository(db),
+		Collaborators: NewCollaboratorRepository(db),
+	}
+}
 
-func SetDBConnection(dbOpts *pg.Options) {
+// NewMemoryRepositories returns repositories keeping data in memory. They
+// are meant for tests which don't need real database.
+func NewMemoryRepositories() Repositories {
+	users := &memoryUserRepository{users: map[int]*User{}}
+	posts := &memoryPostRepository{posts: map[int]*Post{}}
+	webhooks := &memoryWebhookRepository{hooks: map[int]*Webhook{}, deliveries: map[int]*Delivery{}}
+	jobs := &memoryJobRepository{jobs: map[int]*Job{}}
+	events := &memoryEventRepository{listeners: map[int]func(int){}}
+	collaborators := &memoryCollaboratorRepository{collaborators: []*Collaborator{}, users: users, posts: posts}
+	return Repositories{
+		Tx:            &memoryTransactor{repos: []memoryRepository{users, posts, webhooks, jobs, events, collaborators}},
+		Users:         users,
+		Posts:         posts,
+		Webhooks:      webhooks,
+		Jobs:          jobs,
+		Events:        events,
+		Collaborators: collaborators,
+	}
+}
+
+// NewDBConnection returns database connection with metrics and tracing
+// query hooks attached.
+func NewDBConnection(dbOpts *pg.Options) *pg.DB {
 	if dbOpts == nil {
 		log.Panic().Msg("DB options can't be nil")
-	} else {
-		db = pg.Connect(dbOpts)
 	}
+	db := pg.Connect(dbOpts)
+	db.AddQueryHook(metrics.QueryHook{})
+	db.AddQueryHook(tracing.QueryHook{})
+	return db
 }
 
-func GetDBConnection() *pg.DB { return db }
+// Max duration of single store operation, 0 disables it
+var queryTimeout time.Duration
+
+func SetQueryTimeout(timeout time.Duration) { queryTimeout = timeout }
+
+// withTimeout bounds store operation by configured query timeout, in addition
+// to cancellation and deadline of the parent ctx.
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, queryTimeout)
+}
 
-func ResetTestDatabase() {
-	// Connect to test database
-	SetDBConnection(database.NewDBOptions(conf.NewTestConfig()))
+func logger(ctx context.Context) *zerolog.Logger { return logging.Module(ctx, logging.Store) }
+
+// ResetTestDatabase connects to test database and empties all tables.
+func ResetTestDatabase() *pg.DB {
+	db := NewDBConnection(database.NewDBOptions(conf.NewTestConfig()))
 
 	// Empty all tables and restart sequence counters
-	tables := []string{"users", "posts"}
+	tables := []string{"users", "posts", "webhooks", "webhook_deliveries", "jobs", "events", "post_collaborators"}
 	for _, table := range tables {
 		_, err := db.Exec(fmt.Sprintf("DELETE FROM %s;", table))
 		if err != nil {
@@ -39,34 +102,5 @@ func ResetTestDatabase() {
 
 		_, err = db.Exec(fmt.Sprintf("ALTER SEQUENCE %s_id_seq RESTART;", table))
 	}
-}
-
-func dbError(_err interface{}) error {
-	if _err == nil {
-		return nil
-	}
-	switch _err.(type) {
-	case pg.Error:
-		err := _err.(pg.Error)
-		switch err.Field(82) {
-		case "_bt_check_unique":
-			return errors.New(extractColumnName(err.Field(110)) + " already exists.")
-		}
-	case error:
-		err := _err.(error)
-		switch err.Error() {
-		case "pg: no rows in result set":
-			return errors.New("Not found.")
-		}
-		return err
-	}
-	return errors.New(fmt.Sprint(_err))
-}
-
-func extractColumnName(text string) string {
-	reg := regexp.MustCompile(`.+_(.+)_.+`)
-	if reg.MatchString(text) {
-		return strings.Title(reg.FindStringSubmatch(text)[1])
-	}
-	return "Unknown"
+	return db
 }
@@ -1,16 +1,18 @@
 package store
 
 import (
+	"context"
 	"rgb/internal/conf"
 	"rgb/internal/database"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func TestSetDBConnection(t *testing.T) {
+func TestNewDBConnection(t *testing.T) {
 	dbOptions := database.NewDBOptions(conf.NewTestConfig())
-	assert.NotPanics(t, func() { SetDBConnection(dbOptions) })
+	db := NewDBConnection(dbOptions)
 	assert.NotNil(t, db)
 	assert.Equal(t, dbOptions.Addr, db.Options().Addr)
 	assert.Equal(t, dbOptions.User, db.Options().User)
@@ -18,17 +20,36 @@ func TestSetDBConnection(t *testing.T) {
 	assert.Equal(t, dbOptions.Database, db.Options().Database)
 }
 
-func TestSetDBConnectionNilOptions(t *testing.T) {
-	assert.Panics(t, func() { SetDBConnection(nil) })
+func TestNewDBConnectionNilOptions(t *testing.T) {
+	assert.Panics(t, func() { NewDBConnection(nil) })
 }
 
-func TestGetDBConnection(t *testing.T) {
-	dbOptions := database.NewDBOptions(conf.NewTestConfig())
-	SetDBConnection(dbOptions)
-	fetched := GetDBConnection()
-	assert.NotNil(t, fetched)
-	assert.Equal(t, dbOptions.Addr, fetched.Options().Addr)
-	assert.Equal(t, dbOptions.User, fetched.Options().User)
-	assert.Equal(t, dbOptions.Password, fetched.Options().Password)
-	assert.Equal(t, dbOptions.Database, fetched.Options().Database)
+func TestWithTimeout(t *testing.T) {
+	defer SetQueryTimeout(queryTimeout)
+
+	SetQueryTimeout(time.Second)
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+}
+
+func TestWithTimeoutDisabled(t *testing.T) {
+	defer SetQueryTimeout(queryTimeout)
+
+	SetQueryTimeout(0)
+	ctx, cancel := withTimeout(context.Background())
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+	cancel()
+	assert.Error(t, ctx.Err())
+}
+
+func TestWithTimeoutParentCancelled(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := withTimeout(parent)
+	defer cancel()
+	cancelParent()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
 }
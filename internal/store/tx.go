@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// Number of times whole transaction is run again if it fails because of
+// deadlock
+const txRetries = 3
+
+// Transactor runs multi-step store operations as a single unit of work.
+type Transactor interface {
+	// WithTx runs fn in a transaction. Repository calls made with ctx passed
+	// to fn participate in the transaction. Transaction is rolled back if fn
+	// returns error or panics, and committed otherwise. Nested calls use
+	// savepoints, so only the nested part is rolled back on error.
+	//
+	// Transactions run at default READ COMMITTED isolation level, so the only
+	// concurrency failures they get are deadlocks, after which fn is run
+	// again. Conflicting updates aren't detected by the database, and must
+	// be checked by repositories, e.g. with post versions.
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+// txState is transaction in progress, stored in the ctx of every operation
+// which participates in it.
+type txState struct {
+	tx         *pg.Tx
+	savepoints int
+}
+
+func txFromContext(ctx context.Context) *txState {
+	state, _ := ctx.Value(txKey{}).(*txState)
+	return state
+}
+
+// conn returns transaction from the ctx if there is one, and db otherwise.
+func conn(ctx context.Context, db *pg.DB) orm.DB {
+	if state := txFromContext(ctx); state != nil {
+		return state.tx
+	}
+	return db
+}
+
+type pgTransactor struct {
+	db *pg.DB
+}
+
+func NewTransactor(db *pg.DB) Transactor {
+	return &pgTransactor{db: db}
+}
+
+func (t *pgTransactor) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if state := txFromContext(ctx); state != nil {
+		return withSavepoint(ctx, state, fn)
+	}
+	err := t.runTx(ctx, fn)
+	for attempt := 0; attempt < txRetries && isSerializationFailure(err); attempt++ {
+		logger(ctx).Warn().Err(err).Int("attempt", attempt+1).Msg("Retrying transaction")
+		if sleep(ctx, backoff(attempt)) != nil {
+			return err
+		}
+		err = t.runTx(ctx, fn)
+	}
+	return err
+}
+
+func (t *pgTransactor) runTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	tx, err := t.db.BeginContext(ctx)
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error starting transaction")
+		return dbError(err)
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			rollback(ctx, tx)
+			panic(recovered)
+		}
+		if err != nil {
+			rollback(ctx, tx)
+			return
+		}
+		if err = tx.CommitContext(ctx); err != nil {
+			logger(ctx).Error().Err(err).Msg("Error committing transaction")
+			err = dbError(err)
+		}
+	}()
+	return fn(context.WithValue(ctx, txKey{}, &txState{tx: tx}))
+}
+
+func rollback(ctx context.Context, tx *pg.Tx) {
+	// Use context which isn't cancelled, so rollback is sent even if ctx
+	// is the reason transaction failed
+	if err := tx.RollbackContext(context.Background()); err != nil && !errors.Is(err, pg.ErrTxDone) {
+		logger(ctx).Error().Err(err).Msg("Error rolling back transaction")
+	}
+}
+
+func withSavepoint(ctx context.Context, state *txState, fn func(ctx context.Context) error) (err error) {
+	state.savepoints++
+	name := fmt.Sprintf("sp_%d", state.savepoints)
+	if _, err := state.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		logger(ctx).Error().Err(err).Msg("Error creating savepoint")
+		return dbError(err)
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			rollbackToSavepoint(ctx, state.tx, name)
+			panic(recovered)
+		}
+		if err != nil {
+			rollbackToSavepoint(ctx, state.tx, name)
+			return
+		}
+		if _, err = state.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+			logger(ctx).Error().Err(err).Msg("Error releasing savepoint")
+			err = dbError(err)
+		}
+	}()
+	return fn(ctx)
+}
+
+func rollbackToSavepoint(ctx context.Context, tx *pg.Tx, name string) {
+	if _, err := tx.ExecContext(context.Background(), "ROLLBACK TO SAVEPOINT "+name); err != nil {
+		logger(ctx).Error().Err(err).Msg("Error rolling back to savepoint")
+	}
+}
+
+// isSerializationFailure reports whether transaction failed only because of
+// concurrent transactions, so running it again might succeed. At READ
+// COMMITTED that is a deadlock, serialization failures only happen if fn
+// raises isolation level with SET TRANSACTION.
+func isSerializationFailure(err error) bool {
+	var pgErr pg.Error
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	code := pgErr.Field('C')
+	return code == "40001" || code == "40P01"
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithTxCommit(t *testing.T) {
+	testSetup()
+	err := transactor.WithTx(context.Background(), func(ctx context.Context) error {
+		user := &User{Username: "batman", Password: "secret123"}
+		if err := users.Add(ctx, user); err != nil {
+			return err
+		}
+		_, err := posts.Fetch(ctx, 1)
+		assert.Error(t, err)
+		return posts.Add(ctx, user, &Post{Title: "Gotham cronicles", Content: "Joker is planning big hit tonight."})
+	})
+	assert.NoError(t, err)
+
+	_, err = users.Fetch(context.Background(), 1)
+	assert.NoError(t, err)
+	_, err = posts.Fetch(context.Background(), 1)
+	assert.NoError(t, err)
+}
+
+func TestWithTxRollback(t *testing.T) {
+	testSetup()
+	err := transactor.WithTx(context.Background(), func(ctx context.Context) error {
+		if _, err := addTestUserCtx(ctx); err != nil {
+			return err
+		}
+		return errors.New("failed")
+	})
+	assert.EqualError(t, err, "failed")
+
+	_, err = users.Fetch(context.Background(), 1)
+	assert.EqualError(t, err, "Not found.")
+}
+
+func TestWithTxRollbackOnPanic(t *testing.T) {
+	testSetup()
+	assert.Panics(t, func() {
+		_ = transactor.WithTx(context.Background(), func(ctx context.Context) error {
+			_, _ = addTestUserCtx(ctx)
+			panic("failed")
+		})
+	})
+
+	_, err := users.Fetch(context.Background(), 1)
+	assert.EqualError(t, err, "Not found.")
+}
+
+func TestWithTxNestedRollback(t *testing.T) {
+	testSetup()
+	err := transactor.WithTx(context.Background(), func(ctx context.Context) error {
+		user, err := addTestUserCtx(ctx)
+		if err != nil {
+			return err
+		}
+		nestedErr := transactor.WithTx(ctx, func(ctx context.Context) error {
+			if err := posts.Add(ctx, user, &Post{Title: "Gotham cronicles", Content: "Joker is planning big hit tonight."}); err != nil {
+				return err
+			}
+			return errors.New("failed")
+		})
+		assert.EqualError(t, nestedErr, "failed")
+		return nil
+	})
+	assert.NoError(t, err)
+
+	_, err = users.Fetch(context.Background(), 1)
+	assert.NoError(t, err)
+	_, err = posts.Fetch(context.Background(), 1)
+	assert.EqualError(t, err, "Not found.")
+}
+
+func TestWithTxRetrySerializationFailure(t *testing.T) {
+	testSetup()
+	attempts := 0
+	err := transactor.WithTx(context.Background(), func(ctx context.Context) error {
+		attempts++
+		if _, err := addTestUserCtx(ctx); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			_, err := txFromContext(ctx).tx.Exec(`DO $$ BEGIN RAISE EXCEPTION 'conflict' USING ERRCODE = '40001'; END $$`)
+			return err
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestMemoryWithTx(t *testing.T) {
+	repos := NewMemoryRepositories()
+	user := &User{ID: 1}
+	err := repos.Tx.WithTx(context.Background(), func(ctx context.Context) error {
+		if err := repos.Posts.Add(ctx, user, &Post{Title: "Gotham cronicles"}); err != nil {
+			return err
+		}
+		nestedErr := repos.Tx.WithTx(ctx, func(ctx context.Context) error {
+			_ = repos.Posts.Add(ctx, user, &Post{Title: "Justice league meeting"})
+			return errors.New("failed")
+		})
+		assert.Error(t, nestedErr)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, repos.Posts.FetchUserPosts(context.Background(), user))
+	assert.Len(t, user.Posts, 1)
+
+	err = repos.Tx.WithTx(context.Background(), func(ctx context.Context) error {
+		_ = repos.Posts.Add(ctx, user, &Post{Title: "Justice league meeting"})
+		return errors.New("failed")
+	})
+	assert.Error(t, err)
+	assert.NoError(t, repos.Posts.FetchUserPosts(context.Background(), user))
+	assert.Len(t, user.Posts, 1)
+
+	assert.Panics(t, func() {
+		_ = repos.Tx.WithTx(context.Background(), func(ctx context.Context) error {
+			_ = repos.Posts.Add(ctx, user, &Post{Title: "Justice league meeting"})
+			panic("failed")
+		})
+	})
+	assert.NoError(t, repos.Posts.FetchUserPosts(context.Background(), user))
+	assert.Len(t, user.Posts, 1)
+}
@@ -3,10 +3,10 @@ package store
 import (
 	"context"
 	"crypto/rand"
+	"rgb/internal/tracing"
 	"time"
 
 	"github.com/go-pg/pg/v10"
-	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -30,59 +30,117 @@ func (user *User) AfterSelect(ctx context.Context) error {
 	return nil
 }
 
-func AddUser(user *User) error {
-	salt, err := GenerateSalt()
-	if err != nil {
-		return err
-	}
-	toHash := append([]byte(user.Password), salt...)
-	hashedPassword, err := bcrypt.GenerateFromPassword(toHash, bcrypt.DefaultCost)
-	if err != nil {
-		log.Error().Err(err).Msg("Error hashing password")
+type UserRepository interface {
+	// Add hashes user's password and saves user.
+	Add(ctx context.Context, user *User) error
+	// Authenticate returns user with given username if password matches.
+	Authenticate(ctx context.Context, username, password string) (*User, error)
+	Fetch(ctx context.Context, id int) (*User, error)
+	FetchByUsername(ctx context.Context, username string) (*User, error)
+}
+
+type pgUserRepository struct {
+	db *pg.DB
+}
+
+func NewUserRepository(db *pg.DB) UserRepository {
+	return &pgUserRepository{db: db}
+}
+
+func (r *pgUserRepository) Add(ctx context.Context, user *User) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	if err := hashPassword(ctx, user); err != nil {
 		return err
 	}
 
-	user.Salt = salt
-	user.HashedPassword = hashedPassword
-
-	_, err = db.Model(user).Returning("*").Insert()
+	_, err := conn(ctx, r.db).ModelContext(ctx, user).Returning("*").Insert()
 	if err != nil {
-		log.Error().Err(err).Msg("Error inserting new user")
+		logger(ctx).Error().Err(err).Msg("Error inserting new user")
 		return dbError(err)
 	}
 	return nil
 }
 
-func Authenticate(username, password string) (*User, error) {
+func (r *pgUserRepository) Authenticate(ctx context.Context, username, password string) (*User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	user := new(User)
-	if err := db.Model(user).Where(
-		"username = ?", username).Select(); err != nil {
-		log.Error().Err(err).Str("username", username).Msg("Error fetching user for authentication")
+	if err := retry(ctx, func() error {
+		return conn(ctx, r.db).ModelContext(ctx, user).Where("username = ?", username).Select()
+	}); err != nil {
+		logger(ctx).Error().Err(err).Str("username", username).Msg("Error fetching user for authentication")
 		return nil, dbError(err)
 	}
-	salted := append([]byte(password), user.Salt...)
-	if err := bcrypt.CompareHashAndPassword(user.HashedPassword, salted); err != nil {
-		log.Error().Err(err).Msg("Error comparing hash and password")
+	if err := checkPassword(ctx, user, password); err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func FetchUser(id int) (*User, error) {
+func (r *pgUserRepository) Fetch(ctx context.Context, id int) (*User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 	user := new(User)
 	user.ID = id
-	err := db.Model(user).Returning("*").WherePK().Select()
+	err := retry(ctx, func() error {
+		return conn(ctx, r.db).ModelContext(ctx, user).Returning("*").WherePK().Select()
+	})
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error fetching user")
+		return nil, dbError(err)
+	}
+	return user, nil
+}
+
+func (r *pgUserRepository) FetchByUsername(ctx context.Context, username string) (*User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	user := new(User)
+	err := retry(ctx, func() error {
+		return conn(ctx, r.db).ModelContext(ctx, user).Where("username = ?", username).Select()
+	})
 	if err != nil {
-		log.Error().Err(err).Msg("Error fetching user")
+		logger(ctx).Error().Err(err).Str("username", username).Msg("Error fetching user by username")
 		return nil, dbError(err)
 	}
 	return user, nil
 }
 
-func GenerateSalt() ([]byte, error) {
+func hashPassword(ctx context.Context, user *User) error {
+	salt, err := GenerateSalt(ctx)
+	if err != nil {
+		return err
+	}
+	toHash := append([]byte(user.Password), salt...)
+	_, span := tracing.Start(ctx, "bcrypt.GenerateFromPassword")
+	hashedPassword, err := bcrypt.GenerateFromPassword(toHash, bcrypt.DefaultCost)
+	span.End()
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error hashing password")
+		return err
+	}
+
+	user.Salt = salt
+	user.HashedPassword = hashedPassword
+	return nil
+}
+
+func checkPassword(ctx context.Context, user *User, password string) error {
+	salted := append([]byte(password), user.Salt...)
+	_, span := tracing.Start(ctx, "bcrypt.CompareHashAndPassword")
+	err := bcrypt.CompareHashAndPassword(user.HashedPassword, salted)
+	span.End()
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error comparing hash and password")
+	}
+	return err
+}
+
+func GenerateSalt(ctx context.Context) ([]byte, error) {
 	salt := make([]byte, 16)
 	if _, err := rand.Read(salt); err != nil {
-		log.Error().Err(err).Msg("Unable to create salt")
+		logger(ctx).Error().Err(err).Msg("Unable to create salt")
 		return nil, err
 	}
 	return salt, nil
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -31,7 +32,7 @@ func TestAuthenticateUser(t *testing.T) {
 	user, err := addTestUser()
 	assert.NoError(t, err)
 
-	authUser, err := Authenticate(user.Username, user.Password)
+	authUser, err := users.Authenticate(context.Background(), user.Username, user.Password)
 	assert.NoError(t, err)
 	assert.Equal(t, user.ID, authUser.ID)
 	assert.Equal(t, user.Username, authUser.Username)
@@ -45,7 +46,7 @@ func TestAuthenticateUserInvalidUsername(t *testing.T) {
 	user, err := addTestUser()
 	assert.NoError(t, err)
 
-	authUser, err := Authenticate("invalid", user.Password)
+	authUser, err := users.Authenticate(context.Background(), "invalid", user.Password)
 	assert.Error(t, err)
 	assert.Nil(t, authUser)
 }
@@ -55,7 +56,7 @@ func TestAuthenticateUserInvalidPassword(t *testing.T) {
 	user, err := addTestUser()
 	assert.NoError(t, err)
 
-	authUser, err := Authenticate(user.Username, "invalid")
+	authUser, err := users.Authenticate(context.Background(), user.Username, "invalid")
 	assert.Error(t, err)
 	assert.Nil(t, authUser)
 }
@@ -65,7 +66,7 @@ func TestFetchUser(t *testing.T) {
 	user, err := addTestUser()
 	assert.NoError(t, err)
 
-	fetchedUser, err := FetchUser(user.ID)
+	fetchedUser, err := users.Fetch(context.Background(), user.ID)
 	assert.NoError(t, err)
 	assert.Equal(t, user.ID, fetchedUser.ID)
 	assert.Equal(t, user.Username, fetchedUser.Username)
@@ -74,10 +75,23 @@ func TestFetchUser(t *testing.T) {
 	assert.Equal(t, user.HashedPassword, fetchedUser.HashedPassword)
 }
 
+func TestFetchUserByUsername(t *testing.T) {
+	testSetup()
+	user, err := addTestUser()
+	assert.NoError(t, err)
+
+	fetchedUser, err := users.FetchByUsername(context.Background(), user.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, fetchedUser.ID)
+
+	_, err = users.FetchByUsername(context.Background(), "invalid")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
 func TestFetchNotExistingUser(t *testing.T) {
 	testSetup()
 
-	fetchedUser, err := FetchUser(1)
+	fetchedUser, err := users.Fetch(context.Background(), 1)
 	assert.Error(t, err)
 	assert.Nil(t, fetchedUser)
 	assert.Equal(t, "Not found.", err.Error())
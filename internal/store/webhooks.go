@@ -0,0 +1,197 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+)
+
+// Events webhooks can subscribe to.
+const (
+	EventPostCreated  = "post.created"
+	EventPostUpdated  = "post.updated"
+	EventPostDeleted  = "post.deleted"
+	EventUserSignedIn = "user.signed_in"
+)
+
+type Webhook struct {
+	ID     int
+	URL    string
+	Events []string `pg:",array"`
+	// Key deliveries are signed with, never returned to clients
+	Secret    string `json:"-"`
+	CreatedAt time.Time
+	UserID    int `json:"-"`
+}
+
+// Statuses of webhook deliveries.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	// Delivery failed too many times and won't be attempted again
+	DeliveryFailed = "failed"
+)
+
+// Delivery is event waiting to be, or already, delivered to webhook.
+type Delivery struct {
+	tableName struct{} `pg:"webhook_deliveries,alias:delivery"`
+
+	ID        int
+	WebhookID int
+	Webhook   *Webhook `json:"-" pg:"rel:has-one"`
+	Event     string
+	Payload   json.RawMessage `pg:",type:jsonb"`
+	Status    string
+	Attempts  int `pg:",use_zero"`
+	// Response status of last attempt, 0 if there was no response
+	ResponseStatus int `pg:",use_zero"`
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+type WebhookRepository interface {
+	Add(ctx context.Context, user *User, hook *Webhook) error
+	FetchUserWebhooks(ctx context.Context, user *User) ([]*Webhook, error)
+	Fetch(ctx context.Context, id int) (*Webhook, error)
+	Delete(ctx context.Context, hook *Webhook) error
+	// Enqueue saves pending delivery of event payload to every user's
+	// webhook subscribed to the event, and returns their IDs. It should be
+	// called in the same transaction as the change event is about, so event
+	// is delivered if and only if the change is saved.
+	Enqueue(ctx context.Context, userID int, event string, payload []byte) ([]int, error)
+	// FetchDelivery returns delivery with its webhook.
+	FetchDelivery(ctx context.Context, id int) (*Delivery, error)
+	// UpdateDelivery saves result of delivery attempt.
+	UpdateDelivery(ctx context.Context, delivery *Delivery) error
+	// FetchDeliveries returns at most limit most recent deliveries to webhook.
+	FetchDeliveries(ctx context.Context, webhookID, limit int) ([]*Delivery, error)
+}
+
+type pgWebhookRepository struct {
+	db *pg.DB
+}
+
+func NewWebhookRepository(db *pg.DB) WebhookRepository {
+	return &pgWebhookRepository{db: db}
+}
+
+func (r *pgWebhookRepository) Add(ctx context.Context, user *User, hook *Webhook) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	hook.UserID = user.ID
+	_, err := conn(ctx, r.db).ModelContext(ctx, hook).Returning("*").Insert()
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error inserting new webhook")
+	}
+	return dbError(err)
+}
+
+func (r *pgWebhookRepository) FetchUserWebhooks(ctx context.Context, user *User) ([]*Webhook, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var hooks []*Webhook
+	err := retry(ctx, func() error {
+		hooks = []*Webhook{}
+		return conn(ctx, r.db).ModelContext(ctx, &hooks).Where("user_id = ?", user.ID).Order("id ASC").Select()
+	})
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error fetching user's webhooks")
+		return nil, dbError(err)
+	}
+	return hooks, nil
+}
+
+func (r *pgWebhookRepository) Fetch(ctx context.Context, id int) (*Webhook, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	hook := &Webhook{ID: id}
+	err := retry(ctx, func() error {
+		return conn(ctx, r.db).ModelContext(ctx, hook).WherePK().Select()
+	})
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error fetching webhook")
+		return nil, dbError(err)
+	}
+	return hook, nil
+}
+
+func (r *pgWebhookRepository) Delete(ctx context.Context, hook *Webhook) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	_, err := conn(ctx, r.db).ModelContext(ctx, hook).WherePK().Delete()
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error deleting webhook")
+	}
+	return dbError(err)
+}
+
+func (r *pgWebhookRepository) Enqueue(ctx context.Context, userID int, event string, payload []byte) ([]int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var ids []int
+	_, err := conn(ctx, r.db).QueryContext(ctx, pg.Scan(pg.Array(&ids)), `
+		WITH inserted AS (
+			INSERT INTO webhook_deliveries (webhook_id, event, payload)
+			SELECT id, ?, ?::jsonb FROM webhooks WHERE user_id = ? AND ? = ANY(events)
+			RETURNING id
+		)
+		SELECT coalesce(array_agg(id ORDER BY id), '{}') FROM inserted`,
+		event, string(payload), userID, event)
+	if err != nil {
+		logger(ctx).Error().Err(err).Str("event", event).Msg("Error enqueueing webhook deliveries")
+		return nil, dbError(err)
+	}
+	return ids, nil
+}
+
+func (r *pgWebhookRepository) FetchDelivery(ctx context.Context, id int) (*Delivery, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	delivery := &Delivery{}
+	err := retry(ctx, func() error {
+		return conn(ctx, r.db).ModelContext(ctx, delivery).
+			Relation("Webhook").
+			Where("delivery.id = ?", id).
+			Select()
+	})
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error fetching webhook delivery")
+		return nil, dbError(err)
+	}
+	return delivery, nil
+}
+
+func (r *pgWebhookRepository) UpdateDelivery(ctx context.Context, delivery *Delivery) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	_, err := conn(ctx, r.db).ModelContext(ctx, delivery).
+		Column("status", "attempts", "response_status", "last_error", "delivered_at").
+		WherePK().
+		Update()
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error updating webhook delivery")
+	}
+	return dbError(err)
+}
+
+func (r *pgWebhookRepository) FetchDeliveries(ctx context.Context, webhookID, limit int) ([]*Delivery, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	var deliveries []*Delivery
+	err := retry(ctx, func() error {
+		deliveries = []*Delivery{}
+		return conn(ctx, r.db).ModelContext(ctx, &deliveries).
+			Where("webhook_id = ?", webhookID).
+			Order("id DESC").
+			Limit(limit).
+			Select()
+	})
+	if err != nil {
+		logger(ctx).Error().Err(err).Msg("Error fetching webhook deliveries")
+		return nil, dbError(err)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddWebhook(t *testing.T) {
+	testSetup()
+	user, err := addTestUser()
+	assert.NoError(t, err)
+
+	hook, err := addTestWebhook(user)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, hook.ID)
+
+	hooks, err := webhooks.FetchUserWebhooks(context.Background(), user)
+	assert.NoError(t, err)
+	if assert.Len(t, hooks, 1) {
+		assert.Equal(t, hook.Events, hooks[0].Events)
+		assert.Equal(t, hook.Secret, hooks[0].Secret)
+	}
+}
+
+func TestEnqueueDeliveries(t *testing.T) {
+	testSetup()
+	ctx := context.Background()
+	user, err := addTestUser()
+	assert.NoError(t, err)
+	hook, err := addTestWebhook(user)
+	assert.NoError(t, err)
+
+	ids, err := webhooks.Enqueue(ctx, user.ID, EventPostCreated, []byte(`{"event":"post.created"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, ids)
+	// Webhook is not subscribed to this event
+	ids, err = webhooks.Enqueue(ctx, user.ID, EventPostUpdated, []byte(`{"event":"post.updated"}`))
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+
+	delivery, err := webhooks.FetchDelivery(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, EventPostCreated, delivery.Event)
+	assert.Equal(t, hook.URL, delivery.Webhook.URL)
+	assert.JSONEq(t, `{"event":"post.created"}`, string(delivery.Payload))
+
+	now := time.Now()
+	delivery.Status = DeliveryDelivered
+	delivery.Attempts = 1
+	delivery.ResponseStatus = 200
+	delivery.DeliveredAt = &now
+	assert.NoError(t, webhooks.UpdateDelivery(ctx, delivery))
+
+	deliveries, err := webhooks.FetchDeliveries(ctx, hook.ID, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, DeliveryDelivered, deliveries[0].Status)
+		assert.Equal(t, 200, deliveries[0].ResponseStatus)
+	}
+}
+
+func TestEnqueueRolledBack(t *testing.T) {
+	testSetup()
+	ctx := context.Background()
+	user, err := addTestUser()
+	assert.NoError(t, err)
+	hook, err := addTestWebhook(user)
+	assert.NoError(t, err)
+
+	err = transactor.WithTx(ctx, func(ctx context.Context) error {
+		_, err := webhooks.Enqueue(ctx, user.ID, EventPostCreated, []byte(`{}`))
+		assert.NoError(t, err)
+		return ErrValidation
+	})
+	assert.ErrorIs(t, err, ErrValidation)
+	deliveries, err := webhooks.FetchDeliveries(ctx, hook.ID, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, deliveries)
+}
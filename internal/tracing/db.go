@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/go-pg/pg/v10"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Statements longer than this are truncated in span attributes.
+const maxStatementLength = 4096
+
+// QueryHook creates span for every query executed through go-pg.
+type QueryHook struct{}
+
+var _ pg.QueryHook = QueryHook{}
+
+func (QueryHook) BeforeQuery(ctx context.Context, event *pg.QueryEvent) (context.Context, error) {
+	ctx, _ = Start(ctx, "db.query",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL))
+	return ctx, nil
+}
+
+func (QueryHook) AfterQuery(ctx context.Context, event *pg.QueryEvent) error {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		span.End()
+		return nil
+	}
+
+	if query, err := eventQuery(event); err == nil {
+		operation := queryOperation(query)
+		span.SetName("db." + operation)
+		span.SetAttributes(
+			semconv.DBOperationKey.String(operation),
+			semconv.DBStatementKey.String(truncate(string(query))),
+		)
+	}
+	if event.Result != nil {
+		span.SetAttributes(
+			attribute.Int("db.rows_affected", event.Result.RowsAffected()),
+			attribute.Int("db.rows_returned", event.Result.RowsReturned()),
+		)
+	}
+	if event.Err != nil && event.Err != pg.ErrNoRows {
+		span.RecordError(event.Err)
+		span.SetStatus(codes.Error, event.Err.Error())
+	}
+	span.End()
+	return nil
+}
+
+// eventQuery returns query with placeholders instead of bound values, which
+// can hold e.g. password hashes and webhook secrets.
+func eventQuery(event *pg.QueryEvent) ([]byte, error) {
+	return event.UnformattedQuery()
+}
+
+func queryOperation(query []byte) string {
+	fields := bytes.Fields(query)
+	if len(fields) == 0 {
+		return "query"
+	}
+	return string(bytes.ToUpper(fields[0]))
+}
+
+func truncate(statement string) string {
+	if len(statement) > maxStatementLength {
+		return statement[:maxStatementLength]
+	}
+	return statement
+}
@@ -0,0 +1,107 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+func recordSpans() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	return recorder
+}
+
+func TestQueryHook(t *testing.T) {
+	recorder := recordSpans()
+	hook := QueryHook{}
+	event := &pg.QueryEvent{Query: "SELECT * FROM posts"}
+
+	ctx, err := hook.BeforeQuery(context.Background(), event)
+	assert.NoError(t, err)
+	assert.NoError(t, hook.AfterQuery(ctx, event))
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "db.SELECT", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), semconv.DBStatementKey.String("SELECT * FROM posts"))
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+}
+
+func TestQueryHookError(t *testing.T) {
+	recorder := recordSpans()
+	hook := QueryHook{}
+	event := &pg.QueryEvent{Query: "DELETE FROM posts"}
+
+	ctx, err := hook.BeforeQuery(context.Background(), event)
+	assert.NoError(t, err)
+	event.Err = errors.New("connection refused")
+	assert.NoError(t, hook.AfterQuery(ctx, event))
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+}
+
+func TestQueryHookNoRows(t *testing.T) {
+	recorder := recordSpans()
+	hook := QueryHook{}
+	event := &pg.QueryEvent{Query: "SELECT * FROM posts"}
+
+	ctx, err := hook.BeforeQuery(context.Background(), event)
+	assert.NoError(t, err)
+	event.Err = pg.ErrNoRows
+	assert.NoError(t, hook.AfterQuery(ctx, event))
+
+	assert.Equal(t, codes.Unset, recorder.Ended()[0].Status().Code)
+}
+
+func TestQueryHookOmitsValues(t *testing.T) {
+	recorder := recordSpans()
+	hook := QueryHook{}
+	webhook := &struct {
+		tableName struct{} `pg:"webhooks"`
+		Secret    string
+	}{Secret: "0123456789abcdef"}
+	events := []*pg.QueryEvent{
+		{Query: "SELECT * FROM users WHERE username = ?", Params: []interface{}{"batman"}},
+		{Query: orm.NewInsertQuery(orm.NewQuery(nil, webhook))},
+	}
+
+	for _, event := range events {
+		ctx, err := hook.BeforeQuery(context.Background(), event)
+		assert.NoError(t, err)
+		assert.NoError(t, hook.AfterQuery(ctx, event))
+	}
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		statements := 0
+		for _, span := range spans {
+			for _, attr := range span.Attributes() {
+				if attr.Key == semconv.DBStatementKey {
+					statements++
+					assert.NotContains(t, attr.Value.AsString(), "batman")
+					assert.NotContains(t, attr.Value.AsString(), "0123456789abcdef")
+				}
+			}
+		}
+		assert.Equal(t, 2, statements)
+		assert.Contains(t, spans[0].Attributes(), semconv.DBStatementKey.String("SELECT * FROM users WHERE username = ?"))
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "SELECT 1", truncate("SELECT 1"))
+	assert.Len(t, truncate(strings.Repeat("a", maxStatementLength+1)), maxStatementLength)
+}
@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"context"
+	"rgb/internal/conf"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ServiceName     = "rgb"
+	instrumentation = "rgb"
+)
+
+// Setup configures global tracer provider and W3C trace context propagation.
+// Spans are exported to OTLP HTTP collector only if endpoint is configured,
+// otherwise trace context is still propagated, but no spans are recorded.
+// Returned function flushes pending spans and must be called on shutdown.
+func Setup(cfg conf.Config) func(context.Context) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if cfg.OtlpEndpoint == "" {
+		return func(context.Context) error { return nil }
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.OtlpEndpoint)}
+	if cfg.OtlpInsecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		log.Panic().Err(err).Msg("Error creating OTLP trace exporter")
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(ServiceName),
+			semconv.DeploymentEnvironmentKey.String(cfg.Env),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	log.Info().Str("endpoint", cfg.OtlpEndpoint).Msg("Exporting traces")
+	return provider.Shutdown
+}
+
+// Start creates span as child of span in the ctx, if any.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, opts...)
+}
@@ -0,0 +1,266 @@
+// Package webhooks delivers events saved in webhook outbox to subscribed
+// URLs. Every delivery is sent by its own background job.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"rgb/internal/jobs"
+	"rgb/internal/logging"
+	"rgb/internal/store"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Headers of delivery requests.
+const (
+	EventHeader     = "X-RGB-Event"
+	DeliveryHeader  = "X-RGB-Delivery"
+	TimestampHeader = "X-RGB-Timestamp"
+	// HMAC-SHA256 of timestamp and body, see Sign
+	SignatureHeader = "X-RGB-Signature"
+)
+
+// ErrForbiddenAddress is returned for webhook URLs pointing to loopback,
+// private or link-local addresses, which would let users reach services
+// which aren't public, e.g. cloud metadata endpoints.
+var ErrForbiddenAddress = errors.New("webhook address is not public")
+
+// Address ranges which aren't public, in addition to those recognized by
+// net.IP methods.
+var privateNets = mustParseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"fc00::/7",
+)
+
+// JobType is type of job delivering one webhook delivery.
+const JobType = "webhook.deliver"
+
+type deliveryJob struct {
+	DeliveryID int
+}
+
+// NewJob returns job delivering given delivery.
+func NewJob(deliveryID int) (*store.Job, error) {
+	return jobs.New(JobType, deliveryJob{DeliveryID: deliveryID})
+}
+
+// Deliverer sends webhook deliveries. Failed deliveries are retried by job
+// runner with exponential backoff.
+type Deliverer struct {
+	repo   store.WebhookRepository
+	client *http.Client
+
+	// Deliveries are given up after this many failed attempts
+	MaxAttempts int
+	// Delay before the first retry, doubled after every failed attempt up
+	// to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Deliver also to loopback and private addresses, e.g. to receivers
+	// running locally in dev
+	AllowPrivate bool
+}
+
+func NewDeliverer(repo store.WebhookRepository) *Deliverer {
+	d := &Deliverer{
+		repo:        repo,
+		MaxAttempts: 10,
+		Backoff:     30 * time.Second,
+		MaxBackoff:  6 * time.Hour,
+	}
+	// Address is checked after host is resolved, so names resolving to
+	// private addresses and redirects to them are rejected too
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: d.checkDial}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Otherwise address of proxy would be checked instead of webhook's
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	d.client = &http.Client{Timeout: 10 * time.Second, Transport: transport}
+	return d
+}
+
+// Register registers handler of JobType to runner.
+func (d *Deliverer) Register(runner *jobs.Runner) {
+	runner.Register(JobType, d.deliver, jobs.Options{
+		Concurrency: 10,
+		MaxAttempts: d.MaxAttempts,
+		// Leaves time to save result after request times out
+		Timeout:    2 * d.client.Timeout,
+		Backoff:    d.Backoff,
+		MaxBackoff: d.MaxBackoff,
+		OnFailure:  d.giveUp,
+	})
+}
+
+func (d *Deliverer) deliver(ctx context.Context, job *store.Job) error {
+	var payload deliveryJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return err
+	}
+	delivery, err := d.repo.FetchDelivery(ctx, payload.DeliveryID)
+	if errors.Is(err, store.ErrNotFound) {
+		// Webhook was deleted in the meantime
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if delivery.Status != store.DeliveryPending {
+		return nil
+	}
+
+	delivery.Attempts = job.Attempts
+	status, sendErr := d.send(ctx, delivery)
+	delivery.ResponseStatus = status
+	log := logger(ctx).With().
+		Int("delivery_id", delivery.ID).
+		Int("webhook_id", delivery.WebhookID).
+		Str("event", delivery.Event).
+		Int("attempt", delivery.Attempts).
+		Logger()
+	if sendErr == nil {
+		now := time.Now()
+		delivery.Status = store.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		log.Debug().Msg("Webhook delivered")
+	} else {
+		delivery.LastError = sendErr.Error()
+		log.Info().Err(sendErr).Msg("Webhook delivery failed")
+	}
+	// Don't lose result of the attempt when ctx is cancelled during it
+	if err := d.repo.UpdateDelivery(context.Background(), delivery); err != nil {
+		log.Error().Err(err).Msg("Error saving webhook delivery result")
+	}
+	return sendErr
+}
+
+// giveUp marks delivery as failed after its job failed for the last time.
+func (d *Deliverer) giveUp(ctx context.Context, job *store.Job, _ error) {
+	var payload deliveryJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return
+	}
+	delivery, err := d.repo.FetchDelivery(ctx, payload.DeliveryID)
+	if err != nil {
+		return
+	}
+	delivery.Status = store.DeliveryFailed
+	if err := d.repo.UpdateDelivery(ctx, delivery); err != nil {
+		logger(ctx).Error().Err(err).Int("delivery_id", delivery.ID).Msg("Error saving failed webhook delivery")
+	}
+}
+
+// send posts signed payload to webhook URL, and returns response status.
+func (d *Deliverer) send(ctx context.Context, delivery *store.Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "RGB-Webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(delivery.ID))
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(delivery.Webhook.Secret, timestamp, delivery.Payload))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	// Draining body lets connection be reused
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64<<10))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected response status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// checkDial rejects connections to addresses which aren't public, unless
+// AllowPrivate is set.
+func (d *Deliverer) checkDial(network, address string, _ syscall.RawConn) error {
+	if d.AllowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+// CheckHost returns ErrForbiddenAddress if host of webhook URL is localhost
+// or IP address which isn't public. Other names are checked when delivery
+// connects to the address they resolve to.
+func CheckHost(host string) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenAddress
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublic(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, private := range privateNets {
+		if private.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = ipNet
+	}
+	return nets
+}
+
+// Sign returns value of signature header, "sha256=" followed by hex encoded
+// HMAC-SHA256 of timestamp, dot and body, keyed with webhook's secret.
+// Receivers should compute the same and compare it in constant time.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func logger(ctx context.Context) *zerolog.Logger { return logging.Module(ctx, logging.Server) }
@@ -0,0 +1,152 @@
+package webhooks
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"rgb/internal/jobs"
+	"rgb/internal/store"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	w.WriteHeader(r.status)
+}
+
+func setup(t *testing.T, status int) (*receiver, store.Repositories, *store.Webhook) {
+	rec := &receiver{status: status}
+	server := httptest.NewServer(rec)
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	repos := store.NewMemoryRepositories()
+	hook := &store.Webhook{URL: server.URL, Events: []string{store.EventPostCreated}, Secret: "0123456789abcdef"}
+	assert.NoError(t, repos.Webhooks.Add(ctx, &store.User{ID: 1}, hook))
+	ids, err := repos.Webhooks.Enqueue(ctx, 1, store.EventPostCreated, []byte(`{"event":"post.created"}`))
+	assert.NoError(t, err)
+	job, err := NewJob(ids[0])
+	assert.NoError(t, err)
+	assert.NoError(t, repos.Jobs.Enqueue(ctx, job))
+	return rec, repos, hook
+}
+
+// newLocalDeliverer returns deliverer which can reach test receivers on
+// loopback address.
+func newLocalDeliverer(repos store.Repositories) *Deliverer {
+	d := NewDeliverer(repos.Webhooks)
+	d.AllowPrivate = true
+	return d
+}
+
+func newRunner(repos store.Repositories, d *Deliverer) *jobs.Runner {
+	runner := jobs.NewRunner(repos.Jobs)
+	d.Register(runner)
+	return runner
+}
+
+func TestDeliver(t *testing.T) {
+	rec, repos, hook := setup(t, http.StatusNoContent)
+	runner := newRunner(repos, newLocalDeliverer(repos))
+
+	assert.Equal(t, 1, runner.RunDue(context.Background()))
+	if assert.Len(t, rec.requests, 1) {
+		req := rec.requests[0]
+		assert.Equal(t, store.EventPostCreated, req.Header.Get(EventHeader))
+		assert.Equal(t, "1", req.Header.Get(DeliveryHeader))
+		assert.Equal(t, Sign(hook.Secret, req.Header.Get(TimestampHeader), rec.bodies[0]), req.Header.Get(SignatureHeader))
+		assert.JSONEq(t, `{"event":"post.created"}`, string(rec.bodies[0]))
+	}
+
+	deliveries, err := repos.Webhooks.FetchDeliveries(context.Background(), hook.ID, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, store.DeliveryDelivered, deliveries[0].Status)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Equal(t, http.StatusNoContent, deliveries[0].ResponseStatus)
+		assert.NotNil(t, deliveries[0].DeliveredAt)
+	}
+	assert.Equal(t, 0, runner.RunDue(context.Background()))
+}
+
+func TestDeliverRetries(t *testing.T) {
+	rec, repos, hook := setup(t, http.StatusInternalServerError)
+	d := newLocalDeliverer(repos)
+	d.Backoff = time.Millisecond
+	d.MaxAttempts = 3
+	runner := newRunner(repos, d)
+
+	assert.Equal(t, 1, runner.RunDue(context.Background()))
+	deliveries, _ := repos.Webhooks.FetchDeliveries(context.Background(), hook.ID, 10)
+	assert.Equal(t, store.DeliveryPending, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusInternalServerError, deliveries[0].ResponseStatus)
+	assert.Equal(t, "unexpected response status 500", deliveries[0].LastError)
+
+	for i := 0; i < 2; i++ {
+		time.Sleep(5 * time.Millisecond)
+		assert.Equal(t, 1, runner.RunDue(context.Background()))
+	}
+	deliveries, _ = repos.Webhooks.FetchDeliveries(context.Background(), hook.ID, 10)
+	assert.Equal(t, store.DeliveryFailed, deliveries[0].Status)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Len(t, rec.requests, 3)
+
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, 0, runner.RunDue(context.Background()))
+}
+
+func TestDeliverDeletedWebhook(t *testing.T) {
+	rec, repos, hook := setup(t, http.StatusNoContent)
+	runner := newRunner(repos, newLocalDeliverer(repos))
+	assert.NoError(t, repos.Webhooks.Delete(context.Background(), hook))
+
+	assert.Equal(t, 1, runner.RunDue(context.Background()))
+	assert.Empty(t, rec.requests)
+	job, err := repos.Jobs.Fetch(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, store.JobDone, job.Status)
+}
+
+func TestDeliverPrivateAddress(t *testing.T) {
+	rec, repos, hook := setup(t, http.StatusNoContent)
+	runner := newRunner(repos, NewDeliverer(repos.Webhooks))
+
+	assert.Equal(t, 1, runner.RunDue(context.Background()))
+	assert.Empty(t, rec.requests)
+	deliveries, _ := repos.Webhooks.FetchDeliveries(context.Background(), hook.ID, 10)
+	assert.Equal(t, store.DeliveryPending, deliveries[0].Status)
+	assert.Contains(t, deliveries[0].LastError, ErrForbiddenAddress.Error())
+}
+
+func TestCheckHost(t *testing.T) {
+	for _, host := range []string{"localhost", "api.localhost", "127.0.0.1", "::1", "10.1.2.3", "172.16.0.1",
+		"192.168.1.1", "169.254.169.254", "fe80::1", "fd00::1", "0.0.0.0", "::ffff:127.0.0.1"} {
+		assert.ErrorIs(t, CheckHost(host), ErrForbiddenAddress, host)
+	}
+	for _, host := range []string{"example.com", "93.184.216.34", "2606:2800:220:1::1"} {
+		assert.NoError(t, CheckHost(host), host)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Computed with: printf '1633089600.{}' | openssl dgst -sha256 -hmac secret
+	expected := "sha256=f43f048b8d9e994e7d9f66579ccf9a60bb57e88ec478757aa1075cd808cdbdc1"
+	assert.Equal(t, expected, Sign("secret", "1633089600", []byte("{}")))
+}
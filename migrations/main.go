@@ -7,9 +7,8 @@ import (
 
 	"rgb/internal/conf"
 	"rgb/internal/database"
+	"rgb/internal/migrations"
 	"rgb/internal/store"
-
-	"github.com/go-pg/migrations/v8"
 )
 
 const usageText = `This program runs command on the db. Supported commands are:
@@ -21,15 +20,16 @@ const usageText = `This program runs command on the db. Supported commands are:
   - version - prints current db version.
   - set_version [version] - sets db version without running migrations.
 Usage:
-  go run *.go <command> [args]
+  go run *.go [-env dev|prod] <command> [args]
 `
 
 func main() {
 	flag.Usage = usage
+	env := flag.String("env", "dev", `Sets run environment. Possible values are "dev" and "prod"`)
 	flag.Parse()
 
-	store.SetDBConnection(database.NewDBOptions(conf.NewConfig("dev")))
-	db := store.GetDBConnection()
+	db := store.NewDBConnection(database.NewDBOptions(conf.NewConfig(*env)))
+	defer db.Close()
 
 	oldVersion, newVersion, err := migrations.Run(db, flag.Args()...)
 	if err != nil {
@@ -0,0 +1,27 @@
+#! /bin/bash
+
+# Vendors Swagger UI assets served by dev server at /api/docs, so the page
+# doesn't load scripts from CDN at runtime. Run from repository root and
+# commit the downloaded files.
+
+set -euo pipefail
+
+version=3.52.5
+dir=internal/server/swaggerui
+tarball=https://registry.npmjs.org/swagger-ui-dist/-/swagger-ui-dist-$version.tgz
+
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+curl -fsSL "$tarball" -o "$tmp/swagger-ui-dist.tgz"
+# Check tarball against checksum published by npm registry
+expected=$(curl -fsSL "https://registry.npmjs.org/swagger-ui-dist/$version" | sed -n 's/.*"shasum":"\([0-9a-f]*\)".*/\1/p')
+actual=$(sha1sum "$tmp/swagger-ui-dist.tgz" | cut -d ' ' -f 1)
+if [ -z "$expected" ] || [ "$expected" != "$actual" ]; then
+  echo "Checksum of swagger-ui-dist $version doesn't match" >&2
+  exit 1
+fi
+
+tar -xzf "$tmp/swagger-ui-dist.tgz" -C "$tmp"
+cp "$tmp/package/swagger-ui.css" "$tmp/package/swagger-ui-bundle.js" "$dir/"
+echo "Swagger UI $version vendored to $dir"